@@ -40,17 +40,44 @@ type Timing struct {
 	Ended     bool
 }
 
+// FileAccess is a file a test opened or stat'd, as reported to the test log
+type FileAccess struct {
+	Op   string // "open" or "stat"
+	Path string
+}
+
 // Statistics are the test results that will be saved to the DB
 type Statistics struct {
-	Name     string
-	Failed   bool
-	Fatal    bool
-	Statuses []Status
-	Timings  map[string]Timing
-	Start    time.Time
-	End      time.Time
-	Duration time.Duration
-	Output   string
+	Name       string
+	Failed     bool
+	Fatal      bool
+	Statuses   []Status
+	Timings    map[string]Timing
+	Start      time.Time
+	End        time.Time
+	Duration   time.Duration
+	Output     string
+	SkipReason string // message passed to Skip/Skipf; empty if the test was not skipped or skipped without a reason
+	Parallel   bool   // whether the test called t.Parallel(); if not, it held the serial slot for its whole Duration
+	Attempt    int    // 1 for the first run of a test since the statistics were cleared, 2 for the second (e.g. with -count), etc.
+	RunID      string // the runner's RunInfo().RunID of the Run the test ran in
+	Retry      int    // 0 for the first attempt within a Run, 1 for the first retry of a failed test, etc.
+	Flaky      bool   // passed on a retry after failing in an earlier attempt of the same Run (see runner.SetRetries)
+
+	RetryBackoff  time.Duration // total time the runner waited between retries before this attempt
+	TimeoutStacks string        // stacks of the test's goroutines if it ran past the per-test timeout
+	LogOutput     string        // the test's output logged with t.Log, t.Error etc., with SetSplitLogOutput
+	RawOutput     string        // the test's output written to stdout or stderr directly, with SetSplitLogOutput
+	RaceDetected  bool          // the race detector reported a data race while the test ran, with SetDetectRaces
+	RaceReport    string        // the race detector's reports printed while the test ran, with SetDetectRaces
+	FileAccesses  []FileAccess  // the files opened or stat'd while the test ran, in order and without repeats, with SetTrackFileAccess
+	OpenFiles     []string      // the files opened and not closed while the test ran, once per open, with SetTrackOpenFiles
+	Stderr        string        // what was written to stderr while the test ran, with SetFailOnStderr (which failed it) or SetCombineOutput(false)
+
+	// file:line of each message a failed test printed, in order and without
+	// repeats, as the testing package reported it: the caller of a helper that
+	// called t.Helper. t.Log messages have a location too and are included.
+	FailureLocations []string
 }
 
 const DefaultHttpTimeout = time.Second * 30 // default HTTP client timeout
@@ -2,6 +2,7 @@ package testdeck
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -40,6 +41,9 @@ type TD struct {
 	statuses         []constants.Status // stack of statuses; statuses are emitted by Error/Fatal operation or when the lifecycle completes successfully
 	timings          map[string]constants.Timing
 	actualName       string // name of testdeck test case (to pass to testing.T)
+	skipReason       string // message passed to Skip/Skipf
+	parallel         bool   // whether t.Parallel() was called
+	timeoutStacks    string // set by the runner's per-test watchdog
 }
 
 // An interface for testdeck test cases; it is implemented by the TestCase struct below
@@ -80,8 +84,7 @@ func (tc *TestCase) AfterMethod(t *TD) {
 // t is the interface for testing.T
 // tc is the interface for testdeck test cases
 // options is an optional parameter for passing in special test configurations
-func Test(t TestingT, tc TestCaseDelegate, options ...TestConfig) *TD {
-	// FIXME: currently tests cannot be run by matching name
+func Test(t TestingT, tc TestCaseDelegate, options ...TestConfig) (td *TD) {
 	tagged, matched, actualName := runner.MatchTag(t.Name())
 
 	// start timer
@@ -92,24 +95,35 @@ func Test(t TestingT, tc TestCaseDelegate, options ...TestConfig) *TD {
 	}
 
 	// initiate testdeck test case
-	td := &TD{
+	td = &TD{
 		T:                t,
 		fatal:            false,
 		currentLifecycle: constants.LifecycleTestSetup, // start in the test setup step
 		timings:          make(map[string]constants.Timing),
 	}
 
+	// admitted before pausing in t.Parallel, so that like with go test
+	// -failfast a parallel test that started before a failure still runs
+	if runner.Initialized() && (!tagged || matched) {
+		r := runner.Instance(nil)
+		if ok, reason := r.Admit(actualName); !ok {
+			r.LogEvent(fmt.Sprintf("Skipping %s: %s", actualName, reason))
+			td.skipReason = reason
+			td.T.Skip(reason)
+			return td
+		}
+	}
+
 	// if test configurations struct was passed, config the settings
 	if len(options) > 0 {
 		if options[0].ParallelOff == false {
-			td.T.Parallel()
+			td.Parallel()
 		}
 	} else {
 		// if no configs were passed, turn on parallel by default
-		td.T.Parallel()
+		td.Parallel()
 	}
 
-	// FIXME: currently tests cannot be run by matching name
 	if tagged {
 		if !matched {
 			if runner.Initialized() {
@@ -121,11 +135,29 @@ func Test(t TestingT, tc TestCaseDelegate, options ...TestConfig) *TD {
 		td.actualName = actualName
 	}
 
+	stopWatch := func() {}
+	if runner.Initialized() {
+		r := runner.Instance(nil)
+		if td.parallel {
+			r.BackOffForHeap(td.Name())
+		}
+		r.TestStarted(td.Name())
+
+		stopWatch = r.WatchTest(func(d time.Duration, stacks string) {
+			td.timeoutStacks = stacks
+			td.T.Errorf("test did not finish within the per-test timeout of %s; stacks of its goroutines:\n%s", d, stacks)
+		})
+	}
+
 	arrangeComplete := false
 
 	// runs at the end of the test
 	defer func() {
 		end := time.Now()
+		stopWatch() // waits for a timeout report in progress
+		if td.timeoutStacks != "" {
+			td.setFailed(false)
+		}
 
 		// clean up and set test to finished
 		if !td.Skipped() || arrangeComplete {
@@ -155,6 +187,17 @@ func Test(t TestingT, tc TestCaseDelegate, options ...TestConfig) *TD {
 			r.AddStatistics(stats)
 		}
 	}()
+
+	// runs first: turn an os.Exit(0) from the test into a failure so the run can continue
+	defer func() {
+		if p := recover(); p != nil {
+			if !runner.IsExitPanic(p) {
+				panic(p)
+			}
+			td.Error(runner.ExitMessage)
+		}
+	}()
+
 	tc.ArrangeMethod(td)
 	arrangeComplete = true
 	tc.ActMethod(td)
@@ -169,14 +212,18 @@ func Test(t TestingT, tc TestCaseDelegate, options ...TestConfig) *TD {
 // Create a statistics struct for use in saving to DB later
 func (c *TD) makeStatistics(start time.Time, end time.Time) *constants.Statistics {
 	return &constants.Statistics{
-		Name:     c.Name(),
-		Failed:   c.Failed(),
-		Fatal:    c.fatal,
-		Statuses: c.statuses,
-		Timings:  c.timings,
-		Start:    start,
-		End:      end,
-		Duration: end.Sub(start),
+		Name:       c.Name(),
+		Failed:     c.Failed(),
+		Fatal:      c.fatal,
+		Statuses:   c.statuses,
+		Timings:    c.timings,
+		Start:      start,
+		End:        end,
+		Duration:   end.Sub(start),
+		SkipReason: c.skipReason,
+		Parallel:   c.parallel,
+
+		TimeoutStacks: c.timeoutStacks,
 	}
 }
 
@@ -200,8 +247,9 @@ func (c *TD) setFailed(fatal bool) {
 	c.statuses = append(c.statuses, status)
 }
 
-// Add result of SKIPPED lifecycle stage to stack
-func (c *TD) setSkipped() {
+// Add result of SKIPPED lifecycle stage to stack and record the reason
+func (c *TD) setSkipped(reason string) {
+	c.skipReason = reason
 	status := constants.Status{
 		Status:    constants.StatusSkip,
 		Lifecycle: c.currentLifecycle,
@@ -328,19 +376,20 @@ func (c *TD) Fatalf(format string, args ...interface{}) {
 
 // Skip passes through to testing.T.Skip
 func (c *TD) Skip(args ...interface{}) {
-	c.setSkipped()
+	// same formatting as testing.T.Skip (Sprintln without the trailing newline)
+	c.setSkipped(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
 	c.T.Skip(args...)
 }
 
 // Skipf passes through to testing.T.Skipf
 func (c *TD) Skipf(format string, args ...interface{}) {
-	c.setSkipped()
+	c.setSkipped(fmt.Sprintf(format, args...))
 	c.T.Skipf(format, args...)
 }
 
 // SkipNow passes through to testing.T.SkipNow
 func (c *TD) SkipNow() {
-	c.setSkipped()
+	c.setSkipped("")
 	c.T.SkipNow()
 }
 
@@ -353,6 +402,7 @@ func (c *TD) FailNow() {
 
 // Parallel passes through to testing.T.Parallel
 func (c *TD) Parallel() {
+	c.parallel = true
 	c.T.Parallel()
 }
 
@@ -2,11 +2,14 @@ package testdeck
 
 import (
 	"errors"
+	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/mercari/testdeck/constants"
 	. "github.com/mercari/testdeck/fname"
+	"github.com/mercari/testdeck/runner"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -114,6 +117,10 @@ func (t *mockT) Fail() {
 	t.logCall()
 }
 
+func (t *mockT) Parallel() {
+	t.logCall()
+}
+
 func (t *mockT) Helper() {
 	t.logCall()
 }
@@ -582,6 +589,108 @@ func Test_TestWithErrorAndFatal_ShouldBeMarkedFatalAfterFatal(t *testing.T) {
 	}, td.statuses[2])
 }
 
+func Test_TestWithSkip_ShouldRecordSkipReason(t *testing.T) {
+	cases := map[string]struct {
+		skip       func(t *TD)
+		wantReason string
+	}{
+		"Skip": {
+			skip: func(t *TD) {
+				t.Skip("no database configured")
+			},
+			wantReason: "no database configured",
+		},
+		"SkipMultipleArgs": {
+			skip: func(t *TD) {
+				t.Skip("missing env:", "DB_URL")
+			},
+			wantReason: "missing env: DB_URL",
+		},
+		"Skipf": {
+			skip: func(t *TD) {
+				t.Skipf("requires %s", "staging")
+			},
+			wantReason: "requires staging",
+		},
+		"SkipNow": {
+			skip: func(t *TD) {
+				t.SkipNow()
+			},
+			wantReason: "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			mock := newMockT()
+
+			// Act
+			td := Test(mock, &TestCase{Act: tc.skip}, TestConfig{ParallelOff: true})
+			stats := td.makeStatistics(time.Now(), time.Now())
+
+			// Assert
+			assert.Equal(t, tc.wantReason, stats.SkipReason)
+			assert.Equal(t, constants.Status{
+				Status:    constants.StatusSkip,
+				Lifecycle: constants.LifecycleAct,
+			}, td.statuses[0])
+		})
+	}
+}
+
+func Test_Test_ShouldFailTestCallingOsExit0(t *testing.T) {
+	// Arrange
+	(runner.TestDeps{}).SetPanicOnExit0(true) // go test turns it on as well
+	mock := newMockT()
+	test := &TestCase{
+		Act: func(t *TD) {
+			os.Exit(0)
+		},
+	}
+
+	// Act
+	td := Test(mock, test, TestConfig{ParallelOff: true})
+
+	// Assert
+	require.Equal(t, 1, len(mock.argsList["Error"]))
+	assert.Equal(t, []interface{}{runner.ExitMessage}, mock.argsList["Error"][0])
+	assert.Equal(t, constants.Status{
+		Status:    constants.StatusFail,
+		Lifecycle: constants.LifecycleAct,
+	}, td.statuses[0])
+}
+
+func Test_Test_ShouldRecordParallel(t *testing.T) {
+	cases := map[string]struct {
+		options      []TestConfig
+		wantParallel bool
+	}{
+		"Default":     {options: nil, wantParallel: true},
+		"ParallelOn":  {options: []TestConfig{{ParallelOff: false}}, wantParallel: true},
+		"ParallelOff": {options: []TestConfig{{ParallelOff: true}}, wantParallel: false},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			mock := newMockT()
+
+			// Act
+			td := Test(mock, &TestCase{}, tc.options...)
+			stats := td.makeStatistics(time.Now(), time.Now())
+
+			// Assert
+			assert.Equal(t, tc.wantParallel, stats.Parallel)
+			if tc.wantParallel {
+				assert.Equal(t, 1, mock.callCount.get("Parallel"))
+			} else {
+				assert.Equal(t, 0, mock.callCount.get("Parallel"))
+			}
+		})
+	}
+}
+
 func Test_TestingT_RunShouldPass(t *testing.T) {
 	// Arrange
 	test := &TestCase{}
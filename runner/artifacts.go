@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+/*
+artifacts.go: Writing the files a Run produces (test log, profiles, events and reports) into one directory per Run,
+<ArtifactDir>/<RunID>/{logs,profiles,reports}, so that embedders have one place to collect them from.
+*/
+
+// Artifacts are the paths of the files a Run wrote with SetArtifactDir
+type Artifacts struct {
+	Dir        string `json:"dir"`                   // <ArtifactDir>/<RunID>
+	TestLog    string `json:"test_log"`              // logs/testlog.txt, the test log (see SetTestLogWriter)
+	Output     string `json:"output"`                // logs/output.txt, the output of the Run
+	CPUProfile string `json:"cpu_profile,omitempty"` // profiles/cpu.pprof, if SetCPUProfile is set
+	Events     string `json:"events"`                // reports/events.json, see WriteTestEvents
+	Summary    string `json:"summary"`               // reports/summary.yaml, see WriteSummaryYAML
+	Result     string `json:"result"`                // reports/result.json, the Result as JSON
+}
+
+// SetArtifactDir makes each Run write its test log, output, CPU profile (in
+// place of the path given to SetCPUProfile), JSON events and reports into
+// the subtree <dir>/<RunID>/{logs,profiles,reports}, creating it as needed.
+// The paths are in Result().Artifacts. If the subtree can't be created the
+// Run goes on without it and records a warning. Empty turns it off.
+func (r *runner) SetArtifactDir(dir string) {
+	r.artifactDir = dir
+}
+
+// prepareArtifacts creates the artifact subtree of the Run and sends the test
+// log to it until finish is called
+func (r *runner) prepareArtifacts() (finish func()) {
+	r.artifacts = nil
+	if r.artifactDir == "" {
+		return func() {}
+	}
+	dir := filepath.Join(r.artifactDir, r.runInfo.RunID)
+	for _, sub := range []string{"logs", "profiles", "reports"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o777); err != nil {
+			r.addWarning(fmt.Sprintf("creating the artifact directory: %v", err))
+			return func() {}
+		}
+	}
+	a := &Artifacts{
+		Dir:     dir,
+		TestLog: filepath.Join(dir, "logs", "testlog.txt"),
+		Output:  filepath.Join(dir, "logs", "output.txt"),
+		Events:  filepath.Join(dir, "reports", "events.json"),
+		Summary: filepath.Join(dir, "reports", "summary.yaml"),
+		Result:  filepath.Join(dir, "reports", "result.json"),
+	}
+	if r.cpuProfile != "" {
+		a.CPUProfile = filepath.Join(dir, "profiles", "cpu.pprof")
+	}
+	testLog, err := os.Create(a.TestLog)
+	if err != nil {
+		r.addWarning(fmt.Sprintf("creating the test log artifact: %v", err))
+		return func() {}
+	}
+	r.artifacts = a
+
+	deps, ok := r.deps.(*TestDeps)
+	if ok {
+		deps.testLogOut = testLog
+		if r.testLogOut != nil {
+			deps.testLogOut = io.MultiWriter(r.testLogOut, testLog)
+		}
+	}
+	return func() {
+		if ok {
+			deps.testLogOut = r.testLogOut
+		}
+		if err := testLog.Close(); err != nil {
+			r.addWarning(fmt.Sprintf("writing the test log artifact: %v", err))
+		}
+	}
+}
+
+// writeArtifacts writes the output and reports of the finished Run
+func (r *runner) writeArtifacts() {
+	a := r.artifacts
+	if a == nil {
+		return
+	}
+	res := r.Result()
+	files := []struct {
+		path  string
+		write func(w io.Writer) error
+	}{
+		{a.Output, func(w io.Writer) error {
+			_, err := io.WriteString(w, r.output)
+			return err
+		}},
+		{a.Events, func(w io.Writer) error { return WriteTestEvents(w, r.output) }},
+		{a.Summary, func(w io.Writer) error { return WriteSummaryYAML(w, res) }},
+		{a.Result, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(res)
+		}},
+	}
+	for _, file := range files {
+		if err := writeArtifact(file.path, file.write); err != nil {
+			r.addWarning(fmt.Sprintf("writing artifact %s: %v", file.path, err))
+		}
+	}
+}
+
+// writeArtifact creates the file at path with write
+func writeArtifact(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
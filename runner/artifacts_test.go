@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SetArtifactDir_ShouldWriteRunFilesIntoLayout(t *testing.T) {
+	// Arrange
+	var r *runner
+	r = newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestReadsFixture", F: func(t *testing.T) {
+			Open("testdata/fixture.json")
+			r.AddStatistics(&constants.Statistics{Name: "TestReadsFixture"})
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	dir := t.TempDir()
+	r.SetArtifactDir(dir)
+	r.SetRunID("run-1")
+	r.SetCPUProfile(filepath.Join(dir, "elsewhere.prof")) // replaced by the artifact path
+	r.SetCount(1)                                         // not the outer go test's -count
+	defer setTestFlag("test.run", "")()                   // selects the tests of the outer go test too
+	defer setTestFlag("test.testlogfile", "")()           // see Test_Run_ShouldCallDepsAroundTests
+
+	// Act
+	r.Run()
+
+	// Assert
+	runDir := filepath.Join(dir, "run-1")
+	want := &Artifacts{
+		Dir:        runDir,
+		TestLog:    filepath.Join(runDir, "logs", "testlog.txt"),
+		Output:     filepath.Join(runDir, "logs", "output.txt"),
+		CPUProfile: filepath.Join(runDir, "profiles", "cpu.pprof"),
+		Events:     filepath.Join(runDir, "reports", "events.json"),
+		Summary:    filepath.Join(runDir, "reports", "summary.yaml"),
+		Result:     filepath.Join(runDir, "reports", "result.json"),
+	}
+	assert.Empty(t, r.Warnings())
+	assert.Equal(t, want, r.Result().Artifacts)
+	testLog, err := os.ReadFile(want.TestLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(testLog), "open testdata/fixture.json\n")
+	for _, path := range []string{want.Output, want.CPUProfile, want.Events, want.Summary} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.NotZero(t, info.Size(), path)
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "elsewhere.prof"))
+	data, err := os.ReadFile(want.Result)
+	require.NoError(t, err)
+	var res Result
+	require.NoError(t, json.Unmarshal(data, &res))
+	assert.Equal(t, "run-1", res.RunID)
+	assert.Equal(t, want, res.Artifacts)
+	require.Len(t, res.Stats, 1)
+	assert.Equal(t, "TestReadsFixture", res.Stats[0].Name)
+}
+
+func Test_SetArtifactDir_ShouldWarnIfLayoutCantBeCreated(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA")).(*runner)
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o666))
+	r.SetArtifactDir(file) // not a directory
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {}
+
+	// Act
+	r.Run()
+
+	// Assert
+	require.Len(t, r.Warnings(), 1)
+	assert.Contains(t, r.Warnings()[0], "creating the artifact directory")
+	assert.Nil(t, r.Result().Artifacts)
+}
@@ -0,0 +1,335 @@
+package runner
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+benchmark.go: Running single benchmarks with a -benchtime and GOMAXPROCS, and summary statistics of repeated benchmark results (e.g. from -count=N).
+The runner doesn't run the benchmarks of a Run; the results come from RunBenchmark, testing.Benchmark or similar.
+*/
+
+// NamedBenchmarkResult is the result of one run of a benchmark. The custom
+// metrics the benchmark reported with b.ReportMetric (or, for a
+// sub-benchmark, BenchmarkTree.ReportMetric) are in Result.Extra by unit,
+// e.g. Result.Extra["items/sec"].
+type NamedBenchmarkResult struct {
+	Name   string
+	Result testing.BenchmarkResult
+}
+
+// ParseBenchTime parses a -benchtime value: either a duration to run the
+// benchmark for, such as "2s" or "100ms", or an iteration count such as
+// "100x" to run exactly, in place of the adaptive choice of b.N. Only one of
+// d and n is set.
+func ParseBenchTime(s string) (d time.Duration, n int, err error) {
+	if strings.HasSuffix(s, "x") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "x"))
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid benchtime %q: the iteration count must be a positive integer, e.g. 100x", s)
+		}
+		return 0, n, nil
+	}
+	d, err = time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, 0, fmt.Errorf("invalid benchtime %q: must be a positive duration (e.g. 2s) or an iteration count (e.g. 100x)", s)
+	}
+	return d, 0, nil
+}
+
+// BenchConfig sets the conditions benchmarks run under
+type BenchConfig struct {
+	// BenchTime is how long to run each benchmark (see ParseBenchTime); empty
+	// keeps the command line -test.benchtime, 1s by default
+	BenchTime string
+	// GOMAXPROCS pins runtime.GOMAXPROCS while the benchmark runs, restoring
+	// it afterward, and suffixes the result names with "-N" like go test -cpu
+	// does (except for 1, which go test doesn't suffix either); 0 keeps the
+	// current value and the plain names
+	GOMAXPROCS int
+	// CPUProfilePerBenchmark runs each benchmark with the pprof label
+	// bench=name (its result name), so an active CPU profile can be narrowed
+	// to one with go tool pprof -tagfocus=bench=name. Sub-benchmarks run with
+	// RunBenchmarkTreeConfig are labelled with their full path.
+	CPUProfilePerBenchmark bool
+}
+
+// RunBenchmark runs f with testing.Benchmark for benchTime; it is
+// RunBenchmarkConfig with only BenchConfig.BenchTime set.
+func RunBenchmark(name string, benchTime string, f func(b *testing.B)) (NamedBenchmarkResult, error) {
+	return RunBenchmarkConfig(name, BenchConfig{BenchTime: benchTime}, f)
+}
+
+// RunBenchmarkConfig runs f with testing.Benchmark under cfg. It sets
+// -test.benchtime and GOMAXPROCS while f runs, so it must not be called
+// concurrently. With an iteration count, the benchmark's b.N is exactly that
+// count, after a first call with b.N = 1 like under go test.
+func RunBenchmarkConfig(name string, cfg BenchConfig, f func(b *testing.B)) (NamedBenchmarkResult, error) {
+	if cfg.BenchTime != "" {
+		if _, _, err := ParseBenchTime(cfg.BenchTime); err != nil {
+			return NamedBenchmarkResult{}, err
+		}
+	}
+	if cfg.GOMAXPROCS < 0 {
+		return NamedBenchmarkResult{}, fmt.Errorf("invalid GOMAXPROCS %d: must be positive, or 0 to keep the current value", cfg.GOMAXPROCS)
+	}
+
+	if cfg.BenchTime != "" {
+		if flag.Lookup("test.benchtime") == nil {
+			testing.Init() // outside of a test binary
+		}
+		defer setTestFlag("test.benchtime", cfg.BenchTime)()
+	}
+	if cfg.GOMAXPROCS > 0 {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(cfg.GOMAXPROCS))
+	}
+	name = cfg.benchName(name)
+	if !cfg.CPUProfilePerBenchmark {
+		return NamedBenchmarkResult{Name: name, Result: testing.Benchmark(f)}, nil
+	}
+	var result testing.BenchmarkResult
+	// the goroutines testing.Benchmark starts inherit the label
+	pprof.Do(context.Background(), pprof.Labels("bench", name), func(context.Context) {
+		result = testing.Benchmark(f)
+	})
+	return NamedBenchmarkResult{Name: name, Result: result}, nil
+}
+
+// benchName returns name with the -N suffix of cfg.GOMAXPROCS
+func (cfg BenchConfig) benchName(name string) string {
+	if cfg.GOMAXPROCS <= 1 {
+		return name
+	}
+	return fmt.Sprintf("%s-%d", name, cfg.GOMAXPROCS)
+}
+
+// BenchmarkTree records the results of the sub-benchmarks of a benchmark run
+// with RunBenchmarkTree. testing doesn't report sub-benchmark results (or
+// their names) outside of go test, so sub-benchmarks must be started with
+// BenchmarkTree.Run instead of b.Run.
+type BenchmarkTree struct {
+	label   func(path string) string // the pprof label of a sub-benchmark, or nil
+	mu      sync.Mutex
+	paths   map[*testing.B]string
+	extra   map[*testing.B]map[string]float64 // see ReportMetric
+	results []NamedBenchmarkResult
+	index   map[string]int // of results by name
+}
+
+// Run runs f as the sub-benchmark name of b, like b.Run, and records its
+// result under its full path, e.g. "BenchmarkParse/small/json". Only the
+// iterations, time and the metrics reported with ReportMetric are recorded;
+// testing doesn't expose the allocations of a sub-benchmark. The time is that
+// of the whole call of f, so it includes any setup f excludes from its own
+// timer with b.ResetTimer or b.StopTimer.
+func (tree *BenchmarkTree) Run(b *testing.B, name string, f func(b *testing.B)) bool {
+	tree.mu.Lock()
+	path := tree.paths[b] + "/" + name
+	tree.mu.Unlock()
+
+	return b.Run(name, func(sub *testing.B) {
+		tree.mu.Lock()
+		tree.paths[sub] = path
+		delete(tree.extra, sub) // like testing, only the last call's metrics count
+		tree.mu.Unlock()
+		tree.record(path, testing.BenchmarkResult{}) // keeps parents before their sub-benchmarks
+
+		start := time.Now() // testing doesn't expose the timer of a sub-benchmark
+		if tree.label != nil {
+			// replaces the label inherited from the parent
+			pprof.Do(context.Background(), pprof.Labels("bench", tree.label(path)), func(context.Context) { f(sub) })
+		} else {
+			f(sub)
+		}
+		elapsed := time.Since(start)
+
+		// testing calls f again with a larger b.N until the benchtime is
+		// reached, so the last call's result is the one that counts
+		tree.mu.Lock()
+		extra := tree.extra[sub]
+		tree.mu.Unlock()
+		tree.record(path, testing.BenchmarkResult{N: sub.N, T: elapsed, Extra: extra})
+	})
+}
+
+// ReportMetric calls b.ReportMetric and records the metric in the result of
+// b, if it is a sub-benchmark started with Run; testing keeps the metrics of
+// sub-benchmarks to itself. As with b.ReportMetric, the last value reported
+// for a unit wins.
+func (tree *BenchmarkTree) ReportMetric(b *testing.B, n float64, unit string) {
+	b.ReportMetric(n, unit)
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	if tree.extra[b] == nil {
+		tree.extra[b] = make(map[string]float64)
+	}
+	tree.extra[b][unit] = n
+}
+
+func (tree *BenchmarkTree) record(path string, result testing.BenchmarkResult) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	if i, ok := tree.index[path]; ok {
+		tree.results[i].Result = result
+		return
+	}
+	tree.index[path] = len(tree.results)
+	tree.results = append(tree.results, NamedBenchmarkResult{Name: path, Result: result})
+}
+
+// RunBenchmarkTree is RunBenchmark for a benchmark with sub-benchmarks (see
+// BenchmarkTree). It returns the result of the benchmark itself, named name,
+// followed by the result of each sub-benchmark in the order they first ran.
+func RunBenchmarkTree(name string, benchTime string, f func(b *testing.B, tree *BenchmarkTree)) ([]NamedBenchmarkResult, error) {
+	return RunBenchmarkTreeConfig(name, BenchConfig{BenchTime: benchTime}, f)
+}
+
+// RunBenchmarkTreeConfig is RunBenchmarkTree under cfg. With a pinned
+// GOMAXPROCS each sub-benchmark's name is suffixed too, e.g.
+// "BenchmarkParse/small-4" like go test.
+func RunBenchmarkTreeConfig(name string, cfg BenchConfig, f func(b *testing.B, tree *BenchmarkTree)) ([]NamedBenchmarkResult, error) {
+	tree := &BenchmarkTree{
+		paths: make(map[*testing.B]string),
+		extra: make(map[*testing.B]map[string]float64),
+		index: make(map[string]int),
+	}
+	if cfg.CPUProfilePerBenchmark {
+		tree.label = cfg.benchName
+	}
+	top, err := RunBenchmarkConfig(name, cfg, func(b *testing.B) {
+		tree.mu.Lock()
+		tree.paths[b] = name
+		tree.mu.Unlock()
+		f(b, tree)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range tree.results {
+		tree.results[i].Name = cfg.benchName(tree.results[i].Name)
+	}
+	return append([]NamedBenchmarkResult{top}, tree.results...), nil
+}
+
+// GroupBenchmarkResults groups results by their full name, e.g. to aggregate
+// the repeated runs of each sub-benchmark with AggregateBenchmark
+func GroupBenchmarkResults(results []NamedBenchmarkResult) map[string][]NamedBenchmarkResult {
+	groups := make(map[string][]NamedBenchmarkResult)
+	for _, result := range results {
+		groups[result.Name] = append(groups[result.Name], result)
+	}
+	return groups
+}
+
+// WriteBenchmarkCSV writes results as CSV for spreadsheets: a header row and
+// a row per result with its name, N, ns/op, B/op and allocs/op. A result that
+// didn't run (N is 0) has blank metrics rather than zeros; otherwise all of
+// them are measured, as testing.Benchmark always records the allocations.
+func WriteBenchmarkCSV(w io.Writer, results []NamedBenchmarkResult) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "N", "ns/op", "B/op", "allocs/op"})
+	for _, res := range results {
+		r := res.Result
+		row := []string{res.Name, strconv.Itoa(r.N), "", "", ""}
+		if r.N > 0 {
+			nsPerOp, ok := r.Extra["ns/op"]
+			if !ok {
+				nsPerOp = float64(r.T.Nanoseconds()) / float64(r.N)
+			}
+			row[2] = strconv.FormatFloat(nsPerOp, 'f', -1, 64)
+			row[3] = strconv.FormatInt(r.AllocedBytesPerOp(), 10)
+			row[4] = strconv.FormatInt(r.AllocsPerOp(), 10)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// SeriesStats summarizes a series of measurements
+type SeriesStats struct {
+	Min    float64
+	Median float64
+	Mean   float64
+	Stddev float64 // sample standard deviation; 0 for a single measurement
+}
+
+// BenchmarkSummary summarizes the runs of a benchmark
+type BenchmarkSummary struct {
+	Name        string
+	Runs        int
+	NsPerOp     SeriesStats
+	AllocsPerOp SeriesStats
+}
+
+// AggregateBenchmark summarizes the ns/op and allocs/op of results, which
+// must all be from the same benchmark
+func AggregateBenchmark(results []NamedBenchmarkResult) (BenchmarkSummary, error) {
+	if len(results) == 0 {
+		return BenchmarkSummary{}, fmt.Errorf("no benchmark results to aggregate")
+	}
+
+	name := results[0].Name
+	nsPerOp := make([]float64, len(results))
+	allocsPerOp := make([]float64, len(results))
+	for i, res := range results {
+		if res.Name != name {
+			return BenchmarkSummary{}, fmt.Errorf("cannot aggregate results of different benchmarks: %s and %s", name, res.Name)
+		}
+		nsPerOp[i] = float64(res.Result.NsPerOp())
+		allocsPerOp[i] = float64(res.Result.AllocsPerOp())
+	}
+
+	return BenchmarkSummary{
+		Name:        name,
+		Runs:        len(results),
+		NsPerOp:     seriesStats(nsPerOp),
+		AllocsPerOp: seriesStats(allocsPerOp),
+	}, nil
+}
+
+// seriesStats computes the statistics of values (not empty); values is sorted in place
+func seriesStats(values []float64) SeriesStats {
+	sort.Float64s(values)
+	n := len(values)
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(n)
+
+	median := values[n/2]
+	if n%2 == 0 {
+		median = (values[n/2-1] + values[n/2]) / 2
+	}
+
+	var stddev float64
+	if n > 1 {
+		var squares float64
+		for _, v := range values {
+			squares += (v - mean) * (v - mean)
+		}
+		stddev = math.Sqrt(squares / float64(n-1))
+	}
+
+	return SeriesStats{
+		Min:    values[0],
+		Median: median,
+		Mean:   mean,
+		Stddev: stddev,
+	}
+}
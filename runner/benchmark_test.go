@@ -0,0 +1,364 @@
+package runner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// benchResult returns a result of 1000 iterations at nsPerOp and allocsPerOp
+func benchResult(nsPerOp int64, allocsPerOp uint64) NamedBenchmarkResult {
+	return NamedBenchmarkResult{
+		Name: "BenchmarkParse",
+		Result: testing.BenchmarkResult{
+			N:         1000,
+			T:         time.Duration(nsPerOp * 1000),
+			MemAllocs: allocsPerOp * 1000,
+		},
+	}
+}
+
+func Test_AggregateBenchmark_ShouldComputeStatistics(t *testing.T) {
+	// Arrange
+	results := []NamedBenchmarkResult{
+		benchResult(120, 3),
+		benchResult(100, 2),
+		benchResult(140, 2),
+		benchResult(110, 5),
+	}
+
+	// Act
+	summary, err := AggregateBenchmark(results)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "BenchmarkParse", summary.Name)
+	assert.Equal(t, 4, summary.Runs)
+	assert.Equal(t, 100.0, summary.NsPerOp.Min)
+	assert.Equal(t, 115.0, summary.NsPerOp.Median)
+	assert.Equal(t, 117.5, summary.NsPerOp.Mean)
+	assert.InDelta(t, 17.078, summary.NsPerOp.Stddev, 0.001)
+	assert.Equal(t, 2.0, summary.AllocsPerOp.Min)
+	assert.Equal(t, 2.5, summary.AllocsPerOp.Median)
+	assert.InDelta(t, 1.414, summary.AllocsPerOp.Stddev, 0.001)
+}
+
+func Test_AggregateBenchmark_ShouldHaveNoStddevForSingleRun(t *testing.T) {
+	// Act
+	summary, err := AggregateBenchmark([]NamedBenchmarkResult{benchResult(100, 1)})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, summary.NsPerOp.Median)
+	assert.Equal(t, 0.0, summary.NsPerOp.Stddev)
+}
+
+func Test_AggregateBenchmark_ShouldRejectMixedOrEmptyResults(t *testing.T) {
+	// Arrange
+	other := benchResult(100, 1)
+	other.Name = "BenchmarkFormat"
+
+	// Act
+	_, errMixed := AggregateBenchmark([]NamedBenchmarkResult{benchResult(100, 1), other})
+	_, errEmpty := AggregateBenchmark(nil)
+
+	// Assert
+	assert.Error(t, errMixed)
+	assert.Error(t, errEmpty)
+}
+
+func Test_WriteBenchmarkCSV_ShouldWriteRowPerResult(t *testing.T) {
+	// Arrange
+	results := []NamedBenchmarkResult{
+		{Name: "BenchmarkParse/small", Result: testing.BenchmarkResult{
+			N: 4, T: 10 * time.Nanosecond, MemAllocs: 8, MemBytes: 512,
+		}},
+		{Name: "BenchmarkParse/skipped"},
+	}
+	var buf bytes.Buffer
+
+	// Act
+	err := WriteBenchmarkCSV(&buf, results)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "name,N,ns/op,B/op,allocs/op\n"+
+		"BenchmarkParse/small,4,2.5,128,2\n"+
+		"BenchmarkParse/skipped,0,,,\n", buf.String())
+}
+
+func Test_RunBenchmark_ShouldRunIterationCountExactly(t *testing.T) {
+	// Arrange
+	var calls []int // the b.N of each call
+	iterations := 0
+
+	// Act
+	result, err := RunBenchmark("BenchmarkCount", "50x", func(b *testing.B) {
+		calls = append(calls, b.N)
+		for i := 0; i < b.N; i++ {
+			iterations++
+		}
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 50}, calls) // the first call probes with b.N = 1, like go test
+	assert.Equal(t, 51, iterations)
+	assert.Equal(t, 50, result.Result.N)
+	assert.Equal(t, "BenchmarkCount", result.Name)
+}
+
+func Test_RunBenchmark_ShouldRunForDuration(t *testing.T) {
+	// Act
+	result, err := RunBenchmark("BenchmarkSleep", "100ms", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, result.Result.T, 100*time.Millisecond)
+	assert.Greater(t, result.Result.N, 1)
+}
+
+func Test_ParseBenchTime_ShouldParseBothForms(t *testing.T) {
+	cases := map[string]struct {
+		in      string
+		d       time.Duration
+		n       int
+		wantErr bool
+	}{
+		"Duration":        {in: "2s", d: 2 * time.Second},
+		"IterationCount":  {in: "100x", n: 100},
+		"ZeroCount":       {in: "0x", wantErr: true},
+		"NegativeCount":   {in: "-5x", wantErr: true},
+		"FractionalCount": {in: "1.5x", wantErr: true},
+		"MissingCount":    {in: "x", wantErr: true},
+		"ZeroDuration":    {in: "0s", wantErr: true},
+		"NeitherForm":     {in: "fast", wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Act
+			d, n, err := ParseBenchTime(tc.in)
+
+			// Assert
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.d, d)
+			assert.Equal(t, tc.n, n)
+		})
+	}
+}
+
+func Test_RunBenchmark_ShouldRejectInvalidBenchTime(t *testing.T) {
+	// Act
+	_, err := RunBenchmark("BenchmarkBad", "10y", func(b *testing.B) { t.Fatal("must not run") })
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func Test_RunBenchmarkConfig_ShouldPinAndRestoreGOMAXPROCS(t *testing.T) {
+	// Arrange
+	before := runtime.GOMAXPROCS(0)
+	want := 3
+	if before == want {
+		want = 2
+	}
+	var during int
+
+	// Act
+	result, err := RunBenchmarkConfig("BenchmarkProcs", BenchConfig{BenchTime: "1x", GOMAXPROCS: want}, func(b *testing.B) {
+		during = runtime.GOMAXPROCS(0)
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, want, during)
+	assert.Equal(t, before, runtime.GOMAXPROCS(0))
+	assert.Equal(t, fmt.Sprintf("BenchmarkProcs-%d", want), result.Name)
+}
+
+func Test_RunBenchmarkConfig_ShouldNotSuffixSingleProc(t *testing.T) {
+	// Act
+	result, err := RunBenchmarkConfig("BenchmarkProcs", BenchConfig{BenchTime: "1x", GOMAXPROCS: 1}, func(b *testing.B) {})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "BenchmarkProcs", result.Name)
+}
+
+func Test_RunBenchmarkConfig_ShouldRejectNegativeGOMAXPROCS(t *testing.T) {
+	// Act
+	_, err := RunBenchmarkConfig("BenchmarkBad", BenchConfig{GOMAXPROCS: -1}, func(b *testing.B) { t.Fatal("must not run") })
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func Test_RunBenchmarkTreeConfig_ShouldSuffixSubBenchmarks(t *testing.T) {
+	// Act
+	results, err := RunBenchmarkTreeConfig("BenchmarkParse", BenchConfig{BenchTime: "1x", GOMAXPROCS: 2}, func(b *testing.B, tree *BenchmarkTree) {
+		tree.Run(b, "small", func(b *testing.B) {})
+	})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "BenchmarkParse-2", results[0].Name)
+	assert.Equal(t, "BenchmarkParse/small-2", results[1].Name)
+}
+
+func Test_RunBenchmarkConfig_ShouldLabelEachBenchmarkInCPUProfile(t *testing.T) {
+	// Arrange
+	var profile bytes.Buffer
+	if err := pprof.StartCPUProfile(&profile); err != nil {
+		t.Skipf("CPU profile already running: %v", err)
+	}
+	busy := func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i := 0; i < 1000; i++ {
+				_ = fmt.Sprint(i)
+			}
+		}
+	}
+	cfg := BenchConfig{BenchTime: "300ms", CPUProfilePerBenchmark: true}
+
+	// Act
+	_, errA := RunBenchmarkConfig("BenchmarkLabelA", cfg, busy)
+	_, errB := RunBenchmarkConfig("BenchmarkLabelB", cfg, busy)
+	pprof.StopCPUProfile()
+
+	// Assert
+	require.NoError(t, errA)
+	require.NoError(t, errB)
+	// the label keys and values are in the profile's string table
+	zr, err := gzip.NewReader(&profile)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "bench")
+	assert.Contains(t, string(data), "BenchmarkLabelA")
+	assert.Contains(t, string(data), "BenchmarkLabelB")
+}
+
+func Test_RunBenchmarkTree_ShouldRecordSubBenchmarksByFullPath(t *testing.T) {
+	// Arrange
+	loop := func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+		}
+	}
+
+	// Act
+	results, err := RunBenchmarkTree("BenchmarkParse", "20x", func(b *testing.B, tree *BenchmarkTree) {
+		tree.Run(b, "small", func(b *testing.B) {
+			tree.Run(b, "json", loop)
+			tree.Run(b, "yaml", loop)
+		})
+		tree.Run(b, "large", loop)
+	})
+
+	// Assert
+	require.NoError(t, err)
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Name)
+	}
+	assert.Equal(t, []string{
+		"BenchmarkParse",
+		"BenchmarkParse/small",
+		"BenchmarkParse/small/json",
+		"BenchmarkParse/small/yaml",
+		"BenchmarkParse/large",
+	}, names)
+	for _, result := range results[1:] {
+		if result.Name == "BenchmarkParse/small" {
+			continue // only runs its sub-benchmarks
+		}
+		assert.Equal(t, 20, result.Result.N, result.Name)
+	}
+}
+
+func Test_BenchmarkTree_ShouldTimeSubBenchmarks(t *testing.T) {
+	// Act
+	results, err := RunBenchmarkTree("BenchmarkSleep", "5x", func(b *testing.B, tree *BenchmarkTree) {
+		tree.Run(b, "sub", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				time.Sleep(time.Millisecond)
+			}
+		})
+	})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, 5, results[1].Result.N)
+	assert.GreaterOrEqual(t, results[1].Result.T, 5*time.Millisecond)
+}
+
+func Test_RunBenchmark_ShouldKeepReportedMetrics(t *testing.T) {
+	// Act
+	result, err := RunBenchmark("BenchmarkThings", "10x", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+		}
+		b.ReportMetric(42, "things/op")
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 42.0, result.Result.Extra["things/op"])
+}
+
+func Test_BenchmarkTree_ShouldRecordMetricsOfSubBenchmarks(t *testing.T) {
+	// Act
+	results, err := RunBenchmarkTree("BenchmarkThings", "10x", func(b *testing.B, tree *BenchmarkTree) {
+		tree.Run(b, "sub", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+			}
+			tree.ReportMetric(b, float64(b.N), "calls/op")
+			tree.ReportMetric(b, 42, "things/op")
+		})
+		tree.Run(b, "plain", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+			}
+		})
+	})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "BenchmarkThings/sub", results[1].Name)
+	assert.Equal(t, map[string]float64{"calls/op": 10, "things/op": 42}, results[1].Result.Extra)
+	assert.Empty(t, results[2].Result.Extra)
+}
+
+func Test_GroupBenchmarkResults_ShouldGroupByFullName(t *testing.T) {
+	// Arrange
+	results := []NamedBenchmarkResult{
+		{Name: "BenchmarkParse/json", Result: testing.BenchmarkResult{N: 1}},
+		{Name: "BenchmarkParse/yaml", Result: testing.BenchmarkResult{N: 2}},
+		{Name: "BenchmarkParse/json", Result: testing.BenchmarkResult{N: 3}},
+	}
+
+	// Act
+	groups := GroupBenchmarkResults(results)
+
+	// Assert
+	assert.Equal(t, map[string][]NamedBenchmarkResult{
+		"BenchmarkParse/json": {results[0], results[2]},
+		"BenchmarkParse/yaml": {results[1]},
+	}, groups)
+}
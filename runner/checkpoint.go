@@ -0,0 +1,179 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+checkpoint.go: Resuming an interrupted Run. Each top-level test that finishes is appended to the checkpoint as a line of
+JSON, so a Run that is killed part way leaves at most its last line partial. A checkpoint that can't be read is
+discarded with a reset line, as an io.ReadWriter can't be truncated.
+*/
+
+// checkpointEntry is a line of the checkpoint
+type checkpointEntry struct {
+	Test   string                 `json:"test,omitempty"` // top-level test name
+	Failed bool                   `json:"failed,omitempty"`
+	Stats  []constants.Statistics `json:"stats,omitempty"` // added while it ran, including its subtests
+	Reset  bool                   `json:"reset,omitempty"` // the lines before are discarded
+}
+
+// SetCheckpoint makes Run record each top-level test that finishes, with its
+// outcome and statistics, to rw as it goes. When a Run starts it first reads
+// rw: the tests recorded there are not run again but their recorded
+// statistics are added, as if they had run, and only the remaining tests
+// run. So after an interrupted Run, a Run with the same checkpoint (e.g. the
+// reopened file) resumes where it stopped. A checkpoint that can't be read,
+// e.g. with a line left partial by the interrupt, is discarded with a
+// warning and the Run starts fresh. Nil turns it off.
+func (r *runner) SetCheckpoint(rw io.ReadWriter) {
+	r.checkpoint = rw
+}
+
+// loadCheckpoint reads the tests recorded in the checkpoint, by name
+func (r *runner) loadCheckpoint() map[string]checkpointEntry {
+	if r.checkpoint == nil {
+		return nil
+	}
+	data, err := io.ReadAll(r.checkpoint)
+	if err != nil {
+		r.addWarning(fmt.Sprintf("reading the checkpoint, starting fresh: %v", err))
+		r.resetCheckpoint()
+		return nil
+	}
+	entries, err := parseCheckpoint(data)
+	if err != nil {
+		r.addWarning(fmt.Sprintf("the checkpoint is corrupt, starting fresh: %v", err))
+		r.resetCheckpoint()
+		return nil
+	}
+	return entries
+}
+
+// parseCheckpoint returns the entries of the checkpoint data after its last
+// reset, the last one of each test winning
+func parseCheckpoint(data []byte) (map[string]checkpointEntry, error) {
+	entries := make(map[string]checkpointEntry)
+	var corrupt error
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e checkpointEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			corrupt = fmt.Errorf("line %d: %v", i+1, err)
+			continue
+		}
+		switch {
+		case e.Reset:
+			entries = make(map[string]checkpointEntry)
+			corrupt = nil
+		case e.Test == "":
+			corrupt = fmt.Errorf("line %d: no test name", i+1)
+		default:
+			entries[e.Test] = e
+		}
+	}
+	if corrupt != nil {
+		return nil, corrupt
+	}
+	return entries, nil
+}
+
+// resetCheckpoint discards what is in the checkpoint, on a line of its own in
+// case the last line is partial
+func (r *runner) resetCheckpoint() {
+	r.writeCheckpoint(checkpointEntry{Reset: true}, "\n")
+}
+
+// writeCheckpoint appends e to the checkpoint as a line after prefix
+func (r *runner) writeCheckpoint(e checkpointEntry, prefix string) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		r.addWarning(fmt.Sprintf("writing the checkpoint: %v", err))
+		return
+	}
+	r.mu.Lock()
+	_, err = r.checkpoint.Write([]byte(prefix + string(line) + "\n"))
+	r.mu.Unlock()
+	if err != nil {
+		r.addWarning(fmt.Sprintf("writing the checkpoint: %v", err))
+	}
+}
+
+// resumeTests returns the tests that are not in the checkpoint, adding the
+// statistics recorded for the others, and makes the returned ones record
+// themselves in the checkpoint when they finish
+func (r *runner) resumeTests(tests []testing.InternalTest) []testing.InternalTest {
+	if r.checkpoint == nil {
+		return tests
+	}
+	done := map[string]checkpointEntry{}
+	if r.retry == 0 { // a retry only runs tests that failed in this Run
+		done = r.loadCheckpoint()
+	}
+
+	var run []testing.InternalTest
+	var resumed []string
+	for _, test := range tests {
+		// the match workaround tags names, so use the actual name
+		_, _, name := MatchTag(test.Name)
+		if e, ok := done[name]; ok {
+			resumed = append(resumed, name)
+			r.addResumedStatistics(name, e)
+			continue
+		}
+		f := test.F
+		test.F = func(t *testing.T) {
+			r.mu.Lock()
+			first := len(r.stats)
+			r.mu.Unlock()
+			t.Cleanup(func() { r.checkpointTest(t, name, first) }) // after its subtests
+			f(t)
+		}
+		run = append(run, test)
+	}
+	if len(resumed) > 0 {
+		r.LogEvent(fmt.Sprintf("Resuming from the checkpoint, not running: %s", strings.Join(resumed, ", ")))
+	}
+	return run
+}
+
+// addResumedStatistics adds the statistics recorded for the test name, which
+// ran before the Run
+func (r *runner) addResumedStatistics(name string, e checkpointEntry) {
+	stats := e.Stats
+	if len(stats) == 0 && e.Failed {
+		stats = []constants.Statistics{{Name: name, Failed: true}}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range stats {
+		r.stats = append(r.stats, s)
+		if s.Failed {
+			r.failures++
+		}
+	}
+}
+
+// checkpointTest records the finished test name, whose statistics were added
+// from index first on
+func (r *runner) checkpointTest(t *testing.T, name string, first int) {
+	e := checkpointEntry{Test: name, Failed: t.Failed()}
+	r.mu.Lock()
+	for _, s := range r.stats[first:] {
+		if s.Name == name || strings.HasPrefix(s.Name, name+"/") {
+			e.Stats = append(e.Stats, s)
+			e.Failed = e.Failed || s.Failed
+		}
+	}
+	r.mu.Unlock()
+	r.writeCheckpoint(e, "")
+}
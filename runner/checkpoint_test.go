@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCheckpointedRunner returns a runner with tests that add their statistics
+// like the test harness, TestB failing, and the names of the tests that ran
+func newCheckpointedRunner(t *testing.T, checkpoint *bytes.Buffer) (*runner, *[]string) {
+	var r *runner
+	ran := new([]string)
+	test := func(name string, failed bool) testing.InternalTest {
+		return testing.InternalTest{Name: name, F: func(t *testing.T) {
+			*ran = append(*ran, name)
+			r.AddStatistics(&constants.Statistics{Name: name, Failed: failed})
+		}}
+	}
+	r = newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		test("TestA", false), test("TestB", true), test("TestC", false),
+	}, nil, nil, nil)).(*runner)
+	r.PrintToStdout(false)
+	t.Cleanup(func() { r.PrintToStdout(printStdout) })
+	r.SetCheckpoint(checkpoint)
+	return r, ran
+}
+
+func Test_SetCheckpoint_ShouldResumeInterruptedRun(t *testing.T) {
+	// Arrange
+	var checkpoint bytes.Buffer
+	interrupted, ran := newCheckpointedRunner(t, &checkpoint)
+	runIsolated(t)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		// interrupted before TestC
+		testing.RunTests(func(pat, str string) (bool, error) { return true, nil }, tests[:2])
+	}
+	interrupted.Run()
+	require.Equal(t, []string{"TestA", "TestB"}, *ran)
+
+	r, ran := newCheckpointedRunner(t, &checkpoint)
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, []string{"TestC"}, *ran)
+	require.Len(t, r.Statistics(), 3)
+	assert.Equal(t, "TestA", r.Statistics()[0].Name)
+	assert.False(t, r.Statistics()[0].Failed)
+	assert.Equal(t, "TestB", r.Statistics()[1].Name)
+	assert.True(t, r.Statistics()[1].Failed)
+	assert.Equal(t, "TestC", r.Statistics()[2].Name)
+	assert.False(t, r.Passed())
+	assert.Empty(t, r.Warnings())
+}
+
+func Test_SetCheckpoint_ShouldStartFreshFromCorruptCheckpoint(t *testing.T) {
+	// Arrange
+	checkpoint := bytes.NewBufferString("{\"test\":\"TestA\"}\n{\"test\":\"Te") // partial last line
+	r, ran := newCheckpointedRunner(t, checkpoint)
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, []string{"TestA", "TestB", "TestC"}, *ran)
+	require.Len(t, r.Warnings(), 1)
+	assert.Contains(t, r.Warnings()[0], "the checkpoint is corrupt, starting fresh: line 2")
+	entries, err := parseCheckpoint(checkpoint.Bytes())
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func Test_ParseCheckpoint_ShouldKeepLastEntryAfterReset(t *testing.T) {
+	// Arrange
+	data := []byte("{\"test\":\"TestA\",\"failed\":true}\ngarbage\n{\"reset\":true}\n" +
+		"{\"test\":\"TestB\",\"failed\":true}\n\n{\"test\":\"TestB\"}\n")
+
+	// Act
+	entries, err := parseCheckpoint(data)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, map[string]checkpointEntry{"TestB": {Test: "TestB"}}, entries)
+}
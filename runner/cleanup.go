@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"flag"
+	"fmt"
+	"runtime/trace"
+	"sync"
+)
+
+/*
+cleanup.go: Releasing what a Run started for its m.Run on every way out of it.
+
+testing starts the CPU profile, the trace and the test log and turns on the os.Exit(0) hook at the start of m.Run and
+releases them at its end, but not if m.Run panics (e.g. a test's panic that the harness doesn't recover). The runner's own
+setup (the -test.* flags, the test log of SetTestLogWriter) has to be undone either way. A run that times out can't be
+cleaned up: testing's alarm panics on a goroutine of its own, which ends the process.
+*/
+
+// runState is the state of one m.Run of a Run
+type runState struct {
+	r        *runner
+	restores []func() // undo the runner's setup, run last first
+	finished bool     // m.Run returned, so testing released what it started
+	once     sync.Once
+}
+
+// add registers a func undoing part of the runner's setup
+func (s *runState) add(restore func()) {
+	s.restores = append(s.restores, restore)
+}
+
+// cleanup releases what the m.Run started and undoes the runner's setup. It
+// is safe to call more than once; only the first call does anything.
+func (s *runState) cleanup() {
+	s.once.Do(func() {
+		if !s.finished {
+			deps := s.r.deps
+			deps.StopCPUProfile() // a no-op if it isn't running
+			if f := flag.Lookup("test.trace"); f != nil && f.Value.String() != "" && trace.IsEnabled() {
+				trace.Stop()
+			}
+			if err := deps.StopTestLog(); err != nil {
+				s.r.addWarning(fmt.Sprintf("writing the test log: %v", err))
+			}
+			deps.SetPanicOnExit0(false)
+		}
+		for i := len(s.restores) - 1; i >= 0; i-- {
+			s.restores[i]()
+		}
+	})
+}
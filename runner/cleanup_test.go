@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runPanicking runs r with a runnerMainStart that calls start with the deps
+// like testing does at the start of m.Run and then panics, and returns the
+// recovered panic
+func runPanicking(t *testing.T, r *runner, start func(deps testDeps)) (recovered any) {
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		start(deps)
+		panic("test panicked")
+	}
+	defer func() { recovered = recover() }()
+	r.Run()
+	return nil
+}
+
+func Test_Run_ShouldReleaseDepsWhenMRunPanics(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestPanics")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	deps := &fakeDeps{}
+	r.deps = deps
+	stdout := os.Stdout
+
+	// Act
+	recovered := runPanicking(t, r, func(deps testDeps) {
+		deps.SetPanicOnExit0(true)
+		deps.StartCPUProfile(io.Discard)
+		deps.StartTestLog(io.Discard)
+	})
+
+	// Assert
+	assert.Equal(t, "test panicked", recovered)
+	assert.Equal(t, []string{
+		"SetPanicOnExit0(true)",
+		"StartCPUProfile",
+		"StartTestLog",
+		"StopCPUProfile",
+		"StopTestLog",
+		"SetPanicOnExit0(false)",
+	}, deps.Calls())
+	assert.Equal(t, stdout, os.Stdout, "stdout is restored")
+}
+
+func Test_Run_ShouldAllowNewProfileAndTestLogAfterPanic(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestPanics")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	var logged bytes.Buffer
+
+	// Act
+	recovered := runPanicking(t, r, func(deps testDeps) {
+		require.NoError(t, deps.StartCPUProfile(io.Discard))
+		deps.StartTestLog(&logged)
+		Open("testdata/before-panic.txt")
+	})
+
+	// Assert
+	assert.Equal(t, "test panicked", recovered)
+	assert.Contains(t, logged.String(), "open testdata/before-panic.txt\n", "flushed")
+	deps := TestDeps{}
+	require.NoError(t, deps.StartCPUProfile(io.Discard), "the profile was stopped")
+	deps.StopCPUProfile()
+	var fresh bytes.Buffer
+	deps.StartTestLog(&fresh)
+	Open("testdata/after-panic.txt")
+	require.NoError(t, deps.StopTestLog())
+	assert.Contains(t, fresh.String(), "open testdata/after-panic.txt\n")
+	assert.NoError(t, deps.StopTestLog(), "stopping twice is a no-op")
+}
+
+func Test_RunState_ShouldCleanUpOnce(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	deps := &fakeDeps{}
+	r.deps = deps
+	restores := 0
+	state := &runState{r: r}
+	state.add(func() { restores++ })
+
+	// Act
+	state.cleanup()
+	state.cleanup()
+
+	// Assert
+	assert.Equal(t, 1, restores)
+	assert.Equal(t, []string{"StopCPUProfile", "StopTestLog", "SetPanicOnExit0(false)"}, deps.Calls())
+}
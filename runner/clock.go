@@ -0,0 +1,42 @@
+package runner
+
+import "time"
+
+/*
+clock.go: The time source of the runner's own timing (run timestamps, retry backoff, the per-test watchdog, the CPU
+profile limit, the goroutine sampler and the periodic flush of the test log), replaceable for testing. Timeouts are
+enforced by the testing package itself and don't use it, nor does the DashboardReporter, which has no runner.
+*/
+
+// clock tells the time, waits and starts timers and tickers
+type clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	// AfterFunc calls f on a goroutine of its own after d, unless stop is
+	// called first
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+	// NewTicker sends the time on c every d until stop is called
+	NewTicker(d time.Duration) (c <-chan time.Time, stop func())
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+func (realClock) AfterFunc(d time.Duration, f func()) (stop func() bool) {
+	return time.AfterFunc(d, f).Stop
+}
+
+func (realClock) NewTicker(d time.Duration) (c <-chan time.Time, stop func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// setClock replaces the clock of the runner and of its TestDeps, for testing
+func (r *runner) setClock(c clock) {
+	r.clock = c
+	if deps, ok := r.deps.(*TestDeps); ok {
+		deps.clock = c
+	}
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+/*
+context.go: Per-test contexts that carry the run's deadline and the values set with SetContextValues
+*/
+
+// testContexts holds the context of each running test, keyed by test name
+var testContexts sync.Map
+
+// Context returns the context for the running test. The context has the same
+// deadline as t.Deadline() (the run's timeout, see SetRunTimeout), carries the
+// runner's context values and is cancelled when the test finishes. Repeated
+// calls from the same test return the same context.
+func Context(t *testing.T) context.Context {
+	if ctx, ok := testContexts.Load(t.Name()); ok {
+		return ctx.(context.Context)
+	}
+
+	ctx := context.Background()
+	if r, ok := instance.(*runner); ok {
+		for key, value := range r.ctxValues {
+			ctx = context.WithValue(ctx, key, value)
+		}
+	}
+	cancel := context.CancelFunc(func() {})
+	if deadline, ok := t.Deadline(); ok {
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+	}
+
+	actual, loaded := testContexts.LoadOrStore(t.Name(), ctx)
+	if loaded {
+		cancel()
+		return actual.(context.Context)
+	}
+	t.Cleanup(func() {
+		testContexts.Delete(t.Name())
+		cancel()
+	})
+	return ctx
+}
+
+// SetContextValues sets values that the Context of every test carries, e.g.
+// shared fixtures such as a DB handle, so tests can get them with ctx.Value
+// instead of using globals. As with context.WithValue, use a private key type
+// to avoid collisions with other packages. The values are kept in memory only
+// and are not part of WireConfig.
+func (r *runner) SetContextValues(values map[any]any) error {
+	copied := make(map[any]any, len(values))
+	for key, value := range values {
+		if key == nil {
+			return fmt.Errorf("context value key must not be nil")
+		}
+		copied[key] = value
+	}
+	r.ctxValues = copied
+	return nil
+}
@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Context_ShouldCarryTestDeadline(t *testing.T) {
+	// Arrange
+	wantDeadline, ok := t.Deadline()
+	require.True(t, ok, "go test sets a default timeout")
+
+	// Act
+	ctx := Context(t)
+
+	// Assert
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.False(t, deadline.IsZero())
+	assert.Equal(t, wantDeadline, deadline)
+	assert.Equal(t, ctx, Context(t))
+	assert.NoError(t, ctx.Err())
+}
+
+func Test_Context_ShouldBeCancelledAfterTest(t *testing.T) {
+	// Arrange
+	var ctx interface{ Err() error }
+
+	// Act
+	t.Run("sub", func(t *testing.T) {
+		ctx = Context(t)
+	})
+
+	// Assert
+	assert.Error(t, ctx.Err())
+}
+
+type fixtureKey struct{}
+
+func Test_Context_ShouldCarryContextValues(t *testing.T) {
+	// Arrange
+	defer func(prev Runner) { instance = prev }(instance)
+	m := testing.MainStart(&TestDeps{}, []testing.InternalTest{{Name: "TestUsesFixture", F: func(t *testing.T) {}}}, nil, nil, nil)
+	r := newInstance(m)
+	instance = r
+	require.NoError(t, r.SetContextValues(map[any]any{fixtureKey{}: "db handle"}))
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	var got any
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for _, test := range tests {
+			_, _, name := MatchTag(test.Name)
+			t.Run(name, func(t *testing.T) {
+				got = Context(t).Value(fixtureKey{})
+			})
+		}
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, "db handle", got)
+}
+
+func Test_SetContextValues_ShouldRejectNilKey(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+
+	// Act
+	err := r.SetContextValues(map[any]any{nil: "value"})
+
+	// Assert
+	assert.Error(t, err)
+}
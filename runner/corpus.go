@@ -0,0 +1,188 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+/*
+corpus.go: Generating seed corpus entries, and import and export of corpus entries as JSON Lines, an interop path alongside the native "go test fuzz v1" files.
+Each line is an object such as {"path":"seed1","values":["abc",42,true]} with one value per argument of the fuzz target.
+[]byte values are base64 strings (as encoding/json writes them) and numbers are converted to the declared type.
+*/
+
+// GenerateSeedCorpus returns n seed entries with the values gen returns for
+// 0 to n-1, named "seed#0", "seed#1", ... like the entries added with f.Add.
+// See GenerateTypedSeedCorpus to check the values against the fuzz target.
+func GenerateSeedCorpus(n int, gen func(i int) []any) []corpusEntry {
+	entries, _ := GenerateTypedSeedCorpus(n, gen, nil) // can't fail without types
+	return entries
+}
+
+// GenerateTypedSeedCorpus is GenerateSeedCorpus with the values of each entry
+// checked against types (the fuzz target's arguments) with CheckCorpus. Nil
+// types skips the check.
+func GenerateTypedSeedCorpus(n int, gen func(i int) []any, types []reflect.Type) ([]corpusEntry, error) {
+	entries := make([]corpusEntry, 0, n)
+	for i := 0; i < n; i++ {
+		vals := gen(i)
+		if types != nil {
+			if err := (TestDeps{}).CheckCorpus(vals, types); err != nil {
+				return nil, fmt.Errorf("seed %d: %v", i, err)
+			}
+		}
+		entries = append(entries, corpusEntry{Path: fmt.Sprintf("seed#%d", i), Values: vals, IsSeed: true})
+	}
+	return entries, nil
+}
+
+// corpusLine is one line of a JSON Lines corpus
+type corpusLine struct {
+	Path   string            `json:"path,omitempty"`
+	Values []json.RawMessage `json:"values"`
+}
+
+// ReadCorpusJSONL reads corpus entries from JSON Lines, converting the values
+// of each entry to types and checking them with CheckCorpus. Blank lines are
+// ignored.
+func ReadCorpusJSONL(r io.Reader, types []reflect.Type) ([]corpusEntry, error) {
+	var entries []corpusEntry
+	br := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			entry, perr := parseCorpusLine(line, types)
+			if perr != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, perr)
+			}
+			entries = append(entries, entry)
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+	}
+}
+
+func parseCorpusLine(line []byte, types []reflect.Type) (corpusEntry, error) {
+	var cl corpusLine
+	if err := json.Unmarshal(line, &cl); err != nil {
+		return corpusEntry{}, err
+	}
+	if len(cl.Values) != len(types) {
+		return corpusEntry{}, fmt.Errorf("wrong number of values in corpus entry: %d, want %d", len(cl.Values), len(types))
+	}
+	vals := make([]any, len(types))
+	for i, raw := range cl.Values {
+		v, err := corpusValue(raw, types[i])
+		if err != nil {
+			return corpusEntry{}, fmt.Errorf("value %d: %v", i, err)
+		}
+		vals[i] = v
+	}
+	if err := (TestDeps{}).CheckCorpus(vals, types); err != nil {
+		return corpusEntry{}, err
+	}
+	return corpusEntry{Path: cl.Path, Values: vals}, nil
+}
+
+// corpusValue converts a JSON value to a value of type t, one of the types a
+// fuzz target accepts
+func corpusValue(raw json.RawMessage, t reflect.Type) (any, error) {
+	if t == reflect.TypeOf([]byte(nil)) {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(s)
+	}
+
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(integral(raw), 10, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("not a %v: %s", t, raw)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(integral(raw), 10, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("not a %v: %s", t, raw)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f json.Number
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseFloat(f.String(), t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("not a %v: %s", t, raw)
+		}
+		v.SetFloat(n)
+	default:
+		return nil, fmt.Errorf("unsupported corpus type %v", t)
+	}
+	return v.Interface(), nil
+}
+
+// integral returns a JSON number written with a fraction or exponent, such as
+// 42.0 or 4.2e1, in integer form if it has no fractional part, so that it can
+// be converted to an integer type. Anything else is returned as is.
+func integral(raw json.RawMessage) string {
+	s := string(bytes.TrimSpace(raw))
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || !bytes.ContainsAny(raw, ".eE") {
+		return s
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// WriteCorpusJSONL writes entries as JSON Lines, to be read back with
+// ReadCorpusJSONL. NaN and infinite floats can't be written since JSON has no
+// numbers for them.
+func WriteCorpusJSONL(w io.Writer, entries []corpusEntry) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i, entry := range entries {
+		cl := corpusLine{Path: entry.Path, Values: make([]json.RawMessage, len(entry.Values))}
+		for j, v := range entry.Values {
+			if f := reflect.ValueOf(v); f.Kind() == reflect.Float32 || f.Kind() == reflect.Float64 {
+				if math.IsNaN(f.Float()) || math.IsInf(f.Float(), 0) {
+					return fmt.Errorf("entry %d: value %d: %v can't be written as JSON", i, j, v)
+				}
+			}
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("entry %d: value %d: %v", i, j, err)
+			}
+			cl.Values[j] = raw
+		}
+		if err := enc.Encode(cl); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GenerateSeedCorpus_ShouldMarkEntriesAsSeeds(t *testing.T) {
+	// Act
+	entries := GenerateSeedCorpus(3, func(i int) []any { return []any{i, strings.Repeat("a", i)} })
+
+	// Assert
+	require.Equal(t, 3, len(entries))
+	for i, entry := range entries {
+		assert.True(t, entry.IsSeed)
+		assert.Equal(t, fmt.Sprintf("seed#%d", i), entry.Path)
+		assert.Equal(t, []any{i, strings.Repeat("a", i)}, entry.Values)
+	}
+}
+
+func Test_GenerateTypedSeedCorpus_ShouldRejectMismatchedValues(t *testing.T) {
+	// Arrange
+	types := []reflect.Type{reflect.TypeOf(0)}
+	gen := func(i int) []any {
+		if i == 2 {
+			return []any{"two"}
+		}
+		return []any{i}
+	}
+
+	// Act
+	entries, err := GenerateTypedSeedCorpus(3, gen, types)
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "seed 2:")
+	assert.Nil(t, entries)
+}
+
+func Test_CorpusJSONL_ShouldRoundTrip(t *testing.T) {
+	// Arrange
+	types := []reflect.Type{
+		reflect.TypeOf(""), reflect.TypeOf([]byte(nil)), reflect.TypeOf(int8(0)),
+		reflect.TypeOf(uint64(0)), reflect.TypeOf(float32(0)), reflect.TypeOf(false), reflect.TypeOf('x'),
+	}
+	entries := []corpusEntry{
+		{Path: "seed1", Values: []any{"hello", []byte{0, 1, 2}, int8(-128), uint64(math.MaxUint64), float32(1.5), true, 'é'}},
+		{Path: "seed2", Values: []any{"", []byte{}, int8(127), uint64(0), float32(-0.25), false, rune(0)}},
+	}
+	var buf bytes.Buffer
+
+	// Act
+	require.NoError(t, WriteCorpusJSONL(&buf, entries))
+	read, err := ReadCorpusJSONL(&buf, types)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, entries, read)
+}
+
+func Test_ReadCorpusJSONL_ShouldCoerceNumbers(t *testing.T) {
+	// Arrange
+	input := `{"values":[42,2.5e1,7]}
+
+{"path":"big","values":[-1,3,1e2]}
+`
+	types := []reflect.Type{reflect.TypeOf(int64(0)), reflect.TypeOf(uint16(0)), reflect.TypeOf(float64(0))}
+
+	// Act
+	entries, err := ReadCorpusJSONL(strings.NewReader(input), types)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	assert.Equal(t, []any{int64(42), uint16(25), float64(7)}, entries[0].Values)
+	assert.Equal(t, "big", entries[1].Path)
+	assert.Equal(t, []any{int64(-1), uint16(3), float64(100)}, entries[1].Values)
+}
+
+func Test_ReadCorpusJSONL_ShouldRejectMismatchedValues(t *testing.T) {
+	types := []reflect.Type{reflect.TypeOf(uint8(0)), reflect.TypeOf("")}
+	for name, input := range map[string]string{
+		"overflow":       `{"values":[256,"a"]}`,
+		"fraction":       `{"values":[1.5,"a"]}`,
+		"wrong type":     `{"values":[1,2]}`,
+		"too few values": `{"values":[1]}`,
+		"not json":       `{"values":`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Act
+			_, err := ReadCorpusJSONL(strings.NewReader(`{"values":[1,"ok"]}`+"\n"+input), types)
+
+			// Assert
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "line 2:")
+		})
+	}
+}
+
+func Test_WriteCorpusJSONL_ShouldRejectNaN(t *testing.T) {
+	// Act
+	err := WriteCorpusJSONL(&bytes.Buffer{}, []corpusEntry{{Values: []any{math.NaN()}}})
+
+	// Assert
+	assert.Error(t, err)
+}
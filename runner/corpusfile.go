@@ -0,0 +1,342 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+corpusfile.go: Reading corpus files in the "go test fuzz v1" format written by go test -fuzz (and MarshalCorpusEntry),
+for TestDeps.ReadCorpus and for replaying a single entry
+*/
+
+const corpusFileHeader = "go test fuzz v1"
+
+// ReplayCorpusEntry reads the corpus file at path, checks its values against
+// types (the fuzz target's arguments) and calls target with it, returning the
+// target's error. It is the embedded equivalent of go test -run=FuzzX/name.
+func ReplayCorpusEntry(path string, types []reflect.Type, target func(corpusEntry) error) error {
+	entry, err := readCorpusFile(path, types, false)
+	if err != nil {
+		return err
+	}
+	return target(entry)
+}
+
+// readCorpusDir reads the corpus files in dir like go test does; a missing
+// dir is an empty corpus. See readCorpusFile for coerce.
+func readCorpusDir(dir string, types []reflect.Type, coerce bool) ([]corpusEntry, error) {
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []corpusEntry
+	var problems []string
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		entry, err := readCorpusFile(filepath.Join(dir, file.Name()), types, coerce)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(problems) > 0 {
+		return entries, errors.New(strings.Join(problems, "; "))
+	}
+	return entries, nil
+}
+
+// readCorpusFile reads and checks the entry in the corpus file at path. With
+// coerce, its numeric values are converted to types first, see
+// coerceCorpusValues.
+func readCorpusFile(path string, types []reflect.Type, coerce bool) (corpusEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return corpusEntry{}, err
+	}
+	vals, err := UnmarshalCorpusEntry(data)
+	if err != nil {
+		return corpusEntry{}, fmt.Errorf("failed to unmarshal %q: %v", path, err)
+	}
+	if coerce {
+		if vals, err = coerceCorpusValues(vals, types); err != nil {
+			return corpusEntry{}, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := (TestDeps{}).CheckCorpus(vals, types); err != nil {
+		return corpusEntry{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return corpusEntry{Path: path, Data: data, Values: vals}, nil
+}
+
+// coerceCorpusValues converts the numeric values of vals whose type is not
+// the one in types to it, e.g. an int to an int64 argument as written by an
+// older version of the fuzz target, if the conversion is lossless: an
+// integer that is in the range of the type, or a float with no fractional
+// part, or a value a float type represents exactly. A lossy conversion is an
+// error. Other values are left for CheckCorpus to check.
+func coerceCorpusValues(vals []any, types []reflect.Type) ([]any, error) {
+	if len(vals) != len(types) {
+		return vals, nil // CheckCorpus reports it
+	}
+	coerced := make([]any, len(vals))
+	for i, v := range vals {
+		c, err := coerceNumber(v, types[i])
+		if err != nil {
+			return nil, fmt.Errorf("corpus value %d: %v", i, err)
+		}
+		coerced[i] = c
+	}
+	return coerced, nil
+}
+
+// coerceNumber converts v to t if both are numeric and v is exactly
+// representable in t
+func coerceNumber(v any, t reflect.Type) (any, error) {
+	rv := reflect.ValueOf(v)
+	if v == nil || rv.Type() == t || numberKind(rv.Kind()) == "" || numberKind(t.Kind()) == "" {
+		return v, nil
+	}
+	lossy := fmt.Errorf("can't convert %T(%v) to %v without loss", v, v, t)
+
+	var b big.Float
+	switch numberKind(rv.Kind()) {
+	case "int":
+		b.SetInt64(rv.Int())
+	case "uint":
+		b.SetUint64(rv.Uint())
+	default:
+		f := rv.Float()
+		if math.IsNaN(f) {
+			if numberKind(t.Kind()) != "float" {
+				return nil, lossy
+			}
+			return rv.Convert(t).Interface(), nil
+		}
+		b.SetFloat64(f)
+	}
+
+	switch numberKind(t.Kind()) {
+	case "int":
+		i, acc := b.Int64()
+		if b.IsInf() || acc != big.Exact || reflect.Zero(t).OverflowInt(i) {
+			return nil, lossy
+		}
+		return reflect.ValueOf(i).Convert(t).Interface(), nil
+	case "uint":
+		u, acc := b.Uint64()
+		if b.IsInf() || acc != big.Exact || reflect.Zero(t).OverflowUint(u) {
+			return nil, lossy
+		}
+		return reflect.ValueOf(u).Convert(t).Interface(), nil
+	default:
+		if t.Kind() == reflect.Float32 {
+			f, acc := b.Float32()
+			if acc != big.Exact {
+				return nil, lossy
+			}
+			return reflect.ValueOf(f).Convert(t).Interface(), nil
+		}
+		f, acc := b.Float64()
+		if acc != big.Exact {
+			return nil, lossy
+		}
+		return reflect.ValueOf(f).Convert(t).Interface(), nil
+	}
+}
+
+// numberKind returns "int", "uint" or "float" for the numeric kinds, "" for
+// the others
+func numberKind(k reflect.Kind) string {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "uint"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	}
+	return ""
+}
+
+// UnmarshalCorpusEntry decodes the values of a corpus file in the
+// "go test fuzz v1" format
+func UnmarshalCorpusEntry(data []byte) ([]any, error) {
+	lines := bytes.Split(data, []byte("\n"))
+	if string(bytes.TrimSpace(lines[0])) != corpusFileHeader {
+		return nil, fmt.Errorf("unknown encoding version: %s", lines[0])
+	}
+
+	var vals []any
+	for i, line := range lines[1:] {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		v, err := parseCorpusValue(string(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+		vals = append(vals, v)
+	}
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("must include version and at least one value")
+	}
+	return vals, nil
+}
+
+// parseCorpusValue parses one value line such as int(42) or []byte("abc")
+func parseCorpusValue(line string) (any, error) {
+	expr, err := parser.ParseExpr(line)
+	if err != nil {
+		return nil, err
+	}
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, fmt.Errorf("expected a call expression with one argument")
+	}
+	arg := call.Args[0]
+
+	switch fun := call.Fun.(type) {
+	case *ast.ArrayType:
+		if elem, ok := fun.Elt.(*ast.Ident); !ok || fun.Len != nil || elem.Name != "byte" {
+			return nil, fmt.Errorf("expected []byte or a primitive type")
+		}
+		s, err := stringLiteral(arg)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	case *ast.SelectorExpr:
+		// math.Float64frombits(0x...) for NaNs with a payload
+		pkg, ok := fun.X.(*ast.Ident)
+		if !ok || pkg.Name != "math" || (fun.Sel.Name != "Float32frombits" && fun.Sel.Name != "Float64frombits") {
+			return nil, fmt.Errorf("expected math.Float32frombits or math.Float64frombits")
+		}
+		lit, ok := arg.(*ast.BasicLit)
+		if !ok || lit.Kind != token.INT {
+			return nil, fmt.Errorf("expected an integer literal")
+		}
+		if fun.Sel.Name == "Float32frombits" {
+			bits, err := strconv.ParseUint(lit.Value, 0, 32)
+			return math.Float32frombits(uint32(bits)), err
+		}
+		bits, err := strconv.ParseUint(lit.Value, 0, 64)
+		return math.Float64frombits(bits), err
+	case *ast.Ident:
+		return parsePrimitive(fun.Name, arg)
+	}
+	return nil, fmt.Errorf("expected []byte or a primitive type")
+}
+
+func parsePrimitive(typ string, arg ast.Expr) (any, error) {
+	switch typ {
+	case "string":
+		return stringLiteral(arg)
+	case "bool":
+		if id, ok := arg.(*ast.Ident); ok && (id.Name == "true" || id.Name == "false") {
+			return id.Name == "true", nil
+		}
+		return nil, fmt.Errorf("expected true or false")
+	}
+
+	// numbers, possibly negative; rune and byte values may be characters
+	var sign string
+	if unary, ok := arg.(*ast.UnaryExpr); ok && (unary.Op == token.SUB || unary.Op == token.ADD) {
+		sign, arg = unary.Op.String(), unary.X
+	}
+	if id, ok := arg.(*ast.Ident); ok && (typ == "float32" || typ == "float64") && (id.Name == "Inf" || id.Name == "NaN") {
+		f, _ := strconv.ParseFloat(sign+id.Name, 64)
+		if typ == "float32" {
+			return float32(f), nil
+		}
+		return f, nil
+	}
+	lit, ok := arg.(*ast.BasicLit)
+	if !ok {
+		return nil, fmt.Errorf("expected a literal")
+	}
+	if lit.Kind == token.CHAR && (typ == "rune" || typ == "int32" || typ == "byte" || typ == "uint8") && sign == "" {
+		r, _, _, err := strconv.UnquoteChar(lit.Value[1:len(lit.Value)-1], '\'')
+		if err != nil {
+			return nil, err
+		}
+		if typ == "byte" || typ == "uint8" {
+			if r > math.MaxUint8 {
+				return nil, fmt.Errorf("character %s out of range for byte", lit.Value)
+			}
+			return byte(r), nil
+		}
+		return r, nil
+	}
+	s := sign + lit.Value
+
+	switch typ {
+	case "int", "int8", "int16", "int32", "rune", "int64":
+		bits := map[string]int{"int": strconv.IntSize, "int8": 8, "int16": 16, "int32": 32, "rune": 32, "int64": 64}[typ]
+		n, err := strconv.ParseInt(s, 0, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case "int":
+			return int(n), nil
+		case "int8":
+			return int8(n), nil
+		case "int16":
+			return int16(n), nil
+		case "int32", "rune":
+			return int32(n), nil
+		}
+		return n, nil
+	case "uint", "uint8", "byte", "uint16", "uint32", "uint64":
+		bits := map[string]int{"uint": strconv.IntSize, "uint8": 8, "byte": 8, "uint16": 16, "uint32": 32, "uint64": 64}[typ]
+		n, err := strconv.ParseUint(s, 0, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case "uint":
+			return uint(n), nil
+		case "uint8", "byte":
+			return uint8(n), nil
+		case "uint16":
+			return uint16(n), nil
+		case "uint32":
+			return uint32(n), nil
+		}
+		return n, nil
+	case "float32":
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	case "float64":
+		return strconv.ParseFloat(s, 64)
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
+
+func stringLiteral(arg ast.Expr) (string, error) {
+	lit, ok := arg.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", fmt.Errorf("expected a string literal")
+	}
+	return strconv.Unquote(lit.Value)
+}
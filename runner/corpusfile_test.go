@@ -0,0 +1,175 @@
+package runner
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_UnmarshalCorpusEntry_ShouldReadMarshaledValues(t *testing.T) {
+	// Arrange
+	vals := []any{
+		[]byte("a\x00b"), "quote\"d", int(-42), int8(-8), int16(16), int32(-32), int64(64),
+		uint(1), uint8(200), uint16(16), uint32(32), uint64(math.MaxUint64),
+		float32(-1.5), 2.25, math.Inf(-1), 'é', rune(0x10ffff + 1), true, false,
+	}
+	data, err := MarshalCorpusEntry(corpusEntry{Values: vals})
+	require.NoError(t, err)
+
+	// Act
+	read, err := UnmarshalCorpusEntry(data)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, vals, read)
+}
+
+func Test_UnmarshalCorpusEntry_ShouldReadNaN(t *testing.T) {
+	// Act
+	vals, err := UnmarshalCorpusEntry([]byte("go test fuzz v1\nmath.Float64frombits(0x7ff8000000000001)\n"))
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, 1, len(vals))
+	assert.True(t, math.IsNaN(vals[0].(float64)))
+}
+
+func Test_UnmarshalCorpusEntry_ShouldRejectBadFiles(t *testing.T) {
+	cases := map[string]string{
+		"NoValues":    "go test fuzz v1\n",
+		"BadVersion":  "go test fuzz v2\nint(1)\n",
+		"NotACall":    "go test fuzz v1\n42\n",
+		"Overflow":    "go test fuzz v1\nint8(300)\n",
+		"UnknownType": "go test fuzz v1\ncomplex64(1)\n",
+		"WrongLit":    "go test fuzz v1\nstring(1)\n",
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Act
+			_, err := UnmarshalCorpusEntry([]byte(data))
+
+			// Assert
+			assert.Error(t, err)
+		})
+	}
+}
+
+func Test_ReplayCorpusEntry_ShouldReturnTargetError(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	path, err := WriteCrasher(dir, corpusEntry{Values: []any{[]byte("BOOM"), 3}})
+	require.NoError(t, err)
+	types := []reflect.Type{reflect.TypeOf([]byte(nil)), reflect.TypeOf(0)}
+	errCrash := errors.New("crashed")
+	var replayed corpusEntry
+
+	// Act
+	err = ReplayCorpusEntry(path, types, func(e corpusEntry) error {
+		replayed = e
+		if string(e.Values[0].([]byte)) == "BOOM" {
+			return errCrash
+		}
+		return nil
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, errCrash)
+	assert.Equal(t, path, replayed.Path)
+	assert.Equal(t, []any{[]byte("BOOM"), 3}, replayed.Values)
+}
+
+func Test_ReplayCorpusEntry_ShouldCheckTypes(t *testing.T) {
+	// Arrange
+	path, err := WriteCrasher(t.TempDir(), corpusEntry{Values: []any{"not bytes"}})
+	require.NoError(t, err)
+
+	// Act
+	err = ReplayCorpusEntry(path, []reflect.Type{reflect.TypeOf([]byte(nil))}, func(corpusEntry) error {
+		t.Fatal("must not run")
+		return nil
+	})
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func Test_ReadCorpus_ShouldReadCorpusDir(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	_, err := WriteCrasher(dir, corpusEntry{Values: []any{"a"}})
+	require.NoError(t, err)
+	_, err = WriteCrasher(dir, corpusEntry{Values: []any{"b"}})
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0o777))
+	types := []reflect.Type{reflect.TypeOf("")}
+
+	// Act
+	entries, err := TestDeps{}.ReadCorpus(dir, types)
+	missing, errMissing := TestDeps{}.ReadCorpus(filepath.Join(dir, "missing"), types)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.NoError(t, errMissing)
+	assert.Empty(t, missing)
+}
+
+func Test_ReadCorpus_ShouldCoerceNumericValues(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	_, err := WriteCrasher(dir, corpusEntry{Values: []any{int(42), 2.0}})
+	require.NoError(t, err)
+	types := []reflect.Type{reflect.TypeOf(int64(0)), reflect.TypeOf(uint8(0))}
+
+	// Act
+	entries, err := TestDeps{coerceCorpus: true}.ReadCorpus(dir, types)
+	_, errStrict := TestDeps{}.ReadCorpus(dir, types)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	assert.Equal(t, []any{int64(42), uint8(2)}, entries[0].Values)
+	assert.Error(t, errStrict)
+}
+
+func Test_CoerceCorpusValues_ShouldRejectLossyConversions(t *testing.T) {
+	cases := map[string]struct {
+		val  any
+		typ  reflect.Type
+		want any
+	}{
+		"IntToInt64":        {val: int(42), typ: reflect.TypeOf(int64(0)), want: int64(42)},
+		"IntToInt8InRange":  {val: int(-128), typ: reflect.TypeOf(int8(0)), want: int8(-128)},
+		"IntToInt8Overflow": {val: int(300), typ: reflect.TypeOf(int8(0))},
+		"NegativeToUint":    {val: int(-1), typ: reflect.TypeOf(uint(0))},
+		"MaxUint64ToInt64":  {val: uint64(math.MaxUint64), typ: reflect.TypeOf(int64(0))},
+		"IntToFloat64":      {val: int(1 << 53), typ: reflect.TypeOf(float64(0)), want: float64(1 << 53)},
+		"IntToFloat64Loses": {val: int(1<<53 + 1), typ: reflect.TypeOf(float64(0))},
+		"WholeFloatToInt":   {val: 3.0, typ: reflect.TypeOf(int32(0)), want: int32(3)},
+		"FractionToInt":     {val: 3.5, typ: reflect.TypeOf(int32(0))},
+		"InfToInt":          {val: math.Inf(1), typ: reflect.TypeOf(int64(0))},
+		"Float64ToFloat32":  {val: 0.5, typ: reflect.TypeOf(float32(0)), want: float32(0.5)},
+		"Float32Loses":      {val: 0.1, typ: reflect.TypeOf(float32(0))},
+		"NotNumeric":        {val: "42", typ: reflect.TypeOf(int64(0)), want: "42"}, // left for CheckCorpus
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Act
+			vals, err := coerceCorpusValues([]any{tc.val}, []reflect.Type{tc.typ})
+
+			// Assert
+			if tc.want == nil {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []any{tc.want}, vals)
+		})
+	}
+}
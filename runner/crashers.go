@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+)
+
+/*
+crashers.go: Persisting crashing fuzz inputs in the "go test fuzz v1" format, like go test -fuzz does under testdata/fuzz/<Target>,
+so a crash can be replayed with a plain go test -run.
+*/
+
+// MarshalCorpusEntry encodes the values of entry in the "go test fuzz v1"
+// format read by go test
+func MarshalCorpusEntry(entry corpusEntry) ([]byte, error) {
+	b := bytes.NewBufferString("go test fuzz v1\n")
+	for i, v := range entry.Values {
+		switch t := v.(type) {
+		case int, int8, int16, int64, uint, uint16, uint32, uint64, bool:
+			fmt.Fprintf(b, "%T(%v)\n", t, t)
+		case float32:
+			if math.IsNaN(float64(t)) {
+				fmt.Fprintf(b, "math.Float32frombits(0x%x)\n", math.Float32bits(t))
+			} else {
+				fmt.Fprintf(b, "%T(%v)\n", t, t)
+			}
+		case float64:
+			if math.IsNaN(t) {
+				fmt.Fprintf(b, "math.Float64frombits(0x%x)\n", math.Float64bits(t))
+			} else {
+				fmt.Fprintf(b, "%T(%v)\n", t, t)
+			}
+		case string:
+			fmt.Fprintf(b, "string(%q)\n", t)
+		case rune: // int32
+			if utf8.ValidRune(t) && strconv.IsPrint(t) {
+				fmt.Fprintf(b, "rune(%q)\n", t)
+			} else {
+				fmt.Fprintf(b, "int32(%v)\n", t)
+			}
+		case byte: // uint8
+			fmt.Fprintf(b, "byte(%q)\n", t)
+		case []byte:
+			fmt.Fprintf(b, "[]byte(%q)\n", t)
+		default:
+			return nil, fmt.Errorf("value %d: unsupported corpus type %T", i, v)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// WriteCrasher writes entry to dir (e.g. testdata/fuzz/FuzzTarget), creating
+// it if needed, and returns the path of the file. Like go test, the file is
+// named by a hash of its contents, so writing the same crash again reuses its
+// file; a different file with the same name gets a numbered suffix instead of
+// being overwritten.
+func WriteCrasher(dir string, entry corpusEntry) (string, error) {
+	data, err := MarshalCorpusEntry(entry)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+	for n := 0; ; n++ {
+		path := filepath.Join(dir, name)
+		if n > 0 {
+			path += "-" + strconv.Itoa(n)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+		if errors.Is(err, os.ErrExist) {
+			if existing, rerr := os.ReadFile(path); rerr == nil && bytes.Equal(existing, data) {
+				return path, nil
+			}
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		return path, nil
+	}
+}
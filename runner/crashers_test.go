@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"go/parser"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriteCrasher_ShouldWriteParseableCorpusFile(t *testing.T) {
+	// Arrange
+	dir := filepath.Join(t.TempDir(), "testdata", "fuzz", "FuzzParse")
+	entry := corpusEntry{Values: []any{[]byte("BOOM\x00"), "a\"b", 42, uint8('x'), 'é', 1.5, math.NaN(), true}}
+
+	// Act
+	path, err := WriteCrasher(dir, entry)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	assert.Equal(t, []string{
+		"go test fuzz v1",
+		`[]byte("BOOM\x00")`,
+		`string("a\"b")`,
+		"int(42)",
+		"byte('x')",
+		"rune('é')",
+		"float64(1.5)",
+		"math.Float64frombits(0x7ff8000000000001)",
+		"bool(true)",
+	}, lines)
+	for _, line := range lines[1:] {
+		_, err := parser.ParseExpr(line)
+		assert.NoError(t, err, line)
+	}
+}
+
+func Test_WriteCrasher_ShouldHandleNameCollisions(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	entry := corpusEntry{Values: []any{"crash"}}
+	first, err := WriteCrasher(dir, entry)
+	require.NoError(t, err)
+
+	// Act
+	again, errAgain := WriteCrasher(dir, entry)
+	require.NoError(t, os.WriteFile(first, []byte("something else"), 0o666))
+	other, errOther := WriteCrasher(dir, entry)
+
+	// Assert
+	require.NoError(t, errAgain)
+	require.NoError(t, errOther)
+	assert.Equal(t, first, again)
+	assert.Equal(t, first+"-1", other)
+}
+
+func Test_MarshalCorpusEntry_ShouldRejectUnsupportedTypes(t *testing.T) {
+	// Act
+	_, err := MarshalCorpusEntry(corpusEntry{Values: []any{[]int{1}}})
+
+	// Assert
+	assert.Error(t, err)
+}
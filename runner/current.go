@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"sync"
+	"testing"
+)
+
+/*
+current.go: The name of the test running on a goroutine, for helper code that logs which test called it.
+Go has no goroutine-local storage, so the names are kept by goroutine ID (see goroutineID in stacks.go).
+*/
+
+// currentTests are the tests entered on each goroutine, innermost last
+var currentTests = struct {
+	sync.Mutex
+	byID map[int64][]string
+}{byID: make(map[int64][]string)}
+
+// CurrentTest returns the name of the test running on the calling goroutine.
+// The runner tracks the goroutine of every top-level test of a Run, and of
+// the tests and subtests using testdeck.Test from their start to their
+// statistics. Goroutines started by a test are not tracked, so ok is false
+// on them, as it is outside any test.
+func CurrentTest() (name string, ok bool) {
+	id := goroutineID()
+	currentTests.Lock()
+	defer currentTests.Unlock()
+	names := currentTests.byID[id]
+	if len(names) == 0 {
+		return "", false
+	}
+	return names[len(names)-1], true
+}
+
+// enterTest makes name the current test of the calling goroutine until leave
+// is called, from the same goroutine
+func enterTest(name string) (leave func()) {
+	id := goroutineID()
+	currentTests.Lock()
+	currentTests.byID[id] = append(currentTests.byID[id], name)
+	currentTests.Unlock()
+	return func() { leaveTest(id, name) }
+}
+
+// leaveTest removes name from the tests entered on the goroutine id if it is
+// the innermost one
+func leaveTest(id int64, name string) {
+	currentTests.Lock()
+	defer currentTests.Unlock()
+	names := currentTests.byID[id]
+	if len(names) == 0 || names[len(names)-1] != name {
+		return
+	}
+	if len(names) == 1 {
+		delete(currentTests.byID, id)
+		return
+	}
+	currentTests.byID[id] = names[:len(names)-1]
+}
+
+// trackCurrentTests wraps the tests so CurrentTest returns their name
+func trackCurrentTests(tests []testing.InternalTest) []testing.InternalTest {
+	tracked := make([]testing.InternalTest, len(tests))
+	for i, test := range tests {
+		tracked[i] = test
+		// the match workaround tags names, so use the actual name
+		_, _, name := MatchTag(test.Name)
+		f := test.F
+		tracked[i].F = func(t *testing.T) {
+			defer enterTest(name)()
+			f(t)
+		}
+	}
+	return tracked
+}
@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CurrentTest_ShouldReturnNameOfRunningTest(t *testing.T) {
+	// Arrange
+	type seen struct {
+		name string
+		ok   bool
+	}
+	var inTest, inSubtest, inTestdeckSubtest, afterSubtest, inGoroutine seen
+	var mu sync.Mutex
+	var ids []int64 // the goroutines of the tests
+	current := func() seen {
+		mu.Lock()
+		ids = append(ids, goroutineID())
+		mu.Unlock()
+		name, ok := CurrentTest()
+		return seen{name, ok}
+	}
+	var r *runner
+	r = newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestHelper", F: func(t *testing.T) {
+			inTest = current()
+			t.Run("Plain", func(t *testing.T) { inSubtest = current() })
+			t.Run("Testdeck", func(t *testing.T) {
+				r.TestStarted(t.Name()) // as testdeck.Test does
+				inTestdeckSubtest = current()
+				r.AddStatistics(&constants.Statistics{Name: t.Name()})
+			})
+			afterSubtest = current()
+			done := make(chan struct{})
+			go func() {
+				inGoroutine = current()
+				close(done)
+			}()
+			<-done
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	ok := runIsolated(t)
+	_, okBefore := CurrentTest()
+
+	// Act
+	r.Run()
+
+	// Assert
+	require.True(t, *ok)
+	assert.False(t, okBefore)
+	assert.Equal(t, seen{"TestHelper", true}, inTest)
+	assert.Equal(t, seen{}, inSubtest) // its own goroutine, not using testdeck.Test
+	assert.Equal(t, seen{"TestHelper/Testdeck", true}, inTestdeckSubtest)
+	assert.Equal(t, seen{"TestHelper", true}, afterSubtest)
+	assert.Equal(t, seen{}, inGoroutine)
+	_, okAfter := CurrentTest()
+	assert.False(t, okAfter)
+	currentTests.Lock()
+	defer currentTests.Unlock()
+	for _, id := range ids {
+		assert.Empty(t, currentTests.byID[id])
+	}
+}
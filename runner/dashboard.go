@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+dashboard.go: A live view of a run for interactive use, fed by the SetOnTestStart and SetOnTestEnd callbacks.
+On a terminal the view is redrawn in place with ANSI cursor control; anything else gets one summary line per redraw.
+*/
+
+// dashboardMaxRunning is the number of running tests the dashboard lists
+const dashboardMaxRunning = 10
+
+// DashboardReporter shows the counts of running, passed, failed and skipped
+// tests, the last failure and the tests being run, redrawn every interval.
+// Write it to the real stdout or stderr: the dashboard keeps the terminal it
+// was created with, so the runner's output capture (which replaces
+// os.Stdout while a Run runs) doesn't swallow it. The tests' own output
+// printed between redraws scrolls the dashboard away, so turn printing off
+// with PrintToStdout(false) (or SetOutput elsewhere) when using it on a
+// terminal.
+type DashboardReporter struct {
+	w        io.Writer
+	tty      bool
+	interval time.Duration
+
+	mu          sync.Mutex
+	running     []string
+	passed      int
+	failed      int
+	skipped     int
+	lastFailure string
+	drawn       int // lines of the last frame, drawn over by the next one
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewDashboardReporter returns a dashboard drawn to w every interval (1s if
+// 0 or less) while it is started. It redraws in place if w is a terminal.
+func NewDashboardReporter(w io.Writer, interval time.Duration) *DashboardReporter {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &DashboardReporter{w: w, tty: isTerminal(w), interval: interval}
+}
+
+// isTerminal reports whether w is a character device such as a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Attach feeds the dashboard from r's SetOnTestStart and SetOnTestEnd
+// callbacks, replacing the ones set before
+func (d *DashboardReporter) Attach(r Runner) {
+	r.SetOnTestStart(d.TestStarted)
+	r.SetOnTestEnd(d.TestEnded)
+}
+
+// TestStarted records that the test name started; see SetOnTestStart
+func (d *DashboardReporter) TestStarted(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.running = append(d.running, name)
+}
+
+// TestEnded records the outcome of the test name; see SetOnTestEnd
+func (d *DashboardReporter) TestEnded(name string, outcome string, _ time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, running := range d.running {
+		if running == name {
+			d.running = append(d.running[:i], d.running[i+1:]...)
+			break
+		}
+	}
+	switch outcome {
+	case constants.StatusFail:
+		d.failed++
+		d.lastFailure = name
+	case constants.StatusSkip:
+		d.skipped++
+	default:
+		d.passed++
+	}
+}
+
+// Start draws the dashboard and keeps redrawing it every interval until Stop
+func (d *DashboardReporter) Start() {
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	d.draw()
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.draw()
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing and draws the final state once more
+func (d *DashboardReporter) Stop() {
+	if d.stop == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+	d.stop = nil
+	d.draw()
+}
+
+// draw writes the current state, over the last frame on a terminal
+func (d *DashboardReporter) draw() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	counts := fmt.Sprintf("testdeck: %d running, %d passed, %d failed, %d skipped", len(d.running), d.passed, d.failed, d.skipped)
+	if !d.tty {
+		line := counts
+		if d.lastFailure != "" {
+			line += "; last failure: " + d.lastFailure
+		}
+		fmt.Fprintln(d.w, line)
+		return
+	}
+
+	lines := []string{counts}
+	if d.lastFailure != "" {
+		lines = append(lines, "last failure: "+d.lastFailure)
+	}
+	for i, name := range d.running {
+		if i == dashboardMaxRunning {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(d.running)-i))
+			break
+		}
+		lines = append(lines, "  running "+name)
+	}
+
+	var b strings.Builder
+	if d.drawn > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", d.drawn) // up to the first line of the last frame
+	}
+	b.WriteString("\x1b[J") // and clear it
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	io.WriteString(d.w, b.String())
+	d.drawn = len(lines)
+}
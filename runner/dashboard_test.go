@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DashboardReporter_ShouldEmitPeriodicSummaryLinesWithoutTerminal(t *testing.T) {
+	// Arrange
+	var buf lockedBuffer
+	d := NewDashboardReporter(&buf, 5*time.Millisecond)
+	d.TestStarted("TestA")
+	d.TestStarted("TestB")
+	d.TestEnded("TestA", constants.StatusFail, time.Second)
+
+	// Act
+	d.Start()
+	assert.Eventually(t, func() bool { return strings.Count(buf.String(), "\n") >= 3 }, time.Second, time.Millisecond)
+	d.Stop()
+
+	// Assert
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for _, line := range lines {
+		assert.Equal(t, "testdeck: 1 running, 0 passed, 1 failed, 0 skipped; last failure: TestA", line)
+	}
+	assert.NotContains(t, buf.String(), "\x1b[")
+}
+
+func Test_DashboardReporter_ShouldRedrawInPlaceOnTerminal(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	d := NewDashboardReporter(&buf, time.Second)
+	d.tty = true
+	d.TestStarted("TestA")
+	d.TestStarted("TestB")
+	d.draw()
+	buf.Reset()
+	d.TestEnded("TestA", constants.StatusPass, time.Second)
+
+	// Act
+	d.draw()
+
+	// Assert
+	assert.Equal(t, "\x1b[3A\x1b[J"+
+		"testdeck: 1 running, 1 passed, 0 failed, 0 skipped\n"+
+		"  running TestB\n", buf.String())
+}
+
+func Test_DashboardReporter_ShouldBeFedByRunnerCallbacks(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	d := NewDashboardReporter(&buf, time.Second)
+	r := newInstance(newFakeTestingM())
+	d.Attach(r)
+
+	// Act
+	r.TestStarted("TestB")
+	t.Cleanup(func() { r.AddStatistics(&constants.Statistics{Name: "TestB"}) }) // leaves the current test
+	r.TestStarted("TestA")
+	r.AddStatistics(&constants.Statistics{Name: "TestA", Statuses: []constants.Status{{Status: constants.StatusSkip}}})
+	d.draw()
+
+	// Assert
+	assert.Equal(t, "testdeck: 1 running, 0 passed, 0 failed, 1 skipped\n", buf.String())
+}
@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"sync"
+	"testing"
+)
+
+/*
+deck.go: The default deck, a package-level registry of tests for packages that register them from init, before the
+runner instance exists. Every runner runs the tests of the default deck after its own registered tests.
+*/
+
+// Deck is a registry of tests that is safe for concurrent use
+type Deck struct {
+	mu    sync.Mutex
+	tests []registeredTest
+}
+
+var defaultDeck = &Deck{}
+
+// DefaultDeck returns the deck whose tests every runner runs, see
+// RegisterWithWrapper
+func DefaultDeck() *Deck {
+	return defaultDeck
+}
+
+// ResetDefaultDeck removes all the tests from the default deck, e.g. between
+// embedded runs or in tests of code that registers into it
+func ResetDefaultDeck() {
+	defaultDeck.Reset()
+}
+
+// Register adds a top-level test to the deck
+func (d *Deck) Register(name string, fn func(t *testing.T)) {
+	d.RegisterWithWrapper(name, fn, nil)
+}
+
+// RegisterWithWrapper adds a top-level test to the deck, with a wrapper of its
+// T created when it starts, like the runner's RegisterWithWrapper
+func (d *Deck) RegisterWithWrapper(name string, fn func(t *testing.T), wrap func(t *testing.T) any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tests = append(d.tests, registeredTest{name: name, fn: fn, wrap: wrap})
+}
+
+// Names returns the names of the tests in the deck, in registration order
+func (d *Deck) Names() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, len(d.tests))
+	for i, reg := range d.tests {
+		names[i] = reg.name
+	}
+	return names
+}
+
+// Reset removes all the tests from the deck
+func (d *Deck) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tests = nil
+}
+
+// registered returns a copy of the tests in the deck
+func (d *Deck) registered() []registeredTest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]registeredTest(nil), d.tests...)
+}
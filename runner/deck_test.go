@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DefaultDeck_ShouldKeepConcurrentRegistrations(t *testing.T) {
+	// Arrange
+	defer ResetDefaultDeck()
+	var wg sync.WaitGroup
+	var want []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("TestRegistered%02d", i)
+		want = append(want, name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			DefaultDeck().Register(name, func(t *testing.T) {})
+		}()
+	}
+
+	// Act
+	wg.Wait()
+
+	// Assert
+	names := DefaultDeck().Names()
+	sort.Strings(names)
+	assert.Equal(t, want, names)
+}
+
+func Test_ResetDefaultDeck_ShouldRemoveRegisteredTests(t *testing.T) {
+	// Arrange
+	defer ResetDefaultDeck()
+	DefaultDeck().Register("TestBefore", func(t *testing.T) {})
+
+	// Act
+	ResetDefaultDeck()
+
+	// Assert
+	assert.Empty(t, DefaultDeck().Names())
+	assert.Empty(t, newInstance(newFakeTestingM()).(*runner).allTests())
+}
+
+func Test_DefaultDeck_ShouldRunTestsAfterRunnersOwn(t *testing.T) {
+	// Arrange
+	defer ResetDefaultDeck()
+	var ran []string
+	DefaultDeck().Register("TestFromDeck", func(t *testing.T) { ran = append(ran, t.Name()) })
+	r := newInstance(newFakeTestingM()).(*runner)
+	r.RegisterWithWrapper("TestFromRunner", func(t *testing.T) { ran = append(ran, t.Name()) }, nil)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	ok := runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.True(t, *ok)
+	assert.Equal(t, []string{"TestFromRunner", "TestFromDeck"}, ran)
+}
@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+depgraph.go: Per-test file dependencies, from the open and stat ops of the test log, and their export as a Graphviz DOT graph.
+An op is attributed to every test that is running when it is reported, so parallel tests share each other's files.
+*/
+
+// SetTrackFileAccess records the files each test opens or stats while it
+// runs in its Statistics.FileAccesses, see Result.WriteDepGraphDOT. The ops
+// come from the test log (see SetTestLogWriter), which the runner starts for
+// it if testing doesn't, so only files accessed with the Open and Stat funcs
+// of this package are seen. Only tests started with testdeck.Test are
+// tracked, and an op is attributed to all the tests running at the time.
+func (r *runner) SetTrackFileAccess(yes bool) {
+	r.trackFiles = yes
+}
+
+// observeFileAccess attributes the ops of the test log to the running tests
+// for SetTrackFileAccess and SetTrackOpenFiles until stop is called
+func (r *runner) observeFileAccess() (stop func()) {
+	if !r.trackFiles && !r.trackOpen {
+		return func() {}
+	}
+	r.mu.Lock()
+	if r.trackFiles {
+		r.fileAccess = make(map[string][]constants.FileAccess)
+	}
+	if r.trackOpen {
+		r.openFiles = make(map[string][]string)
+	}
+	r.mu.Unlock()
+	log.setObserver(func(op, path string) {
+		r.recordFileAccess(op, path)
+		r.recordOpenFile(op, path)
+	})
+	return func() {
+		log.setObserver(nil)
+		r.mu.Lock()
+		// the tests still running at the end have no statistics
+		r.fileAccess = nil
+		r.openFiles = nil
+		r.mu.Unlock()
+	}
+}
+
+// startFileAccess makes name one of the running tests that get ops
+func (r *runner) startFileAccess(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fileAccess != nil {
+		r.fileAccess[name] = nil
+	}
+}
+
+// recordFileAccess adds an open or stat op to the running tests
+func (r *runner) recordFileAccess(op, path string) {
+	if op != "open" && op != "stat" {
+		return
+	}
+	access := constants.FileAccess{Op: op, Path: path}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, accesses := range r.fileAccess {
+		if !containsAccess(accesses, access) {
+			r.fileAccess[name] = append(accesses, access)
+		}
+	}
+}
+
+func containsAccess(accesses []constants.FileAccess, access constants.FileAccess) bool {
+	for _, a := range accesses {
+		if a == access {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteDepGraphDOT writes the files the tests of the result accessed (see
+// SetTrackFileAccess) as a Graphviz DOT graph: an edge from each test to each
+// file it opened or stat'd, labelled with the ops. Files accessed by more
+// than one test are filled, as the tests that share them can't be assumed to
+// be independent. Repeated runs of a test are merged.
+func (res *Result) WriteDepGraphDOT(w io.Writer) error {
+	var tests, files []string
+	ops := make(map[[2]string][]string) // by test and file
+	users := make(map[string]map[string]bool)
+	for _, s := range res.Stats {
+		for _, a := range s.FileAccesses {
+			if !containsString(tests, s.Name) {
+				tests = append(tests, s.Name)
+			}
+			if users[a.Path] == nil {
+				files = append(files, a.Path)
+				users[a.Path] = make(map[string]bool)
+			}
+			users[a.Path][s.Name] = true
+			edge := [2]string{s.Name, a.Path}
+			if !containsString(ops[edge], a.Op) {
+				ops[edge] = append(ops[edge], a.Op)
+			}
+		}
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph deps {")
+	fmt.Fprintln(b, "\trankdir=LR;")
+	for _, name := range tests {
+		fmt.Fprintf(b, "\t%s [shape=ellipse];\n", dotQuote(name))
+	}
+	for _, path := range files {
+		if len(users[path]) > 1 {
+			fmt.Fprintf(b, "\t%s [shape=box, style=filled, fillcolor=orange];\n", dotQuote(path))
+		} else {
+			fmt.Fprintf(b, "\t%s [shape=box];\n", dotQuote(path))
+		}
+	}
+	for _, name := range tests {
+		for _, path := range files {
+			if edgeOps, ok := ops[[2]string{name, path}]; ok {
+				fmt.Fprintf(b, "\t%s -> %s [label=%s];\n", dotQuote(name), dotQuote(path), dotQuote(strings.Join(edgeOps, ",")))
+			}
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// dotQuote returns s as a DOT double-quoted string
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
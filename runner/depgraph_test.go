@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SetTrackFileAccess_ShouldRecordFilesOfRunningTest(t *testing.T) {
+	// Arrange
+	defer setTestFlag("test.testlogfile", "")() // testing doesn't start the log
+	r := newInstance(newTestingM("TestReadsFixture")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetTrackFileAccess(true)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		Open("testdata/before.json") // no test running yet
+		r.TestStarted("TestReadsFixture")
+		Open("testdata/fixture.json")
+		Stat("testdata/fixture.json")
+		Open("testdata/fixture.json")
+		Getenv("HOME") // not a file
+		r.AddStatistics(&constants.Statistics{Name: "TestReadsFixture"})
+		Stat("testdata/after.json")
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	require.Len(t, r.Statistics(), 1)
+	assert.Equal(t, []constants.FileAccess{
+		{Op: "open", Path: "testdata/fixture.json"},
+		{Op: "stat", Path: "testdata/fixture.json"},
+	}, r.Statistics()[0].FileAccesses)
+	var dot strings.Builder
+	require.NoError(t, r.Result().WriteDepGraphDOT(&dot))
+	assert.Contains(t, dot.String(), "\"TestReadsFixture\" -> \"testdata/fixture.json\" [label=\"open,stat\"];\n")
+}
+
+func Test_WriteDepGraphDOT_ShouldHighlightSharedFiles(t *testing.T) {
+	// Arrange
+	res := &Result{Stats: []constants.Statistics{
+		{Name: "TestA", FileAccesses: []constants.FileAccess{{Op: "open", Path: "testdata/a.txt"}, {Op: "open", Path: "testdata/shared.txt"}}},
+		{Name: "TestB", FileAccesses: []constants.FileAccess{{Op: "stat", Path: "testdata/shared.txt"}}},
+		{Name: "TestA", FileAccesses: []constants.FileAccess{{Op: "stat", Path: "testdata/a.txt"}}}, // a second attempt
+		{Name: "TestNoFiles"},
+	}}
+	var dot strings.Builder
+
+	// Act
+	err := res.WriteDepGraphDOT(&dot)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, `digraph deps {
+	rankdir=LR;
+	"TestA" [shape=ellipse];
+	"TestB" [shape=ellipse];
+	"testdata/a.txt" [shape=box];
+	"testdata/shared.txt" [shape=box, style=filled, fillcolor=orange];
+	"TestA" -> "testdata/a.txt" [label="open,stat"];
+	"TestA" -> "testdata/shared.txt" [label="open"];
+	"TestB" -> "testdata/shared.txt" [label="stat"];
+}
+`, dot.String())
+}
+
+func Test_DotQuote_ShouldEscapeQuotesAndBackslashes(t *testing.T) {
+	// Arrange
+	s := `C:\data\"quoted".txt`
+
+	// Act
+	quoted := dotQuote(s)
+
+	// Assert
+	assert.Equal(t, `"C:\\data\\\"quoted\".txt"`, quoted)
+}
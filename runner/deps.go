@@ -24,7 +24,38 @@ import (
 
 // TestDeps is an implementation of the testing.testDeps interface,
 // suitable for passing to testing.MainStart.
-type TestDeps struct{}
+type TestDeps struct {
+	cpuProfileDuration time.Duration // see runner.SetCPUProfileDuration
+	testLogOut         io.Writer     // see runner.SetTestLogWriter
+	testLogFlush       time.Duration // see runner.SetTestLogFlushInterval
+	coerceCorpus       bool          // see runner.SetCorpusCoercion
+	clock              clock         // see runner.setClock; nil is the real clock
+}
+
+// testDeps is the testing.testDeps interface that testing.MainStart takes and
+// the runner hands on to it. *TestDeps is the implementation used outside of
+// the runner's own tests, which use a fake that doesn't touch the global
+// profiling and test log state instead.
+type testDeps interface {
+	ImportPath() string
+	ModulePath() string
+	MatchString(pat, str string) (bool, error)
+	SetPanicOnExit0(bool)
+	StartCPUProfile(io.Writer) error
+	StopCPUProfile()
+	StartTestLog(io.Writer)
+	StopTestLog() error
+	WriteProfileTo(string, io.Writer, int) error
+	CoordinateFuzzing(time.Duration, int64, time.Duration, int64, int, []corpusEntry, []reflect.Type, string, string) error
+	RunFuzzWorker(func(corpusEntry) error) error
+	ReadCorpus(string, []reflect.Type) ([]corpusEntry, error)
+	CheckCorpus([]any, []reflect.Type) error
+	ResetCoverage()
+	SnapshotCoverage()
+	InitRuntimeCoverage() (mode string, tearDown func(coverprofile string, gocoverdir string) (string, error), snapcov func() float64)
+}
+
+var _ testDeps = (*TestDeps)(nil)
 
 // InitRuntimeCoverage implements testing.testDeps.
 func (t *TestDeps) InitRuntimeCoverage() (mode string, tearDown func(coverprofile string, gocoverdir string) (string, error), snapcov func() float64) {
@@ -58,12 +89,20 @@ func (TestDeps) MatchString(pat, str string) (result bool, err error) {
 	return matchRe.MatchString(str), nil
 }
 
-func (TestDeps) StartCPUProfile(w io.Writer) error {
-	return pprof.StartCPUProfile(w)
+// runClock returns the clock set by runner.setClock, or the real clock
+func (t TestDeps) runClock() clock {
+	if t.clock == nil {
+		return realClock{}
+	}
+	return t.clock
+}
+
+func (t TestDeps) StartCPUProfile(w io.Writer) error {
+	return startCPUProfile(w, t.cpuProfileDuration, t.runClock())
 }
 
 func (TestDeps) StopCPUProfile() {
-	pprof.StopCPUProfile()
+	stopCPUProfile(0)
 }
 
 func (TestDeps) WriteProfileTo(name string, w io.Writer, debug int) error {
@@ -77,11 +116,20 @@ func (TestDeps) ImportPath() string {
 	return ImportPath
 }
 
+// ModulePath is the module path of the testing binary, set by the generated main function.
+var ModulePath string
+
+func (TestDeps) ModulePath() string {
+	return ModulePath
+}
+
 // testLog implements testlog.Interface, logging actions by package os.
 type testLog struct {
-	mu  sync.Mutex
-	w   *bufio.Writer
-	set bool
+	mu        sync.Mutex
+	w         *bufio.Writer
+	set       bool
+	stopFlush func()                // stops the periodic flush of w, or nil
+	observe   func(op, name string) // also gets every op, see runner.SetTrackFileAccess
 }
 
 func (l *testLog) Getenv(key string) {
@@ -100,6 +148,10 @@ func (l *testLog) Chdir(name string) {
 	l.add("chdir", name)
 }
 
+func (l *testLog) Close(name string) {
+	l.add("close", name)
+}
+
 // add adds the (op, name) pair to the test log.
 func (l *testLog) add(op, name string) {
 	if strings.Contains(name, "\n") || name == "" {
@@ -107,21 +159,67 @@ func (l *testLog) add(op, name string) {
 	}
 
 	l.mu.Lock()
-	defer l.mu.Unlock()
-	if l.w == nil {
-		return
+	observe := l.observe
+	if l.w != nil {
+		l.w.WriteString(op)
+		l.w.WriteByte(' ')
+		l.w.WriteString(name)
+		l.w.WriteByte('\n')
 	}
-	l.w.WriteString(op)
-	l.w.WriteByte(' ')
-	l.w.WriteString(name)
-	l.w.WriteByte('\n')
+	l.mu.Unlock()
+	if observe != nil {
+		observe(op, name) // without l.mu, which a writing op might need
+	}
+}
+
+// setObserver sets the func that gets every op added to l, nil for none
+func (l *testLog) setObserver(fn func(op, name string)) {
+	l.mu.Lock()
+	l.observe = fn
+	l.mu.Unlock()
+}
+
+// flushEvery flushes the current writer of l every d until stop is called
+// or the writer is replaced. l.mu must be held. An error is kept by the
+// writer and returned by the Flush of StopTestLog.
+func (l *testLog) flushEvery(d time.Duration, clk clock) (stop func()) {
+	w := l.w
+	tick, stopTicker := clk.NewTicker(d)
+	done := make(chan struct{})
+	go func() {
+		defer stopTicker()
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick:
+			}
+			l.mu.Lock()
+			if l.w == w {
+				w.Flush()
+			}
+			l.mu.Unlock()
+		}
+	}()
+	// doesn't wait for the goroutine, which may be waiting for l.mu
+	return func() { close(done) }
 }
 
 var log testLog
 
-func (TestDeps) StartTestLog(w io.Writer) {
+func (t TestDeps) StartTestLog(w io.Writer) {
+	if t.testLogOut != nil {
+		w = io.MultiWriter(w, t.testLogOut)
+	}
 	log.mu.Lock()
+	if log.stopFlush != nil {
+		log.stopFlush() // the log was not stopped
+		log.stopFlush = nil
+	}
 	log.w = bufio.NewWriter(w)
+	if t.testLogFlush > 0 {
+		log.stopFlush = log.flushEvery(t.testLogFlush, t.runClock())
+	}
 	if !log.set {
 		// Tests that define TestMain and then run m.Run multiple times
 		// will call StartTestLog/StopTestLog multiple times.
@@ -137,6 +235,13 @@ func (TestDeps) StartTestLog(w io.Writer) {
 func (TestDeps) StopTestLog() error {
 	log.mu.Lock()
 	defer log.mu.Unlock()
+	if log.w == nil {
+		return nil // already stopped, see runState.cleanup
+	}
+	if log.stopFlush != nil {
+		log.stopFlush()
+		log.stopFlush = nil
+	}
 	err := log.w.Flush()
 	log.w = nil
 	return err
@@ -145,6 +250,7 @@ func (TestDeps) StopTestLog() error {
 // SetPanicOnExit0 tells the os package whether to panic on os.Exit(0).
 func (TestDeps) SetPanicOnExit0(v bool) {
 	SetPanicOnExit0(v)
+	testlogSetPanicOnExit0(v) // the hook package os actually reads, see osexit.go
 }
 
 func (TestDeps) CheckCorpus(vals []any, types []reflect.Type) error {
@@ -167,8 +273,8 @@ func (TestDeps) CoordinateFuzzing(time.Duration, int64, time.Duration, int64, in
 	return nil
 }
 
-func (TestDeps) ReadCorpus(dir string, types []reflect.Type) ([]corpusEntry, error) {
-	return nil, nil
+func (t TestDeps) ReadCorpus(dir string, types []reflect.Type) ([]corpusEntry, error) {
+	return readCorpusDir(dir, types, t.coerceCorpus) // see corpusfile.go
 }
 
 func (TestDeps) ResetCoverage() {}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDeps is a testDeps that records the calls testing makes to it instead
+// of profiling or logging, so runs can be tested without changing the global
+// pprof and test log state. MatchString matches like TestDeps but isn't
+// recorded since it is called for every test.
+type fakeDeps struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (d *fakeDeps) record(call string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, call)
+}
+
+// Calls returns the calls made so far
+func (d *fakeDeps) Calls() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.calls...)
+}
+
+func (d *fakeDeps) ImportPath() string { return "" }
+func (d *fakeDeps) ModulePath() string { return "" }
+func (d *fakeDeps) MatchString(pat, str string) (bool, error) {
+	return regexp.MatchString(pat, str)
+}
+func (d *fakeDeps) SetPanicOnExit0(v bool) { d.record(fmt.Sprintf("SetPanicOnExit0(%t)", v)) }
+func (d *fakeDeps) StartCPUProfile(w io.Writer) error {
+	d.record("StartCPUProfile")
+	return nil
+}
+func (d *fakeDeps) StopCPUProfile()          { d.record("StopCPUProfile") }
+func (d *fakeDeps) StartTestLog(w io.Writer) { d.record("StartTestLog") }
+func (d *fakeDeps) StopTestLog() error {
+	d.record("StopTestLog")
+	return nil
+}
+func (d *fakeDeps) WriteProfileTo(name string, w io.Writer, debug int) error {
+	d.record("WriteProfileTo(" + name + ")")
+	return nil
+}
+func (d *fakeDeps) CoordinateFuzzing(time.Duration, int64, time.Duration, int64, int, []corpusEntry, []reflect.Type, string, string) error {
+	d.record("CoordinateFuzzing")
+	return nil
+}
+func (d *fakeDeps) RunFuzzWorker(func(corpusEntry) error) error {
+	d.record("RunFuzzWorker")
+	return nil
+}
+func (d *fakeDeps) ReadCorpus(dir string, types []reflect.Type) ([]corpusEntry, error) {
+	d.record("ReadCorpus")
+	return nil, nil
+}
+func (d *fakeDeps) CheckCorpus(vals []any, types []reflect.Type) error {
+	d.record("CheckCorpus")
+	return nil
+}
+func (d *fakeDeps) ResetCoverage()    { d.record("ResetCoverage") }
+func (d *fakeDeps) SnapshotCoverage() { d.record("SnapshotCoverage") }
+func (d *fakeDeps) InitRuntimeCoverage() (mode string, tearDown func(coverprofile string, gocoverdir string) (string, error), snapcov func() float64) {
+	d.record("InitRuntimeCoverage")
+	return
+}
+
+func Test_Run_ShouldCallDepsAroundTests(t *testing.T) {
+	// Arrange
+	var ran []string
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestA", F: func(t *testing.T) { ran = append(ran, "TestA") }},
+		{Name: "TestB", F: func(t *testing.T) { ran = append(ran, "TestB") }},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	deps := &fakeDeps{}
+	r.deps = deps
+	r.SetCount(1) // not the outer go test's -count
+	dir := t.TempDir()
+	r.SetCPUProfile(filepath.Join(dir, "cpu.prof"))
+	defer setTestFlag("test.run", "")() // selects the tests of the outer go test too
+	// testing keeps the test log file in a global, so a nested m.Run with a
+	// test log would close the outer go test's log
+	defer setTestFlag("test.testlogfile", "")()
+	defer setTestFlag("test.shuffle", "off")() // not the outer go test's -shuffle
+
+	// Act
+	r.Run()
+
+	// Assert
+	require.Equal(t, []string{"TestA", "TestB"}, ran)
+	assert.Equal(t, []string{
+		"InitRuntimeCoverage",
+		"StartCPUProfile",
+		"SetPanicOnExit0(true)",
+		"StopCPUProfile",
+		"SetPanicOnExit0(false)",
+	}, deps.Calls())
+}
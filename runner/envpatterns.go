@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"os"
+)
+
+/*
+envpatterns.go: Taking the run and skip patterns from the environment, for tooling that sets them there instead of
+passing flags. The patterns set on the runner always win; the environment only fills in the ones that are empty.
+*/
+
+// The environment variables read with SetAllowEnvPatterns
+const (
+	EnvRunPattern  = "TESTDECK_RUN"
+	EnvSkipPattern = "TESTDECK_SKIP"
+)
+
+// SetAllowEnvPatterns makes each Run take its run pattern from the
+// TESTDECK_RUN environment variable if no run pattern other than the default
+// ".*" is set (see Match and MatchNames), and its skip pattern from
+// TESTDECK_SKIP if no skip pattern is set (see Skip). The runner's own
+// patterns are left as they were after the Run. The variables consulted are
+// reported to the test log (see SetTestLogWriter) like a test's os.Getenv.
+// An invalid pattern fails the Run (see RunInfo().Failure).
+func (r *runner) SetAllowEnvPatterns(yes bool) {
+	r.envPatterns = yes
+}
+
+// applyEnvPatterns sets the patterns of SetAllowEnvPatterns for the Run until
+// restore is called
+func (r *runner) applyEnvPatterns() (restore func(), err error) {
+	r.envRead = nil
+	if !r.envPatterns {
+		return func() {}, nil
+	}
+	matchRe, matcher, matchPattern, exactNames := r.matchRe, r.matcher, r.matchPattern, r.exactNames
+	skipPattern, skipMatcher := r.skipPattern, r.skipMatcher
+	restore = func() {
+		r.matchRe, r.matcher, r.matchPattern, r.exactNames = matchRe, matcher, matchPattern, exactNames
+		r.skipPattern, r.skipMatcher = skipPattern, skipMatcher
+	}
+
+	if r.matcher == nil || (r.matchPattern == ".*" && r.exactNames == nil) {
+		r.envRead = append(r.envRead, EnvRunPattern)
+		if pattern := os.Getenv(EnvRunPattern); pattern != "" {
+			if err := r.Match(pattern); err != nil {
+				return restore, withSource(err, EnvRunPattern)
+			}
+		}
+	}
+	if r.skipMatcher == nil {
+		r.envRead = append(r.envRead, EnvSkipPattern)
+		if pattern := os.Getenv(EnvSkipPattern); pattern != "" {
+			if err := r.Skip(pattern); err != nil {
+				return restore, withSource(err, EnvSkipPattern)
+			}
+		}
+	}
+	return restore, nil
+}
+
+// logEnvPatterns reports the variables applyEnvPatterns consulted to the
+// test log, once it has started
+func (r *runner) logEnvPatterns() {
+	for _, key := range r.envRead {
+		Getenv(key)
+	}
+}
@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SetAllowEnvPatterns_ShouldFillInEmptyPatterns(t *testing.T) {
+	cases := map[string]struct {
+		allow   bool
+		match   string
+		skip    string
+		envRun  string
+		envSkip string
+		want    []string
+	}{
+		"EnvRunPatternWhenEmpty": {
+			allow: true, envRun: "TestB", want: []string{"TestB"},
+		},
+		"EnvSkipPatternWhenEmpty": {
+			allow: true, envSkip: "TestB", want: []string{"TestA", "TestC"},
+		},
+		"NotWhenDisabled": {
+			envRun: "TestB", envSkip: "TestC", want: []string{"TestA", "TestB", "TestC"},
+		},
+		"NotOverRunnersPatterns": {
+			allow: true, match: "TestA|TestC", skip: "TestC", envRun: "TestB", envSkip: "TestA", want: []string{"TestA"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			t.Setenv(EnvRunPattern, tc.envRun)
+			t.Setenv(EnvSkipPattern, tc.envSkip)
+			var ran []string
+			test := func(name string) func(t *testing.T) {
+				return func(t *testing.T) { ran = append(ran, name) }
+			}
+			r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+				{Name: "TestA", F: test("TestA")}, {Name: "TestB", F: test("TestB")}, {Name: "TestC", F: test("TestC")},
+			}, nil, nil, nil)).(*runner)
+			defer r.PrintToStdout(printStdout)
+			r.PrintToStdout(false)
+			r.SetAllowEnvPatterns(tc.allow)
+			if tc.match != "" {
+				require.NoError(t, r.Match(tc.match))
+			}
+			require.NoError(t, r.Skip(tc.skip))
+			runIsolated(t)
+
+			// Act
+			r.Run()
+
+			// Assert
+			assert.Equal(t, tc.want, ran)
+			assert.Equal(t, tc.skip, r.EffectiveSkipPattern()) // the runner's own patterns are kept
+		})
+	}
+}
+
+func Test_SetAllowEnvPatterns_ShouldLogConsultedVariables(t *testing.T) {
+	// Arrange
+	defer setTestFlag("test.testlogfile", "")() // testing doesn't start the log
+	t.Setenv(EnvRunPattern, "TestA")
+	r := newInstance(newTestingM("TestA")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetAllowEnvPatterns(true)
+	var testLog bytes.Buffer
+	r.SetTestLogWriter(&testLog)
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Contains(t, testLog.String(), "getenv "+EnvRunPattern+"\n")
+	assert.Contains(t, testLog.String(), "getenv "+EnvSkipPattern+"\n")
+}
+
+func Test_SetAllowEnvPatterns_ShouldFailRunWithInvalidPattern(t *testing.T) {
+	// Arrange
+	t.Setenv(EnvRunPattern, "(")
+	r := newInstance(newTestingM("TestA")).(*runner)
+	r.SetAllowEnvPatterns(true)
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.False(t, r.Passed())
+	assert.Contains(t, r.RunInfo().Failure, "not running any tests: TESTDECK_RUN: ")
+}
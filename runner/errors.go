@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+errors.go: Typed errors for the failures of a Run other than failed tests, so embedders can tell them apart with
+errors.As instead of parsing RunInfo().Failure. Each wraps its cause, so errors.Is sees through them too.
+*/
+
+// PatternError is an invalid run, skip or list pattern
+type PatternError struct {
+	Source  string // where the pattern came from if not a setter, e.g. EnvRunPattern
+	Pattern string
+	Err     error // from compiling the pattern
+}
+
+func (e *PatternError) Error() string {
+	if e.Source == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Source, e.Err)
+}
+
+func (e *PatternError) Unwrap() error { return e.Err }
+
+// SetupError is a step before the tests that failed, so none of them ran
+type SetupError struct {
+	Op  string // e.g. "validating the tests"
+	Err error
+}
+
+func (e *SetupError) Error() string { return fmt.Sprintf("%s: %v", e.Op, e.Err) }
+
+func (e *SetupError) Unwrap() error { return e.Err }
+
+// TimeoutError is a Run with tests that ran past the per-test timeout of
+// SetPerTestTimeout. errors.Is sees context.DeadlineExceeded in it.
+type TimeoutError struct {
+	Timeout time.Duration
+	Tests   []string // past the timeout, in the order they were added
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("past the per-test timeout of %s: %s", e.Timeout, strings.Join(e.Tests, ", "))
+}
+
+func (e *TimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+// BudgetError is a Run with tests over the per-test budget of
+// SetPerTestBudget. It has no cause: the tests themselves didn't fail.
+type BudgetError struct {
+	Budget    time.Duration
+	Tests     []string        // over the budget, in the order they were added
+	Durations []time.Duration // of Tests
+}
+
+func (e *BudgetError) Error() string {
+	over := make([]string, len(e.Tests))
+	for i, name := range e.Tests {
+		over[i] = fmt.Sprintf("%s (%s)", name, e.Durations[i])
+	}
+	return fmt.Sprintf("over the per-test budget of %s: %s", e.Budget, strings.Join(over, ", "))
+}
+
+// CancelledError is a Run whose context (see RunContext) was done before all
+// its tests could start. errors.Is tells context.Canceled and
+// context.DeadlineExceeded apart.
+type CancelledError struct {
+	NotRun int // tests held back because of it; 0 if the Run didn't start
+	Err    error
+}
+
+func (e *CancelledError) Error() string {
+	if e.NotRun == 0 {
+		return fmt.Sprintf("the Run was cancelled: %v", e.Err)
+	}
+	return fmt.Sprintf("the Run was cancelled: %v; %d tests not run", e.Err, e.NotRun)
+}
+
+func (e *CancelledError) Unwrap() error { return e.Err }
+
+// withSource returns err with the source of its pattern set, if it is a
+// *PatternError
+func withSource(err error, source string) error {
+	var pe *PatternError
+	if errors.As(err, &pe) {
+		return &PatternError{Source: source, Pattern: pe.Pattern, Err: pe.Err}
+	}
+	return fmt.Errorf("%s: %w", source, err)
+}
+
+// Err returns why the last Run failed other than by failed tests, the error
+// behind RunInfo().Failure: a *PatternError, *SetupError, *TimeoutError,
+// *BudgetError or *CancelledError where the cause is one of those, or an error
+// with the message of the Failure otherwise. It is nil if the Run didn't fail
+// that way. A run timeout (see SetRunTimeout) is not returned: testing panics
+// from its own timer like under go test, which ends the process.
+func (r *runner) Err() error {
+	if r.runErr != nil {
+		return r.runErr
+	}
+	if r.runInfo.Failure != "" {
+		return errors.New(r.runInfo.Failure)
+	}
+	return nil
+}
+
+// failRun records err as the failure of the Run, see Err
+func (r *runner) failRun(prefix string, err error) {
+	r.runErr = err
+	r.runInfo.Failure = prefix + err.Error()
+}
+
+// RunContext is Run, returning Err. Once ctx is done, the tests that have
+// not started yet (see Admit) are skipped and no more retries start; the
+// tests already running are not stopped. A ctx that is done before the Run
+// starts runs nothing.
+func (r *runner) RunContext(ctx context.Context) error {
+	r.runCtx = ctx
+	defer func() { r.runCtx = nil }()
+	r.Run()
+	return r.Err()
+}
+
+// cancelled returns the error of the Run's context, if it is done
+func (r *runner) cancelled() error {
+	if r.runCtx == nil {
+		return nil
+	}
+	return r.runCtx.Err()
+}
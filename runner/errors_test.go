@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Match_ShouldReturnPatternError(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM())
+
+	// Act
+	err := r.Match("(")
+
+	// Assert
+	var pe *PatternError
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, "(", pe.Pattern)
+	assert.Empty(t, pe.Source)
+}
+
+func Test_RunContext_ShouldReturnTypedErrors(t *testing.T) {
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	cases := map[string]struct {
+		arrange func(t *testing.T, r *runner) context.Context
+		check   func(t *testing.T, err error)
+	}{
+		"PatternFromEnv": {
+			arrange: func(t *testing.T, r *runner) context.Context {
+				t.Setenv(EnvSkipPattern, "(")
+				r.SetAllowEnvPatterns(true)
+				return context.Background()
+			},
+			check: func(t *testing.T, err error) {
+				var pe *PatternError
+				require.True(t, errors.As(err, &pe))
+				assert.Equal(t, EnvSkipPattern, pe.Source)
+				assert.Equal(t, "(", pe.Pattern)
+			},
+		},
+		"SetupOfStrictRun": {
+			arrange: func(t *testing.T, r *runner) context.Context {
+				r.m = newTestingM("TestA", "TestA")
+				r.SetStrict(true)
+				return context.Background()
+			},
+			check: func(t *testing.T, err error) {
+				var se *SetupError
+				require.True(t, errors.As(err, &se))
+				assert.Equal(t, "validating the tests", se.Op)
+			},
+		},
+		"PastPerTestTimeout": {
+			arrange: func(t *testing.T, r *runner) context.Context {
+				r.SetPerTestTimeout(time.Second)
+				runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+					r.AddStatistics(&constants.Statistics{Name: "TestHung", Failed: true, TimeoutStacks: "goroutine 7 [chan receive]:"})
+					r.AddStatistics(&constants.Statistics{Name: "TestQuick"})
+				}
+				return context.Background()
+			},
+			check: func(t *testing.T, err error) {
+				var te *TimeoutError
+				require.True(t, errors.As(err, &te))
+				assert.Equal(t, []string{"TestHung"}, te.Tests)
+				assert.True(t, errors.Is(err, context.DeadlineExceeded))
+				assert.Equal(t, "past the per-test timeout of 1s: TestHung", err.Error())
+			},
+		},
+		"OverBudget": {
+			arrange: func(t *testing.T, r *runner) context.Context {
+				r.SetPerTestBudget(time.Second)
+				runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+					r.AddStatistics(&constants.Statistics{Name: "TestSlow", Duration: 2 * time.Second})
+				}
+				return context.Background()
+			},
+			check: func(t *testing.T, err error) {
+				var be *BudgetError
+				require.True(t, errors.As(err, &be))
+				assert.Equal(t, []string{"TestSlow"}, be.Tests)
+				assert.Equal(t, "over the per-test budget of 1s: TestSlow (2s)", err.Error())
+			},
+		},
+		"DeadlineBeforeStart": {
+			arrange: func(t *testing.T, r *runner) context.Context {
+				ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+				t.Cleanup(cancel)
+				runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+					t.Error("the Run started")
+				}
+				return ctx
+			},
+			check: func(t *testing.T, err error) {
+				var ce *CancelledError
+				require.True(t, errors.As(err, &ce))
+				assert.Equal(t, 0, ce.NotRun)
+				assert.True(t, errors.Is(err, context.DeadlineExceeded))
+			},
+		},
+		"CancelledWhileRunning": {
+			arrange: func(t *testing.T, r *runner) context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				t.Cleanup(cancel)
+				runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+					ok, _ := r.Admit("TestA")
+					assert.True(t, ok)
+					cancel()
+					ok, reason := r.Admit("TestB")
+					assert.False(t, ok)
+					assert.Equal(t, "not run: the Run was cancelled", reason)
+				}
+				return ctx
+			},
+			check: func(t *testing.T, err error) {
+				var ce *CancelledError
+				require.True(t, errors.As(err, &ce))
+				assert.Equal(t, 1, ce.NotRun)
+				assert.True(t, errors.Is(err, context.Canceled))
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			r := newInstance(newFakeTestingM()).(*runner)
+			r.PrintToStdout(false)
+			defer r.PrintToStdout(printStdout)
+			runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {}
+			ctx := tc.arrange(t, r)
+
+			// Act
+			err := r.RunContext(ctx)
+
+			// Assert
+			require.Error(t, err)
+			tc.check(t, err)
+			assert.Contains(t, r.RunInfo().Failure, err.Error())
+			assert.False(t, r.Passed())
+		})
+	}
+}
+
+func Test_Err_ShouldBeNilForPassingRun(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {}
+
+	// Act
+	err := r.RunContext(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, r.Err())
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+/*
+events.go: A JSON event stream of a run's output in the format of go test -json (see go doc test2json)
+*/
+
+// OutputEvent is an "output" event. Test is omitted for package-level output.
+type OutputEvent struct {
+	Action string
+	Test   string `json:",omitempty"`
+	Output string
+}
+
+// TestEvent is a "pass", "fail" or "skip" event of a test or subtest, whose
+// Test is its full name (e.g. "TestA/sub")
+type TestEvent struct {
+	Action  string
+	Test    string
+	Elapsed float64 // seconds, as timed by the testing package
+}
+
+// WriteOutputEvents writes an output event for each line of output (e.g. the
+// runner's Output()), stamped with the test the line belongs to. Attribution
+// works as for SetGroupOutput, so run with verbose output (-test.v) for
+// interleaved parallel tests to be told apart.
+func WriteOutputEvents(w io.Writer, output string) error {
+	return writeEvents(w, output, false)
+}
+
+// WriteTestEvents writes the output events of WriteOutputEvents and, after
+// the result line of each test and subtest, a TestEvent with the time the
+// testing package measured for it, like go test -json. The results of
+// passing subtests are only printed with verbose output (-test.v).
+func WriteTestEvents(w io.Writer, output string) error {
+	return writeEvents(w, output, true)
+}
+
+func writeEvents(w io.Writer, output string, results bool) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, line := range attributeLines(output) {
+		event := OutputEvent{Action: "output", Test: line.test, Output: line.text}
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+		if !results {
+			continue
+		}
+		if parts := reTestResult.FindStringSubmatch(line.text); parts != nil {
+			d, _ := time.ParseDuration(parts[3])
+			result := TestEvent{Action: strings.ToLower(parts[1]), Test: untagName(parts[2]), Elapsed: d.Seconds()}
+			if err := enc.Encode(result); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriteOutputEvents_ShouldStampOutputWithTest(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+
+	// Act
+	err := WriteOutputEvents(&buf, interleavedOutput)
+
+	// Assert
+	require.NoError(t, err)
+	var events []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.Equal(t, strings.Count(interleavedOutput, "\n"), len(events))
+	for _, event := range events {
+		assert.Equal(t, "output", event["Action"])
+		output := event["Output"].(string)
+		switch {
+		case strings.Contains(output, "A line"), strings.HasSuffix(output, "TestA\n"), strings.Contains(output, ": TestA "):
+			assert.Equal(t, "TestA", event["Test"], output)
+		case strings.Contains(output, "B line"), strings.HasSuffix(output, "TestB\n"), strings.Contains(output, ": TestB "):
+			assert.Equal(t, "TestB", event["Test"], output)
+		default:
+			assert.Equal(t, "FAIL\n", output)
+			assert.NotContains(t, event, "Test", "package-level output has no test")
+		}
+	}
+}
+
+func Test_WriteTestEvents_ShouldTimeEachSubtest(t *testing.T) {
+	// Arrange
+	defer setTestVerbose()()
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestParent", F: func(t *testing.T) {
+			t.Run("first", func(t *testing.T) {})
+			t.Run("second", func(t *testing.T) {
+				t.Run("nested", func(t *testing.T) {})
+			})
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	runIsolated(t)
+	r.Run()
+	var buf bytes.Buffer
+
+	// Act
+	err := WriteTestEvents(&buf, r.Output())
+
+	// Assert
+	require.NoError(t, err)
+	var names []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event struct {
+			Action  string
+			Test    string
+			Elapsed *float64
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		if event.Action == "output" {
+			continue
+		}
+		assert.Equal(t, "pass", event.Action, event.Test)
+		require.NotNil(t, event.Elapsed, event.Test)
+		assert.GreaterOrEqual(t, *event.Elapsed, 0.0, event.Test)
+		names = append(names, event.Test)
+	}
+	assert.ElementsMatch(t, []string{"TestParent", "TestParent/first", "TestParent/second", "TestParent/second/nested"}, names)
+}
+
+func Test_WriteTestEvents_ShouldUntagMatchWorkaroundNames(t *testing.T) {
+	// Arrange
+	output := "=== RUN   TestA\x00TestA\n--- PASS: TestA\x00TestA (0.00s)\n"
+	var buf bytes.Buffer
+
+	// Act
+	err := WriteTestEvents(&buf, output)
+
+	// Assert
+	require.NoError(t, err)
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event struct{ Test string }
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		assert.Equal(t, "TestA", event.Test)
+	}
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+/*
+examples.go: Running the examples given to the runner after its tests, like go test does. The examples are run by the
+testing package's own RunExamples, so their output is compared as upstream does, e.g. sorted by line for
+"// Unordered output:" (InternalExample.Unordered).
+*/
+
+// runnerRunExamples is pulled out so it can be replaced for unit testing
+var runnerRunExamples = func(examples []testing.InternalExample) (ok bool) {
+	// the examples are already selected by the runner's own patterns
+	defer setTestFlag("test.run", "")()
+	defer setTestFlag("test.skip", "")()
+	return testing.RunExamples(func(pat, str string) (bool, error) { return true, nil }, examples)
+}
+
+// selectExamples returns the examples of r.m selected by matcher and the
+// skip pattern
+func (r *runner) selectExamples(matcher *Matcher) []testing.InternalExample {
+	var examples []testing.InternalExample
+	for _, eg := range getInternalExamples(r.m) {
+		if matcher != nil {
+			if ok, partial := matcher.MatchFullName(eg.Name); !ok || partial {
+				continue
+			}
+		}
+		if r.skipMatcher != nil {
+			if skip, partial := r.skipMatcher.MatchFullName(eg.Name); skip && !partial {
+				continue
+			}
+		}
+		examples = append(examples, eg)
+	}
+	return examples
+}
+
+// runExamples runs examples, recording a failure in RunInfo().Failure if any
+// fails
+func (r *runner) runExamples(examples []testing.InternalExample) {
+	if len(examples) == 0 {
+		return
+	}
+	if !runnerRunExamples(examples) && r.runInfo.Failure == "" {
+		r.runInfo.Failure = "an example failed, see the output"
+	}
+}
+
+// getInternalExamples returns the examples of m, which has none unless it is
+// a *testing.M (see getInternalTests)
+func getInternalExamples(m TestRunner) []testing.InternalExample {
+	rs := reflect.ValueOf(m)
+	if rs.Kind() != reflect.Pointer || rs.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	rs = rs.Elem()
+	exampleType := reflect.TypeOf(testing.InternalExample{})
+	for i := 0; i < rs.NumField(); i++ {
+		rf := rs.Field(i)
+		if rf.Kind() != reflect.Slice || rf.Type().Elem() != exampleType {
+			continue
+		}
+		rf = reflect.NewAt(rf.Type(), unsafe.Pointer(rf.UnsafeAddr())).Elem()
+		return append([]testing.InternalExample(nil), rf.Interface().([]testing.InternalExample)...)
+	}
+	return nil
+}
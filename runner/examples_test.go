@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newExamplesRunner returns a runner with no tests and the given examples
+func newExamplesRunner(t *testing.T, examples ...testing.InternalExample) *runner {
+	r := newInstance(testing.MainStart(&TestDeps{}, nil, nil, nil, examples)).(*runner)
+	t.Cleanup(func() { r.PrintToStdout(printStdout) })
+	r.PrintToStdout(false)
+	prev := runnerMainStart
+	t.Cleanup(func() { runnerMainStart = prev })
+	// no tests to run; testing.RunTests would warn about that
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {}
+	return r
+}
+
+func Test_Run_ShouldPassUnorderedExampleWithShuffledOutput(t *testing.T) {
+	// Arrange
+	r := newExamplesRunner(t, testing.InternalExample{
+		Name:      "ExampleShuffled",
+		F:         func() { fmt.Println("c\nalpha\nb") },
+		Output:    "alpha\nb\nc\n",
+		Unordered: true,
+	})
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.True(t, r.Passed(), r.RunInfo().Failure)
+	assert.NotContains(t, r.Output(), "--- FAIL")
+}
+
+func Test_Run_ShouldFailOrderedExampleWithShuffledOutput(t *testing.T) {
+	// Arrange
+	r := newExamplesRunner(t, testing.InternalExample{
+		Name:   "ExampleShuffled",
+		F:      func() { fmt.Println("c\nalpha\nb") },
+		Output: "alpha\nb\nc\n",
+	})
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.False(t, r.Passed())
+	assert.Contains(t, r.Output(), "--- FAIL: ExampleShuffled")
+}
+
+func Test_Run_ShouldSelectExamplesByPatterns(t *testing.T) {
+	// Arrange
+	var ran []string
+	example := func(name string) testing.InternalExample {
+		return testing.InternalExample{Name: name, F: func() { ran = append(ran, name) }}
+	}
+	r := newExamplesRunner(t, example("ExampleParse"), example("ExampleParse_slow"), example("ExampleFormat"))
+	assert.NoError(t, r.Match("ExampleParse"))
+	assert.NoError(t, r.Skip("_slow$"))
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, []string{"ExampleParse"}, ran)
+}
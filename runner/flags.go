@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"flag"
+	"strconv"
+)
+
+/*
+flags.go: Mapping between the runner's settings and the testing package's -test.* flags.
+The testing package reads its flags at the start of every m.Run, so the runner overrides them for the duration of a Run.
+*/
+
+// ConfigFromFlags registers the familiar go test flags (-run, -skip, -list,
+// -timeout, -failfast, -count, -parallel, -cpuprofile, -v) on fs, plus -stdout
+// for PrintToStdout, parses args and returns the resulting settings, to be
+// applied with Runner.FromWire. Like go test, -v is off by default.
+func ConfigFromFlags(fs *flag.FlagSet, args []string) (WireConfig, error) {
+	run := fs.String("run", ".*", "run only tests matching `regexp`")
+	skip := fs.String("skip", "", "do not run tests matching `regexp`")
+	list := fs.String("list", "", "list tests matching `regexp` instead of running them")
+	timeout := fs.Duration("timeout", 0, "panic after duration `d` (0 means the go test default)")
+	failfast := fs.Bool("failfast", false, "do not start new tests after the first test failure")
+	count := fs.Int("count", 1, "run each test `n` times (0 runs nothing)")
+	parallel := fs.Int("parallel", 0, "run at most `n` tests in parallel (0 means GOMAXPROCS)")
+	cpuprofile := fs.String("cpuprofile", "", "write a cpu profile to `file`")
+	verbose := fs.Bool("v", false, "verbose: log all tests as they are run")
+	stdout := fs.Bool("stdout", true, "print test output to stdout")
+
+	if err := fs.Parse(args); err != nil {
+		return WireConfig{}, err
+	}
+
+	c := WireConfig{
+		MatchPattern:   *run,
+		SkipPattern:    *skip,
+		List:           *list,
+		RunTimeout:     *timeout,
+		Count:          *count,
+		Parallel:       *parallel,
+		CPUProfile:     *cpuprofile,
+		SuppressStdout: !*stdout,
+		Verbose:        *verbose,
+	}
+	c.FailFast = *failfast
+	if *count == 0 {
+		c.Count = -1 // WireConfig's zero Count keeps the command line -count
+	}
+	if _, err := NewMatcher(c.MatchPattern); err != nil {
+		return WireConfig{}, err
+	}
+	if _, err := NewMatcher(c.SkipPattern); err != nil {
+		return WireConfig{}, err
+	}
+	if _, err := NewMatcher(c.List); err != nil {
+		return WireConfig{}, err
+	}
+	return c, nil
+}
+
+// setTestFlag overrides one of the testing package's -test.* flags. The
+// returned func restores the previous value. Unknown flags are ignored.
+func setTestFlag(name string, value string) (restore func()) {
+	// m.Run parses the command line if it hasn't been yet, which would
+	// overwrite the value set here
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+	f := flag.Lookup(name)
+	if f == nil {
+		return func() {}
+	}
+	prev := f.Value.String()
+	f.Value.Set(value)
+	return func() {
+		f.Value.Set(prev)
+	}
+}
+
+// setTestFlags overrides the -test.* flags for the runner's settings that
+// testing itself implements. Unset (zero) settings keep the command line
+// values. -test.paniconexit0 is always turned on.
+func (r *runner) setTestFlags() (restore func()) {
+	var restores []func()
+	if r.runTimeout > 0 {
+		// testing arms its alarm and computes t.Deadline() from this
+		restores = append(restores, setTestFlag("test.timeout", r.runTimeout.String()))
+	}
+	if r.parallel > 0 {
+		restores = append(restores, setTestFlag("test.parallel", strconv.Itoa(r.parallel)))
+	}
+	if r.count > 0 {
+		restores = append(restores, setTestFlag("test.count", strconv.Itoa(r.count)))
+	}
+	if r.artifacts != nil && r.artifacts.CPUProfile != "" {
+		restores = append(restores, setTestFlag("test.cpuprofile", r.artifacts.CPUProfile))
+	} else if r.cpuProfile != "" {
+		restores = append(restores, setTestFlag("test.cpuprofile", r.cpuProfile))
+	}
+	if r.verbose || r.detectRaces {
+		restores = append(restores, setTestVerbose())
+	}
+	// always on, like go test does, so an os.Exit(0) in a test is a failure (see osexit.go)
+	restores = append(restores, setTestFlag("test.paniconexit0", "true"))
+	return func() {
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+	}
+}
+
+// setTestVerbose turns on the -test.v flag so every result is printed
+func setTestVerbose() (restore func()) {
+	return setTestFlag("test.v", "true")
+}
@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ConfigFromFlags_ShouldMapGoTestFlags(t *testing.T) {
+	// Arrange
+	fs := flag.NewFlagSet("embedder", flag.ContinueOnError)
+	args := []string{
+		"-run", "TestParent/TestChild",
+		"-skip", "TestSlow",
+		"-list", "TestParent",
+		"-timeout", "2m",
+		"-failfast",
+		"-count", "3",
+		"-parallel", "8",
+		"-cpuprofile", "cpu.out",
+		"-v",
+		"-stdout=false",
+		"extra",
+	}
+
+	// Act
+	c, err := ConfigFromFlags(fs, args)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, WireConfig{
+		MatchPattern:   "TestParent/TestChild",
+		SkipPattern:    "TestSlow",
+		List:           "TestParent",
+		RunTimeout:     2 * time.Minute,
+		Count:          3,
+		Parallel:       8,
+		CPUProfile:     "cpu.out",
+		FailFast:       true,
+		SuppressStdout: true,
+		Verbose:        true,
+	}, c)
+	assert.Equal(t, []string{"extra"}, fs.Args())
+}
+
+func Test_ConfigFromFlags_ShouldUseDefaults(t *testing.T) {
+	// Arrange
+	fs := flag.NewFlagSet("embedder", flag.ContinueOnError)
+
+	// Act
+	c, err := ConfigFromFlags(fs, nil)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, WireConfig{MatchPattern: ".*", Count: 1}, c)
+}
+
+func Test_ConfigFromFlags_ShouldRejectInvalidPattern(t *testing.T) {
+	// Arrange
+	fs := flag.NewFlagSet("embedder", flag.ContinueOnError)
+
+	// Act
+	_, err := ConfigFromFlags(fs, []string{"-run", "TestA/("})
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func Test_SetTestFlags_ShouldOverrideAndRestoreFlags(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	r.SetRunTimeout(90 * time.Second)
+	r.SetParallel(3)
+	r.SetCount(2)
+	prevTimeout := flag.Lookup("test.timeout").Value.String()
+	prevParallel := flag.Lookup("test.parallel").Value.String()
+	prevCount := flag.Lookup("test.count").Value.String()
+	prevProfile := flag.Lookup("test.cpuprofile").Value.String()
+
+	// Act
+	restore := r.setTestFlags()
+	gotTimeout := flag.Lookup("test.timeout").Value.String()
+	gotParallel := flag.Lookup("test.parallel").Value.String()
+	gotCount := flag.Lookup("test.count").Value.String()
+	gotProfile := flag.Lookup("test.cpuprofile").Value.String()
+	gotPanicOnExit0 := flag.Lookup("test.paniconexit0").Value.String()
+	restore()
+
+	// Assert
+	assert.Equal(t, "1m30s", gotTimeout)
+	assert.Equal(t, "3", gotParallel)
+	assert.Equal(t, "2", gotCount)
+	assert.Equal(t, prevProfile, gotProfile, "unset settings keep the command line value")
+	assert.Equal(t, "true", gotPanicOnExit0)
+	assert.Equal(t, prevTimeout, flag.Lookup("test.timeout").Value.String())
+	assert.Equal(t, prevParallel, flag.Lookup("test.parallel").Value.String())
+	assert.Equal(t, prevCount, flag.Lookup("test.count").Value.String())
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"runtime"
+	"time"
+)
+
+/*
+goroutines.go: Sampling the number of goroutines during a Run to record its peak, a cheap trend metric for runaway
+goroutine growth that doesn't attribute the goroutines to tests like a leak check would
+*/
+
+// defaultGoroutineSampleInterval is how often the goroutines are counted if
+// SetGoroutineSampleInterval isn't set
+const defaultGoroutineSampleInterval = 10 * time.Millisecond
+
+// GoroutinePeak is the highest number of goroutines sampled during a Run
+type GoroutinePeak struct {
+	Count int       `json:"count"` // not counting the sampler
+	At    time.Time `json:"at"`    // when it was first sampled
+}
+
+// SetTrackPeakGoroutines makes each Run sample runtime.NumGoroutine() while it
+// runs, at the interval set with SetGoroutineSampleInterval, and record the
+// peak in RunInfo().PeakGoroutines and the Result. A spike shorter than the
+// interval can be missed.
+func (r *runner) SetTrackPeakGoroutines(yes bool) {
+	r.peakRoutines = yes
+}
+
+// SetGoroutineSampleInterval sets how often SetTrackPeakGoroutines counts the
+// goroutines; 0 means every 10ms
+func (r *runner) SetGoroutineSampleInterval(d time.Duration) {
+	r.goSampleTick = d
+}
+
+// sampleGoroutines starts sampling the goroutines for SetTrackPeakGoroutines
+// until stop is called, which records the peak in the RunInfo
+func (r *runner) sampleGoroutines() (stop func()) {
+	if !r.peakRoutines {
+		return func() {}
+	}
+	interval := r.goSampleTick
+	if interval <= 0 {
+		interval = defaultGoroutineSampleInterval
+	}
+
+	peak := GoroutinePeak{Count: runtime.NumGoroutine(), At: r.clock.Now()}
+	sample := func() {
+		// the sampler is running too
+		if n := runtime.NumGoroutine() - 1; n > peak.Count {
+			peak = GoroutinePeak{Count: n, At: r.clock.Now()}
+		}
+	}
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	tick, stopTicker := r.clock.NewTicker(interval)
+	go func() {
+		defer close(done)
+		defer stopTicker()
+		for {
+			select {
+			case <-tick:
+				sample()
+			case <-quit:
+				sample()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-done
+		r.mu.Lock()
+		r.runInfo.PeakGoroutines = &peak
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SetTrackPeakGoroutines_ShouldRecordSpikeOfTest(t *testing.T) {
+	// Arrange
+	const spike = 50
+	before := runtime.NumGoroutine()
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestSpike", F: func(t *testing.T) {
+			release := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < spike; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-release
+				}()
+			}
+			time.Sleep(20 * time.Millisecond) // a few samples
+			close(release)
+			wg.Wait()
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetTrackPeakGoroutines(true)
+	r.SetGoroutineSampleInterval(time.Millisecond)
+	ok := runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.True(t, *ok)
+	peak := r.RunInfo().PeakGoroutines
+	require.NotNil(t, peak)
+	assert.GreaterOrEqual(t, peak.Count, before+spike)
+	assert.False(t, peak.At.Before(r.RunInfo().StartedAt))
+	assert.False(t, peak.At.After(r.RunInfo().FinishedAt))
+	assert.Equal(t, peak, r.Result().PeakGoroutines)
+}
+
+func Test_SetTrackPeakGoroutines_ShouldSampleOnClockTicks(t *testing.T) {
+	// Arrange
+	const spike = 50
+	before := runtime.NumGoroutine()
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestSpike", F: func(t *testing.T) {
+			release := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < spike; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-release
+				}()
+			}
+			clock.now = clock.now.Add(time.Minute)
+			clock.ticks[0] <- clock.now
+			clock.ticks[0] <- clock.now // returns once the first sample is taken
+			close(release)
+			wg.Wait()
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.setClock(clock)
+	r.SetTrackPeakGoroutines(true)
+	r.SetGoroutineSampleInterval(time.Hour) // only the fake ticks
+	ok := runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.True(t, *ok)
+	peak := r.RunInfo().PeakGoroutines
+	require.NotNil(t, peak)
+	assert.GreaterOrEqual(t, peak.Count, before+spike)
+	assert.Equal(t, time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC), peak.At)
+}
+
+func Test_SetTrackPeakGoroutines_ShouldNotSampleWhenOff(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Nil(t, r.RunInfo().PeakGoroutines)
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+/*
+heap.go: Holding back parallel tests while the heap is above a watermark, for resource-heavy suites on constrained machines
+*/
+
+// HeapBackoff configures waiting for the heap to shrink before starting a
+// parallel test (see SetHeapBackoff)
+type HeapBackoff struct {
+	HighWatermarkBytes uint64 // 0 turns the backoff off
+}
+
+// heapPollInterval is how often the heap is checked again while backing off
+const heapPollInterval = 50 * time.Millisecond
+
+// This is pulled out so it can be replaced for unit testing
+var readHeapInuse = func() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapInuse
+}
+
+// SetHeapBackoff makes each parallel test wait, before it starts, until the
+// heap in use (runtime.MemStats.HeapInuse) is at most b.HighWatermarkBytes.
+// The heap is polled while waiting, and the total time waited is recorded in
+// RunInfo().HeapBackoff.
+func (r *runner) SetHeapBackoff(b HeapBackoff) {
+	r.heapBackoff = b
+}
+
+// BackOffForHeap waits until the heap is below the watermark set with
+// SetHeapBackoff and returns how long it waited. testdeck.Test calls it for
+// parallel tests once they are admitted.
+func (r *runner) BackOffForHeap(name string) time.Duration {
+	watermark := r.heapBackoff.HighWatermarkBytes
+	if watermark == 0 {
+		return 0
+	}
+
+	var waited time.Duration
+	for inuse := readHeapInuse(); inuse > watermark; inuse = readHeapInuse() {
+		if waited == 0 {
+			r.LogEvent(fmt.Sprintf("Holding back %s: heap in use %d bytes is above %d", name, inuse, watermark))
+		}
+		r.clock.Sleep(heapPollInterval)
+		waited += heapPollInterval
+	}
+
+	if waited > 0 {
+		r.mu.Lock()
+		r.runInfo.HeapBackoff += waited
+		r.mu.Unlock()
+	}
+	return waited
+}
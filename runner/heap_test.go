@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHeap replaces readHeapInuse with one that returns readings in turn,
+// repeating the last one
+func fakeHeap(t *testing.T, readings ...uint64) (reads *int) {
+	reads = new(int)
+	prev := readHeapInuse
+	t.Cleanup(func() { readHeapInuse = prev })
+	readHeapInuse = func() uint64 {
+		i := *reads
+		*reads++
+		if i >= len(readings) {
+			i = len(readings) - 1
+		}
+		return readings[i]
+	}
+	return reads
+}
+
+func Test_BackOffForHeap_ShouldWaitUntilHeapDropsBelowWatermark(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestHeavy")).(*runner)
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r.setClock(clock)
+	r.SetHeapBackoff(HeapBackoff{HighWatermarkBytes: 100})
+	reads := fakeHeap(t, 300, 200, 101, 100)
+
+	// Act
+	waited := r.BackOffForHeap("TestHeavy")
+
+	// Assert
+	assert.Equal(t, 3*heapPollInterval, waited)
+	assert.Equal(t, []time.Duration{heapPollInterval, heapPollInterval, heapPollInterval}, clock.sleeps)
+	assert.Equal(t, 4, *reads)
+	assert.Equal(t, 3*heapPollInterval, r.RunInfo().HeapBackoff)
+}
+
+func Test_BackOffForHeap_ShouldNotWaitBelowWatermark(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestLight")).(*runner)
+	clock := &fakeClock{}
+	r.setClock(clock)
+	r.SetHeapBackoff(HeapBackoff{HighWatermarkBytes: 100})
+	fakeHeap(t, 50)
+
+	// Act
+	waited := r.BackOffForHeap("TestLight")
+
+	// Assert
+	assert.Zero(t, waited)
+	assert.Empty(t, clock.sleeps)
+	assert.Zero(t, r.RunInfo().HeapBackoff)
+}
+
+func Test_BackOffForHeap_ShouldBeOffByDefault(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestHeavy")).(*runner)
+	reads := fakeHeap(t, 1<<40)
+
+	// Act
+	waited := r.BackOffForHeap("TestHeavy")
+
+	// Assert
+	assert.Zero(t, waited)
+	assert.Zero(t, *reads)
+}
+
+func Test_Run_ShouldRecordTotalHeapBackoff(t *testing.T) {
+	// Arrange
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	r := newInstance(newTestingM("TestA", "TestB")).(*runner)
+	clock := &fakeClock{}
+	r.setClock(clock)
+	r.SetHeapBackoff(HeapBackoff{HighWatermarkBytes: 100})
+	fakeHeap(t, 500, 50, 500, 500, 50)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for _, test := range tests {
+			_, _, name := MatchTag(test.Name)
+			r.BackOffForHeap(name)
+		}
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, 3*heapPollInterval, r.RunInfo().HeapBackoff)
+}
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+/*
+hooks.go: Setup and teardown hooks run around the top-level tests matching a pattern, e.g. for a group of integration tests
+that share a fixture
+*/
+
+// scopes of the hooks registered with HookFor, see SetHookScope
+const (
+	HookScopeRun       = "run"
+	HookScopeIteration = "iteration"
+)
+
+// testHook is a hook registered with HookFor
+type testHook struct {
+	pattern  string
+	matcher  *Matcher
+	setup    func(ctx context.Context, name string) error
+	teardown func(ctx context.Context, name string) error
+}
+
+// HookFor registers setup and teardown (either may be nil) to run around each
+// top-level test whose name fully matches pattern, with the test's Context and
+// name, once per Run or once per iteration of SetCount (see SetHookScope).
+// The setups of all matching hooks run in registration order before the test
+// and their teardowns in reverse order after it and its subtests. If a setup
+// fails the test fails with its error without running, and only the hooks set
+// up so far are torn down. Like SetContextValues, hooks are kept in memory
+// only and are not part of WireConfig.
+func (r *runner) HookFor(pattern string, setup, teardown func(ctx context.Context, name string) error) error {
+	m, err := NewMatcher(pattern)
+	if err != nil {
+		return err
+	}
+	r.hooks = append(r.hooks, testHook{pattern: pattern, matcher: m, setup: setup, teardown: teardown})
+	return nil
+}
+
+// SetHookScope sets whether the hooks registered with HookFor run once
+// around all the runs of a test with SetCount (or -test.count) above 1,
+// HookScopeRun, or around each of them, HookScopeIteration. With
+// HookScopeRun, the default, an expensive fixture is set up in the first
+// iteration (with its Context) and torn down after the last one, and a failed
+// setup fails every iteration. If the Run stops before the last iteration,
+// e.g. with -test.failfast, the fixture is torn down at the end of the Run
+// instead, with a context carrying only the context values, and a failed
+// teardown is a warning. With a count of 1 both scopes are the same.
+func (r *runner) SetHookScope(scope string) error {
+	if err := checkHookScope(scope); err != nil {
+		return err
+	}
+	r.hookScope = scope
+	return nil
+}
+
+func checkHookScope(scope string) error {
+	switch scope {
+	case "", HookScopeRun, HookScopeIteration:
+		return nil
+	}
+	return fmt.Errorf("unknown hook scope %q, want %q or %q", scope, HookScopeRun, HookScopeIteration)
+}
+
+// hookTests wraps the tests matched by hooks so the hooks run around them.
+// finishHooks tears down what the wrapped tests leave set up.
+func (r *runner) hookTests(tests []testing.InternalTest) []testing.InternalTest {
+	r.hookFinish = nil
+	if len(r.hooks) == 0 {
+		return tests
+	}
+	iterations := 1
+	if r.hookScope != HookScopeIteration {
+		iterations = r.effectiveCount()
+	}
+
+	hooked := make([]testing.InternalTest, len(tests))
+	for i, test := range tests {
+		hooked[i] = test
+		// the match workaround tags names, so match against the actual name
+		_, _, name := MatchTag(test.Name)
+		var hooks []testHook
+		for _, h := range r.hooks {
+			if ok, partial := h.matcher.MatchFullName(name); ok && !partial {
+				hooks = append(hooks, h)
+			}
+		}
+		if len(hooks) > 0 {
+			var finish func(ctx context.Context) []error
+			hooked[i].F, finish = withHooks(name, hooks, iterations, test.F)
+			r.hookFinish = append(r.hookFinish, finish)
+		}
+	}
+	return hooked
+}
+
+// finishHooks tears down the hooks left set up by a Run that stopped before
+// the last iteration of their test
+func (r *runner) finishHooks() {
+	ctx := context.Background()
+	for key, value := range r.ctxValues {
+		ctx = context.WithValue(ctx, key, value)
+	}
+	for _, finish := range r.hookFinish {
+		for _, err := range finish(ctx) {
+			r.addWarning(err.Error())
+		}
+	}
+	r.hookFinish = nil
+}
+
+// effectiveCount returns how many times a Run runs each test, see SetCount
+func (r *runner) effectiveCount() int {
+	if r.count > 0 {
+		return r.count
+	}
+	if f := flag.Lookup("test.count"); f != nil {
+		if n, err := strconv.Atoi(f.Value.String()); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 1
+}
+
+// withHooks returns f run between the setups and teardowns of hooks. They are
+// set up in the first of each series of iterations runs of f and torn down in
+// the last; the runs of a test with -test.count follow each other. finish
+// tears down the hooks of a series that stopped before its last run.
+func withHooks(name string, hooks []testHook, iterations int, f func(t *testing.T)) (hooked func(t *testing.T), finish func(ctx context.Context) []error) {
+	iteration := 0
+	var setUp []testHook // the hooks whose setup succeeded
+	var setupErr error
+	finish = func(ctx context.Context) []error {
+		if iteration == 0 {
+			return nil // not started, or torn down by the last run
+		}
+		iteration = 0
+		var errs []error
+		for i := len(setUp) - 1; i >= 0; i-- {
+			h := setUp[i]
+			if h.teardown == nil {
+				continue
+			}
+			if err := h.teardown(ctx, name); err != nil {
+				errs = append(errs, fmt.Errorf("teardown hook for %q of %s failed: %v", h.pattern, name, err))
+			}
+		}
+		setUp = nil
+		return errs
+	}
+	hooked = func(t *testing.T) {
+		iteration++
+		first, last := iteration == 1, iteration == iterations
+		if last {
+			iteration = 0
+		}
+
+		ctx := Context(t)
+		if first {
+			setUp, setupErr = nil, nil
+			for _, h := range hooks {
+				if h.setup != nil {
+					if err := h.setup(ctx, name); err != nil {
+						setupErr = fmt.Errorf("setup hook for %q failed, not running the test: %v", h.pattern, err)
+						break
+					}
+				}
+				setUp = append(setUp, h)
+			}
+		}
+		if last {
+			for _, h := range setUp {
+				if h.teardown != nil {
+					// cleanups run last-in first-out and after parallel subtests
+					h := h
+					t.Cleanup(func() {
+						if err := h.teardown(ctx, name); err != nil {
+							t.Errorf("teardown hook for %q failed: %v", h.pattern, err)
+						}
+					})
+				}
+			}
+		}
+		if setupErr != nil {
+			t.Error(setupErr)
+			return
+		}
+		f(t)
+	}
+	return hooked, finish
+}
@@ -0,0 +1,205 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runIsolated replaces runnerMainStart with one that runs the tests with
+// testing.RunTests, so their failures don't fail the calling test, and
+// returns whether they all passed
+func runIsolated(t *testing.T) *bool {
+	prev := runnerMainStart
+	t.Cleanup(func() { runnerMainStart = prev })
+	t.Cleanup(setTestFlag("test.count", "1")) // not the outer go test's -count
+	ok := new(bool)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		*ok = testing.RunTests(func(pat, str string) (bool, error) { return true, nil }, tests)
+	}
+	return ok
+}
+
+func Test_HookFor_ShouldRunHooksAroundMatchingTestsInOrder(t *testing.T) {
+	// Arrange
+	var events []string
+	hook := func(label string) func(ctx context.Context, name string) error {
+		return func(ctx context.Context, name string) error {
+			events = append(events, label+" "+name)
+			return nil
+		}
+	}
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestDBUsers", F: func(t *testing.T) { events = append(events, "run TestDBUsers") }},
+		{Name: "TestOther", F: func(t *testing.T) { events = append(events, "run TestOther") }},
+	}, nil, nil, nil))
+	require.NoError(t, r.HookFor("^TestDB", hook("setup db"), hook("teardown db")))
+	require.NoError(t, r.HookFor("Users$", hook("setup users"), hook("teardown users")))
+	ok := runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.True(t, *ok)
+	assert.Equal(t, []string{
+		"setup db TestDBUsers",
+		"setup users TestDBUsers",
+		"run TestDBUsers",
+		"teardown users TestDBUsers",
+		"teardown db TestDBUsers",
+		"run TestOther",
+	}, events)
+}
+
+func Test_HookFor_ShouldNotRunTestWhenSetupFails(t *testing.T) {
+	// Arrange
+	var events []string
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestDB", F: func(t *testing.T) { events = append(events, "run") }},
+	}, nil, nil, nil))
+	require.NoError(t, r.HookFor("TestDB", nil, func(ctx context.Context, name string) error {
+		events = append(events, "teardown first")
+		return nil
+	}))
+	require.NoError(t, r.HookFor("TestDB", func(ctx context.Context, name string) error {
+		return errors.New("no database")
+	}, func(ctx context.Context, name string) error {
+		events = append(events, "teardown second")
+		return nil
+	}))
+	ok := runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.False(t, *ok)
+	assert.Equal(t, []string{"teardown first"}, events)
+}
+
+func Test_SetHookScope_ShouldRunHooksOncePerScope(t *testing.T) {
+	cases := map[string]struct {
+		scope      string
+		wantEvents []string
+	}{
+		"Run": {
+			scope:      HookScopeRun,
+			wantEvents: []string{"setup", "run", "run", "run", "teardown"},
+		},
+		"Iteration": {
+			scope:      HookScopeIteration,
+			wantEvents: []string{"setup", "run", "teardown", "setup", "run", "teardown", "setup", "run", "teardown"},
+		},
+		"DefaultIsRun": {
+			scope:      "",
+			wantEvents: []string{"setup", "run", "run", "run", "teardown"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			var events []string
+			event := func(label string) func(ctx context.Context, name string) error {
+				return func(ctx context.Context, name string) error {
+					events = append(events, label)
+					return nil
+				}
+			}
+			r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+				{Name: "TestDB", F: func(t *testing.T) { events = append(events, "run") }},
+			}, nil, nil, nil)).(*runner)
+			require.NoError(t, r.HookFor("TestDB", event("setup"), event("teardown")))
+			require.NoError(t, r.SetHookScope(tc.scope))
+			r.SetCount(3)
+			ok := runIsolated(t)
+
+			// Act
+			r.Run()
+
+			// Assert
+			assert.True(t, *ok)
+			assert.Equal(t, tc.wantEvents, events)
+		})
+	}
+}
+
+func Test_SetHookScope_ShouldFailEveryIterationWhenSetupFails(t *testing.T) {
+	// Arrange
+	setups, runs := 0, 0
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestDB", F: func(t *testing.T) { runs++ }},
+	}, nil, nil, nil)).(*runner)
+	require.NoError(t, r.HookFor("TestDB", func(ctx context.Context, name string) error {
+		setups++
+		return errors.New("no database")
+	}, nil))
+	r.SetCount(3)
+	ok := runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.False(t, *ok)
+	assert.Equal(t, 1, setups)
+	assert.Equal(t, 0, runs)
+}
+
+func Test_SetHookScope_ShouldTearDownWhenIterationsAreCutShort(t *testing.T) {
+	// Arrange
+	var events []string
+	event := func(label string) func(ctx context.Context, name string) error {
+		return func(ctx context.Context, name string) error {
+			events = append(events, label)
+			return nil
+		}
+	}
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestDB", F: func(t *testing.T) { events = append(events, "run") }},
+	}, nil, nil, nil)).(*runner)
+	require.NoError(t, r.HookFor("TestDB", event("setup"), event("teardown")))
+	r.SetCount(3)
+	ok := runIsolated(t)
+	isolated := runnerMainStart
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		// stop after the first iteration, as with -test.failfast after a failure
+		defer setTestFlag("test.count", "1")()
+		isolated(deps, tests)
+	}
+
+	// Act
+	r.Run()
+	r.Run() // sets up again
+
+	// Assert
+	assert.True(t, *ok)
+	assert.Equal(t, []string{"setup", "run", "teardown", "setup", "run", "teardown"}, events)
+}
+
+func Test_SetHookScope_ShouldRejectUnknownScope(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+
+	// Act
+	err := r.SetHookScope("suite")
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func Test_HookFor_ShouldRejectInvalidPattern(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+
+	// Act
+	err := r.HookFor("(", nil, nil)
+
+	// Assert
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+)
+
+/*
+list.go: Listing the tests a run would select instead of running them, like go test -list
+*/
+
+// SetList makes Run print the names of the top-level tests matching pattern
+// that it would run (see ShouldRun), one per line, instead of running any
+// test. Like go test -list, subtests are not listed since they are only known
+// once their parent runs, and no statistics are added. An empty pattern turns
+// listing off.
+func (r *runner) SetList(pattern string) error {
+	if pattern == "" {
+		r.listPattern = ""
+		r.listMatcher = nil
+		return nil
+	}
+	m, err := NewMatcher(pattern)
+	if err != nil {
+		return err
+	}
+	r.listPattern = pattern
+	r.listMatcher = m
+	return nil
+}
+
+// SetListOutput sets where the names listed by SetList are printed, the
+// runner's output (see SetOutput) by default
+func (r *runner) SetListOutput(w io.Writer) {
+	r.listOutput = w
+}
+
+// listTests prints the names of the tests matching the SetList pattern that
+// a Run would run (see ShouldRun)
+func (r *runner) listTests() error {
+	w := r.listOutput
+	if w == nil {
+		w = r.stdout()
+	}
+	for _, test := range r.allTests() {
+		// a partial match only selects the test to reach its subtests
+		if ok, partial := r.listMatcher.MatchFullName(test.Name); ok && !partial {
+			if run, _ := r.ShouldRun(test.Name); !run {
+				continue
+			}
+			if _, err := fmt.Fprintln(w, test.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Run_ShouldListMatchingTestsWithoutRunningThem(t *testing.T) {
+	// Arrange
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	ran := false
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) { ran = true }
+	r := newInstance(newTestingM("TestLogin", "TestLogout", "TestSignup", "TestLoginFails")).(*runner)
+	require.NoError(t, r.SetList("^TestLog(in|out)$"))
+	var buf bytes.Buffer
+	r.SetListOutput(&buf)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.False(t, ran)
+	assert.Equal(t, "TestLogin\nTestLogout\n", buf.String())
+	assert.Empty(t, r.Statistics())
+	assert.True(t, r.Passed())
+}
+
+func Test_Run_ShouldNotListPartialMatches(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestParent", "TestOther")).(*runner)
+	require.NoError(t, r.SetList("TestParent/TestChild"))
+	var buf bytes.Buffer
+	r.SetListOutput(&buf)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Empty(t, buf.String())
+}
+
+func Test_SetList_ShouldRejectInvalidPattern(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA")).(*runner)
+
+	// Act
+	err := r.SetList("TestA/(")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, r.listMatcher)
+}
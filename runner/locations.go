@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"regexp"
+)
+
+/*
+locations.go: The file:line locations of the messages of failed tests, as the testing package printed them.
+testing already skips the frames of helpers that call t.Helper, so the printed location is used as is, not recomputed.
+*/
+
+// reMessageLocation matches the location testing puts before each message a
+// test logs with t.Log, t.Error etc., indented by the test's level
+var reMessageLocation = regexp.MustCompile(`^\s+([^\s:]+\.go:\d+): `)
+
+// recordFailureLocations sets the FailureLocations of the failed tests of the
+// statistics from first on from output
+func (r *runner) recordFailureLocations(first int, output string) {
+	var locations map[string][]string
+	for i := first; i < len(r.stats); i++ {
+		if !r.stats[i].Failed {
+			continue
+		}
+		if locations == nil {
+			locations = messageLocations(output)
+		}
+		r.stats[i].FailureLocations = locations[r.stats[i].Name]
+	}
+}
+
+// messageLocations returns the distinct locations of the messages in output,
+// in order, by test
+func messageLocations(output string) map[string][]string {
+	locations := make(map[string][]string)
+	for _, line := range attributeLines(output) {
+		if line.test == "" {
+			continue
+		}
+		parts := reMessageLocation.FindStringSubmatch(line.text)
+		if parts == nil || containsString(locations[line.test], parts[1]) {
+			continue
+		}
+		locations[line.test] = append(locations[line.test], parts[1])
+	}
+	return locations
+}
@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requirePositive fails t like an assertion helper
+func requirePositive(t *testing.T, n int) {
+	t.Helper()
+	if n <= 0 {
+		t.Errorf("%d is not positive", n)
+	}
+}
+
+func Test_Runner_ShouldRecordHelperAdjustedFailureLocation(t *testing.T) {
+	// Arrange
+	var line int
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestHelperFails", F: func(t *testing.T) {
+			_, _, line, _ = runtime.Caller(0)
+			requirePositive(t, -1) // the line after
+		}},
+		{Name: "TestPasses", F: func(t *testing.T) {
+			t.Log("logged")
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetVerbose(true)
+	r.parseResults = true // the tests don't use testdeck.Test
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	stats := r.Statistics()
+	require.Len(t, stats, 2)
+	assert.Equal(t, []string{fmt.Sprintf("locations_test.go:%d", line+1)}, stats[0].FailureLocations)
+	assert.Empty(t, stats[1].FailureLocations) // only for failed tests
+}
+
+func Test_MessageLocations_ShouldReadLocationsByTest(t *testing.T) {
+	// Arrange
+	output := "=== RUN   TestA\n" +
+		"    a_test.go:10: first\n" +
+		"        continued: not.go:1: a location\n" +
+		"=== RUN   TestA/Sub\n" +
+		"    a_test.go:12: in the subtest\n" +
+		"--- FAIL: TestA (0.00s)\n" +
+		"    a_test.go:10: first again\n" +
+		"    a_test.go:14: second\n" +
+		"FAIL\n"
+
+	// Act
+	locations := messageLocations(output)
+
+	// Assert
+	assert.Equal(t, map[string][]string{
+		"TestA":     {"a_test.go:10", "a_test.go:14"},
+		"TestA/Sub": {"a_test.go:12"},
+	}, locations)
+}
@@ -13,11 +13,14 @@ import "sync/atomic"
 // The os package will invoke the interface's methods to indicate that
 // it is inspecting the given environment variables or files.
 // Multiple goroutines may call these methods simultaneously.
+// Close is not in the original; it pairs the files that are opened with
+// their closes.
 type Interface interface {
 	Getenv(key string)
 	Stat(file string)
 	Open(file string)
 	Chdir(dir string)
+	Close(file string)
 }
 
 // logger is the current logger Interface.
@@ -67,3 +70,10 @@ func Stat(name string) {
 		log.Stat(name)
 	}
 }
+
+// Close calls Logger().Close, if a logger has been set.
+func Close(name string) {
+	if log := Logger(); log != nil {
+		log.Close(name)
+	}
+}
@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TestLog_ShouldPairOpenAndClose(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	deps := TestDeps{}
+	deps.StartTestLog(&buf)
+
+	// Act
+	Open("testdata/a.txt")
+	Stat("testdata/b.txt")
+	Close("testdata/a.txt")
+	Close("") // ignored like the other ops
+	err := deps.StopTestLog()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "open testdata/a.txt\nstat testdata/b.txt\nclose testdata/a.txt\n")
+	assert.NotContains(t, buf.String(), "close \n")
+}
+
+func Test_SetTestLogWriter_ShouldWriteOpsOfRun(t *testing.T) {
+	// Arrange
+	defer setTestFlag("test.testlogfile", "")() // testing doesn't start the log
+	r := newInstance(newTestingM("TestReadsFixture")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	var buf bytes.Buffer
+	r.SetTestLogWriter(&buf)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		Open("testdata/fixture.json")
+	}
+
+	// Act
+	r.Run()
+	Open("testdata/after.json") // the log is stopped after the Run
+
+	// Assert
+	assert.Contains(t, buf.String(), "open testdata/fixture.json\n")
+	assert.NotContains(t, buf.String(), "after.json")
+	assert.Empty(t, r.Warnings())
+}
+
+func Test_TestDeps_ShouldAddTestLogWriter(t *testing.T) {
+	// Arrange
+	var file, extra bytes.Buffer
+	deps := TestDeps{testLogOut: &extra}
+	deps.StartTestLog(&file) // as testing does with -test.testlogfile
+
+	// Act
+	Open("testdata/a.txt")
+	err := deps.StopTestLog()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Contains(t, file.String(), "open testdata/a.txt\n")
+	assert.Equal(t, file.String(), extra.String())
+}
+
+// lockedBuffer is a bytes.Buffer that can be read while it is written to
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func Test_TestDeps_ShouldFlushTestLogPeriodically(t *testing.T) {
+	// Arrange
+	var buf lockedBuffer
+	deps := TestDeps{testLogFlush: 10 * time.Millisecond}
+	deps.StartTestLog(&buf)
+	defer deps.StopTestLog()
+
+	// Act
+	Open("testdata/live.txt")
+
+	// Assert
+	assert.Eventually(t, func() bool {
+		return strings.Contains(buf.String(), "open testdata/live.txt\n")
+	}, time.Second, 5*time.Millisecond)
+}
+
+func Test_TestDeps_ShouldFlushTestLogOnClockTicks(t *testing.T) {
+	// Arrange
+	var buf lockedBuffer
+	clock := &fakeClock{}
+	deps := TestDeps{testLogFlush: time.Hour, clock: clock}
+	deps.StartTestLog(&buf)
+	defer deps.StopTestLog()
+	Open("testdata/ticked.txt")
+	require.Len(t, clock.ticks, 1)
+	before := buf.String()
+
+	// Act
+	clock.ticks[0] <- time.Time{}
+	clock.ticks[0] <- time.Time{} // returns once the first flush is done
+
+	// Assert
+	assert.Empty(t, before)
+	assert.Contains(t, buf.String(), "open testdata/ticked.txt\n")
+}
+
+func Test_TestDeps_ShouldNotFlushTestLogBeforeStopByDefault(t *testing.T) {
+	// Arrange
+	var buf lockedBuffer
+	deps := TestDeps{}
+	deps.StartTestLog(&buf)
+
+	// Act
+	Open("testdata/buffered.txt")
+	time.Sleep(20 * time.Millisecond)
+	before := buf.String()
+	err := deps.StopTestLog()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, before)
+	assert.Contains(t, buf.String(), "open testdata/buffered.txt\n")
+}
@@ -0,0 +1,236 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+match.go: Test name matching adapted from golang testing's match.go. A -run style
+pattern is split by "/" into one regexp per subtest level, so "TestParent/TestChild"
+selects TestParent (as a partial match) and then TestChild inside it.
+*/
+
+// Matcher matches test names against a -run style pattern.
+type Matcher struct {
+	filter filterMatch
+	res    map[string]*regexp.Regexp
+}
+
+// NewMatcher compiles pattern. Each slash-separated element must be a valid regexp.
+// Like any regexp, an empty pattern matches every name, which only suits a run
+// pattern: an empty skip pattern must skip nothing, so Skip and the other
+// users of skip patterns don't compile it at all.
+func NewMatcher(pattern string) (*Matcher, error) {
+	m := &Matcher{
+		filter: splitRegexp(pattern),
+		res:    make(map[string]*regexp.Regexp),
+	}
+	if err := m.filter.verify(pattern, m.compile); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MatchFullName matches a full test name such as "TestParent/TestChild".
+// ok reports whether the test should run. partial reports whether the name
+// only matched the leading elements of the pattern, i.e. the test itself is not
+// selected but it must run so that its matching subtests can be reached.
+func (m *Matcher) MatchFullName(name string) (ok, partial bool) {
+	return m.filter.matches(strings.Split(name, "/"), m.matchString)
+}
+
+// Pattern returns the pattern m matches with, as normalized by NewMatcher:
+// like go test, spaces in each element are replaced by underscores and
+// unprintable characters are escaped, since test names are rewritten the
+// same way.
+func (m *Matcher) Pattern() string {
+	return filterPattern(m.filter)
+}
+
+func filterPattern(f filterMatch) string {
+	switch f := f.(type) {
+	case simpleMatch:
+		return strings.Join(f, "/")
+	case alternationMatch:
+		alternatives := make([]string, len(f))
+		for i, alt := range f {
+			alternatives[i] = filterPattern(alt)
+		}
+		return strings.Join(alternatives, "|")
+	}
+	return ""
+}
+
+// This is pulled out so compiles can be counted in unit tests
+var compileRegexp = regexp.Compile
+
+func (m *Matcher) compile(pat, str string) (bool, error) {
+	re, err := compileRegexp(pat)
+	if err != nil {
+		return false, err
+	}
+	m.res[pat] = re
+	return re.MatchString(str), nil
+}
+
+func (m *Matcher) matchString(pat, str string) (bool, error) {
+	return m.res[pat].MatchString(str), nil
+}
+
+// -----
+// CODE COPIED FROM GOLANG TESTING LIBRARY
+// -----
+
+type filterMatch interface {
+	// matches checks the name against the receiver's pattern strings using the
+	// given match function.
+	matches(name []string, matchString func(pat, str string) (bool, error)) (ok, partial bool)
+
+	// verify checks that the receiver's pattern strings are valid filters by
+	// calling the given match function.
+	verify(name string, matchString func(pat, str string) (bool, error)) error
+}
+
+// simpleMatch matches a test name if all of the pattern strings match in
+// sequence.
+type simpleMatch []string
+
+// alternationMatch matches a test name if one of the alternations match.
+type alternationMatch []filterMatch
+
+func (m simpleMatch) matches(name []string, matchString func(pat, str string) (bool, error)) (ok, partial bool) {
+	for i, s := range name {
+		if i >= len(m) {
+			break
+		}
+		if ok, _ := matchString(m[i], s); !ok {
+			return false, false
+		}
+	}
+	return true, len(name) < len(m)
+}
+
+func (m simpleMatch) verify(name string, matchString func(pat, str string) (bool, error)) error {
+	for i, s := range m {
+		m[i] = rewrite(s)
+	}
+	// Verify filters before doing any processing.
+	for i, s := range m {
+		if _, err := matchString(s, "non-empty"); err != nil {
+			return fmt.Errorf("element %d of %s (%q): %s", i, name, s, err)
+		}
+	}
+	return nil
+}
+
+func (m alternationMatch) matches(name []string, matchString func(pat, str string) (bool, error)) (ok, partial bool) {
+	for _, m := range m {
+		if ok, partial = m.matches(name, matchString); ok {
+			return ok, partial
+		}
+	}
+	return false, false
+}
+
+func (m alternationMatch) verify(name string, matchString func(pat, str string) (bool, error)) error {
+	for i, m := range m {
+		if err := m.verify(name, matchString); err != nil {
+			return fmt.Errorf("alternation %d of %s", i, err)
+		}
+	}
+	return nil
+}
+
+func splitRegexp(s string) filterMatch {
+	a := make(simpleMatch, 0, strings.Count(s, "/"))
+	b := make(alternationMatch, 0, strings.Count(s, "|"))
+	cs := 0
+	cp := 0
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '[':
+			cs++
+		case ']':
+			if cs--; cs < 0 { // An unmatched ']' is legal.
+				cs = 0
+			}
+		case '(':
+			if cs == 0 {
+				cp++
+			}
+		case ')':
+			if cs == 0 {
+				cp--
+			}
+		case '\\':
+			i++
+		case '/':
+			if cs == 0 && cp == 0 {
+				a = append(a, s[:i])
+				s = s[i+1:]
+				i = 0
+				continue
+			}
+		case '|':
+			if cs == 0 && cp == 0 {
+				a = append(a, s[:i])
+				s = s[i+1:]
+				i = 0
+				b = append(b, a)
+				a = make(simpleMatch, 0, len(a))
+				continue
+			}
+		}
+		i++
+	}
+
+	a = append(a, s)
+	if len(b) == 0 {
+		return a
+	}
+	return append(b, a)
+}
+
+// rewrite rewrites a subname to having only printable characters and no white
+// space.
+func rewrite(s string) string {
+	b := []byte{}
+	for _, r := range s {
+		switch {
+		case isSpace(r):
+			b = append(b, '_')
+		case !strconv.IsPrint(r):
+			s := strconv.QuoteRune(r)
+			b = append(b, s[1:len(s)-1]...)
+		default:
+			b = append(b, string(r)...)
+		}
+	}
+	return string(b)
+}
+
+func isSpace(r rune) bool {
+	if r < 0x2000 {
+		switch r {
+		// Note: not the same as Unicode Z class.
+		case '\t', '\n', '\v', '\f', '\r', ' ', 0x85, 0xA0, 0x1680:
+			return true
+		}
+	} else {
+		if r <= 0x200a {
+			return true
+		}
+		switch r {
+		case 0x2028, 0x2029, 0x202f, 0x205f, 0x3000:
+			return true
+		}
+	}
+	return false
+}
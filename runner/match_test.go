@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Matcher_MatchFullName(t *testing.T) {
+	cases := map[string]struct {
+		pattern     string
+		name        string
+		wantOk      bool
+		wantPartial bool
+	}{
+		"TopLevelFull": {
+			pattern: "TestParent",
+			name:    "TestParent",
+			wantOk:  true,
+		},
+		"TopLevelSubtestOfMatch": {
+			pattern: "TestParent",
+			name:    "TestParent/TestChild",
+			wantOk:  true,
+		},
+		"ParentPartial": {
+			pattern:     "TestParent/TestChild",
+			name:        "TestParent",
+			wantOk:      true,
+			wantPartial: true,
+		},
+		"ChildFull": {
+			pattern: "TestParent/TestChild",
+			name:    "TestParent/TestChild",
+			wantOk:  true,
+		},
+		"OtherChild": {
+			pattern: "TestParent/TestChild",
+			name:    "TestParent/TestOther",
+			wantOk:  false,
+		},
+		"OtherParent": {
+			pattern: "TestParent/TestChild",
+			name:    "TestOther",
+			wantOk:  false,
+		},
+		"Alternation": {
+			pattern:     "TestA|TestB/Sub",
+			name:        "TestB",
+			wantOk:      true,
+			wantPartial: true,
+		},
+		"SlashInsideGroup": {
+			pattern: "^(TestA|TestB)(/|$)",
+			name:    "TestB/Sub",
+			wantOk:  true,
+		},
+		"SpacesRewritten": {
+			pattern: "TestParent/with space",
+			name:    "TestParent/with_space",
+			wantOk:  true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			m, err := NewMatcher(tc.pattern)
+			require.NoError(t, err)
+
+			// Act
+			ok, partial := m.MatchFullName(tc.name)
+
+			// Assert
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantPartial, partial)
+		})
+	}
+}
+
+func Test_NewMatcher_ShouldRejectInvalidElement(t *testing.T) {
+	// Act
+	_, err := NewMatcher("TestParent/(")
+
+	// Assert
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"math"
+	"reflect"
+)
+
+/*
+minimize.go: Shrinking a crashing fuzz input to a smaller one that still crashes
+*/
+
+// MinimizeCrash shrinks the values of input while fails still returns true
+// for the result: []byte and string values are cut in half and have chunks
+// removed down to single bytes, numbers are zeroed or halved towards zero and
+// bools are set to false. It stops when no value can be shrunk any further
+// and returns the smallest failing entry found, or input itself if nothing
+// smaller fails. input is not modified.
+func MinimizeCrash(input corpusEntry, fails func(corpusEntry) bool) corpusEntry {
+	best := input
+	best.Values = append([]any(nil), input.Values...)
+	best.Data = nil // the encoded values no longer match
+
+	try := func(i int, v any) bool {
+		candidate := best
+		candidate.Values = append([]any(nil), best.Values...)
+		candidate.Values[i] = v
+		if !fails(candidate) {
+			return false
+		}
+		best = candidate
+		return true
+	}
+
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for i, v := range best.Values {
+			for _, smaller := range shrinkCandidates(v) {
+				if try(i, smaller) {
+					shrunk = true
+					break // start again from the new value
+				}
+			}
+		}
+	}
+	return best
+}
+
+// shrinkCandidates returns values smaller than v to try, most aggressive first
+func shrinkCandidates(v any) []any {
+	switch v := v.(type) {
+	case []byte:
+		var out []any
+		for _, b := range shrinkBytes(v) {
+			out = append(out, b)
+		}
+		return out
+	case string:
+		var out []any
+		for _, b := range shrinkBytes([]byte(v)) {
+			out = append(out, string(b))
+		}
+		return out
+	case bool:
+		if v {
+			return []any{false}
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	var out []any
+	add := func(n reflect.Value) { out = append(out, n.Interface()) }
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := rv.Int(); n != 0 {
+			add(reflect.Zero(rv.Type()))
+			if n/2 != 0 {
+				add(reflect.ValueOf(n / 2).Convert(rv.Type()))
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := rv.Uint(); n != 0 {
+			add(reflect.Zero(rv.Type()))
+			if n/2 != 0 {
+				add(reflect.ValueOf(n / 2).Convert(rv.Type()))
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); f != 0 && !math.IsNaN(f) {
+			add(reflect.Zero(rv.Type()))
+			if t := math.Trunc(f); t != f && !math.IsInf(f, 0) {
+				add(reflect.ValueOf(t).Convert(rv.Type()))
+			}
+			if h := math.Trunc(f / 2); h != 0 && !math.IsInf(f, 0) {
+				add(reflect.ValueOf(h).Convert(rv.Type()))
+			}
+		}
+	}
+	return out
+}
+
+// shrinkBytes returns shorter copies of b: empty, each half, then b with
+// chunks removed, from half its length down to single bytes
+func shrinkBytes(b []byte) [][]byte {
+	if len(b) == 0 {
+		return nil
+	}
+	out := [][]byte{{}}
+	if len(b) > 1 {
+		half := len(b) / 2
+		out = append(out, cloneBytes(b[:half]), cloneBytes(b[half:]))
+	}
+	for size := len(b) / 2; size >= 1; size /= 2 {
+		for start := 0; start+size <= len(b); start += size {
+			cut := make([]byte, 0, len(b)-size)
+			cut = append(cut, b[:start]...)
+			cut = append(cut, b[start+size:]...)
+			out = append(out, cut)
+		}
+	}
+	return out
+}
+
+func cloneBytes(b []byte) []byte {
+	return append([]byte{}, b...)
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MinimizeCrash_ShouldShrinkPaddedCrasher(t *testing.T) {
+	// Arrange
+	input := corpusEntry{
+		Path:   "crash-1",
+		Data:   []byte("go test fuzz v1\n..."),
+		Values: []any{[]byte("xxxxxxxxxxBOOMyyyyyyyyyyyyyyyyyyyy"), strings.Repeat("pad", 20), 1000, uint8(200), 3.75, true},
+	}
+	padded := append([]any(nil), input.Values...)
+	// crashes whenever the bytes contain "BOOM" and the int is at least 7
+	fails := func(e corpusEntry) bool {
+		return bytes.Contains(e.Values[0].([]byte), []byte("BOOM")) && e.Values[2].(int) >= 7
+	}
+
+	// Act
+	minimized := MinimizeCrash(input, fails)
+
+	// Assert
+	assert.True(t, fails(minimized))
+	assert.Equal(t, []any{[]byte("BOOM"), "", 7, uint8(0), 0.0, false}, minimized.Values)
+	assert.Equal(t, "crash-1", minimized.Path)
+	assert.Nil(t, minimized.Data)
+	assert.Equal(t, padded, input.Values)
+}
+
+func Test_MinimizeCrash_ShouldReturnInputWhenNothingSmallerFails(t *testing.T) {
+	// Arrange
+	input := corpusEntry{Values: []any{"a", int64(1)}}
+
+	// Act
+	minimized := MinimizeCrash(input, func(e corpusEntry) bool {
+		return e.Values[0] == "a" && e.Values[1] == int64(1)
+	})
+
+	// Assert
+	assert.Equal(t, input.Values, minimized.Values)
+}
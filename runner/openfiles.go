@@ -0,0 +1,50 @@
+package runner
+
+/*
+openfiles.go: Reporting the files a test opened and didn't close, from the open and close ops of the test log.
+Like the file accesses of depgraph.go, an op is attributed to every test that is running when it is reported.
+*/
+
+// SetTrackOpenFiles records the files each test opened but didn't close
+// while it ran in its Statistics.OpenFiles, to find the tests that leak file
+// descriptors in a long run. The ops come from the test log, as with
+// SetTrackFileAccess, so only files opened and closed with the Open and
+// Close funcs of this package are seen: package os doesn't report closes.
+// Only tests started with testdeck.Test are tracked, and an op is attributed
+// to all the tests running at the time, so a parallel test can be reported
+// for a file another test left open.
+func (r *runner) SetTrackOpenFiles(yes bool) {
+	r.trackOpen = yes
+}
+
+// startOpenFiles makes name one of the running tests that get open and close
+// ops
+func (r *runner) startOpenFiles(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.openFiles != nil {
+		r.openFiles[name] = nil
+	}
+}
+
+// recordOpenFile adds a file opened by the running tests, or removes one
+// they closed
+func (r *runner) recordOpenFile(op, path string) {
+	if op != "open" && op != "close" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, open := range r.openFiles {
+		if op == "open" {
+			r.openFiles[name] = append(open, path)
+			continue
+		}
+		for i := len(open) - 1; i >= 0; i-- {
+			if open[i] == path {
+				r.openFiles[name] = append(open[:i:i], open[i+1:]...)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SetTrackOpenFiles_ShouldReportFilesLeftOpen(t *testing.T) {
+	// Arrange
+	defer setTestFlag("test.testlogfile", "")() // testing doesn't start the log
+	r := newInstance(newTestingM("TestLeaks")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetTrackOpenFiles(true)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		Open("testdata/before.json") // no test running yet
+		r.TestStarted("TestLeaks")
+		Open("testdata/a.json")
+		Open("testdata/b.json")
+		Open("testdata/b.json")
+		Close("testdata/b.json")
+		Close("testdata/a.json")
+		Close("testdata/before.json") // opened before the test
+		Stat("testdata/c.json")       // not an open
+		r.AddStatistics(&constants.Statistics{Name: "TestLeaks"})
+		Open("testdata/after.json")
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	require.Len(t, r.Statistics(), 1)
+	assert.Equal(t, []string{"testdata/b.json"}, r.Statistics()[0].OpenFiles)
+	assert.Nil(t, r.Statistics()[0].FileAccesses) // not tracked
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+order.go: The order in which a Run starts its top-level tests. testing starts them in the order they are given, so sorting them
+gives the same order however the tests were put together (e.g. collected from a map).
+*/
+
+// Orders of SetSortTests
+const (
+	SortNone     = "none"     // the order the tests were registered in
+	SortName     = "name"     // by name
+	SortDuration = "duration" // slowest first, by the durations of a previous run
+)
+
+// SetSortTests sets the order in which Run starts the top-level tests:
+// SortNone (or ""), SortName or SortDuration. SortDuration uses the durations
+// in prior, or the runner's own statistics of earlier Runs if prior is nil;
+// tests without a duration run after the others, in registration order.
+func (r *runner) SetSortTests(order string, prior []constants.Statistics) error {
+	if err := checkSortOrder(order); err != nil {
+		return err
+	}
+	if order == SortNone {
+		order = ""
+	}
+	r.sortTests = order
+	r.sortPrior = prior
+	return nil
+}
+
+func checkSortOrder(order string) error {
+	switch order {
+	case "", SortNone, SortName, SortDuration:
+		return nil
+	}
+	return fmt.Errorf("unknown test order %q, want %q, %q or %q", order, SortNone, SortName, SortDuration)
+}
+
+// sortedTests returns tests in the order set by SetSortTests
+func (r *runner) sortedTests(tests []testing.InternalTest) []testing.InternalTest {
+	if r.sortTests == "" {
+		return tests
+	}
+	sorted := append([]testing.InternalTest(nil), tests...)
+	switch r.sortTests {
+	case SortName:
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	case SortDuration:
+		prior := r.sortPrior
+		if prior == nil {
+			prior = r.stats
+		}
+		durations := topLevelDurations(prior)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			di, iok := durations[sorted[i].Name]
+			dj, jok := durations[sorted[j].Name]
+			if iok != jok {
+				return iok
+			}
+			return di > dj
+		})
+	}
+	return sorted
+}
+
+// topLevelDurations returns the duration of each top-level test in stats,
+// the longest if it ran more than once
+func topLevelDurations(stats []constants.Statistics) map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	for _, s := range stats {
+		if strings.Contains(s.Name, "/") {
+			continue
+		}
+		if d, ok := durations[s.Name]; !ok || s.Duration > d {
+			durations[s.Name] = s.Duration
+		}
+	}
+	return durations
+}
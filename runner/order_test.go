@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordOrder replaces runnerMainStart with one that records the order the
+// tests are given in
+func recordOrder(t *testing.T) (order *[]string) {
+	order = new([]string)
+	prev := runnerMainStart
+	t.Cleanup(func() { runnerMainStart = prev })
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for _, test := range tests {
+			_, _, name := MatchTag(test.Name)
+			*order = append(*order, name)
+		}
+	}
+	return order
+}
+
+func Test_SetSortTests_ShouldOrderTests(t *testing.T) {
+	prior := []constants.Statistics{
+		{Name: "TestB", Duration: time.Second},
+		{Name: "TestC", Duration: 3 * time.Second},
+		{Name: "TestC/sub", Duration: time.Minute},
+		{Name: "TestB", Duration: 2 * time.Second},
+	}
+	cases := map[string]struct {
+		order string
+		want  []string
+	}{
+		"Default":  {order: "", want: []string{"TestC", "TestA", "TestD", "TestB"}},
+		"None":     {order: SortNone, want: []string{"TestC", "TestA", "TestD", "TestB"}},
+		"Name":     {order: SortName, want: []string{"TestA", "TestB", "TestC", "TestD"}},
+		"Duration": {order: SortDuration, want: []string{"TestC", "TestB", "TestA", "TestD"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			r := newInstance(newTestingM("TestC", "TestA", "TestD", "TestB")).(*runner)
+			assert.NoError(t, r.SetSortTests(tc.order, prior))
+			order := recordOrder(t)
+
+			// Act
+			r.Run()
+
+			// Assert
+			assert.Equal(t, tc.want, *order)
+		})
+	}
+}
+
+func Test_SetSortTests_ShouldUseEarlierRunsForDuration(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestFast", "TestSlow")).(*runner)
+	r.AddStatistics(&constants.Statistics{Name: "TestFast", Duration: time.Millisecond})
+	r.AddStatistics(&constants.Statistics{Name: "TestSlow", Duration: time.Second})
+	assert.NoError(t, r.SetSortTests(SortDuration, nil))
+	order := recordOrder(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, []string{"TestSlow", "TestFast"}, *order)
+}
+
+func Test_SetSortTests_ShouldRejectUnknownOrder(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA")).(*runner)
+
+	// Act
+	err := r.SetSortTests("random", nil)
+
+	// Assert
+	assert.Error(t, err)
+}
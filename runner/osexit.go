@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"flag"
+	"strconv"
+	"sync"
+	"testing"
+	_ "unsafe" // for linkname
+)
+
+/*
+osexit.go: Detection of tests that call os.Exit
+
+The exit.go copy of internal/testlog is not the one package os reads, so the
+real hook is set as well. With it on, os.Exit(0) during a Run panics and the
+testdeck harness turns the panic into a failure of the test. os.Exit with any
+other code can't be intercepted and still ends the process.
+*/
+
+// ExitMessage is the failure message of a test that called os.Exit
+const ExitMessage = "test called os.Exit, which aborts the runner"
+
+// exitPanic is the value os.Exit(0) panics with when the hook is on
+const exitPanic = "unexpected call to os.Exit(0) during test"
+
+//go:linkname testlogSetPanicOnExit0 internal/testlog.SetPanicOnExit0
+func testlogSetPanicOnExit0(v bool)
+
+// IsExitPanic returns true if v (a recovered panic value) is from a test
+// calling os.Exit(0) during a Run
+func IsExitPanic(v any) bool {
+	s, ok := v.(string)
+	return ok && s == exitPanic
+}
+
+// allowExit serializes the tests that called AllowExit
+var allowExit struct {
+	run    sync.Mutex // held by the test that is allowed to exit
+	mu     sync.Mutex // guards holder
+	holder testing.TB
+}
+
+// AllowExit turns off the os.Exit(0) check (see ExitMessage) until t ends,
+// e.g. for a test whose code under test exits 0 in a child process started
+// from the test binary itself. The check is process-wide, so the tests that
+// call AllowExit run one at a time; tests that don't call it may still run in
+// parallel and are not checked meanwhile either. Calling it again in the same
+// test does nothing.
+func AllowExit(t testing.TB) {
+	allowExit.mu.Lock()
+	held := allowExit.holder == t
+	allowExit.mu.Unlock()
+	if held {
+		return
+	}
+
+	allowExit.run.Lock()
+	allowExit.mu.Lock()
+	allowExit.holder = t
+	allowExit.mu.Unlock()
+
+	prev := panicOnExit0Flag()
+	TestDeps{}.SetPanicOnExit0(false)
+	t.Cleanup(func() {
+		TestDeps{}.SetPanicOnExit0(prev)
+		allowExit.mu.Lock()
+		allowExit.holder = nil
+		allowExit.mu.Unlock()
+		allowExit.run.Unlock()
+	})
+}
+
+// panicOnExit0Flag returns the -test.paniconexit0 setting, which the testing
+// package applies at the start of each m.Run (see setTestFlags)
+func panicOnExit0Flag() bool {
+	f := flag.Lookup("test.paniconexit0")
+	if f == nil {
+		return false
+	}
+	v, _ := strconv.ParseBool(f.Value.String())
+	return v
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_IsExitPanic_ShouldDetectOsExit0(t *testing.T) {
+	// Arrange
+	TestDeps{}.SetPanicOnExit0(true) // go test turns it on as well
+	var recovered any
+
+	// Act
+	func() {
+		defer func() { recovered = recover() }()
+		os.Exit(0)
+	}()
+
+	// Assert
+	assert.True(t, IsExitPanic(recovered))
+	assert.False(t, IsExitPanic("some other panic"))
+	assert.False(t, IsExitPanic(nil))
+}
+
+// Test_AllowExit_Child is run by the tests below in a child process of the
+// test binary, with -test.paniconexit0 on like go test does
+func Test_AllowExit_Child(t *testing.T) {
+	switch os.Getenv("TESTDECK_ALLOW_EXIT_CHILD") {
+	case "allow":
+		AllowExit(t)
+	case "deny":
+	default:
+		t.Skip("only run as a child process")
+	}
+	os.Exit(0)
+}
+
+func runAllowExitChild(t *testing.T, mode string) (output string, exitCode int) {
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_AllowExit_Child$", "-test.paniconexit0")
+	cmd.Env = append(os.Environ(), "TESTDECK_ALLOW_EXIT_CHILD="+mode)
+	out, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(out), exitErr.ExitCode()
+	}
+	require.NoError(t, err)
+	return string(out), 0
+}
+
+func Test_AllowExit_ShouldLetChildExit0(t *testing.T) {
+	// Act
+	output, exitCode := runAllowExitChild(t, "allow")
+
+	// Assert
+	assert.Equal(t, 0, exitCode, output)
+	assert.NotContains(t, output, exitPanic)
+}
+
+func Test_AllowExit_ShouldKeepGuardWithoutIt(t *testing.T) {
+	// Act
+	output, exitCode := runAllowExitChild(t, "deny")
+
+	// Assert
+	assert.NotEqual(t, 0, exitCode)
+	assert.Contains(t, output, exitPanic)
+}
+
+func Test_AllowExit_ShouldRestoreGuardWhenTestEnds(t *testing.T) {
+	// Arrange
+	TestDeps{}.SetPanicOnExit0(true)
+	defer TestDeps{}.SetPanicOnExit0(panicOnExit0Flag())
+	prev := flag.Lookup("test.paniconexit0").Value.String()
+	require.NoError(t, flag.Set("test.paniconexit0", "true"))
+	defer flag.Set("test.paniconexit0", prev)
+	var during bool
+
+	// Act
+	t.Run("allowed", func(t *testing.T) {
+		AllowExit(t)
+		AllowExit(t) // a second call must not block
+		during = PanicOnExit0()
+	})
+
+	// Assert
+	assert.False(t, during)
+	assert.True(t, PanicOnExit0())
+}
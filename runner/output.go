@@ -0,0 +1,255 @@
+package runner
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+/*
+output.go: Capturing a run's output, and splitting it into one block per test.
+The testing package marks which test each line of verbose output belongs to ("=== RUN", "=== CONT", "=== NAME", "=== PAUSE")
+and prints a "--- PASS/FAIL/SKIP" line (followed by the test's log in non-verbose mode) when a test finishes.
+*/
+
+// reTestMarker matches the lines that switch the test the following output belongs to
+var reTestMarker = regexp.MustCompile(`^\s*(=== (?:RUN|CONT|NAME|PAUSE)\s+|--- (?:PASS|FAIL|SKIP): )(\S+)`)
+
+// rePackageSummary matches the package summary lines printed after all tests
+var rePackageSummary = regexp.MustCompile(`^(PASS|FAIL|ok\s|FAIL\s|coverage:|testing: )`)
+
+// reLogLine matches the first line of a message logged with t.Log etc. in
+// verbose output, e.g. "    foo_test.go:12: message"
+var reLogLine = regexp.MustCompile(`^ {4,}\S+:\d+: `)
+
+// testOutput is a run's output split by test
+type testOutput struct {
+	names  []string          // tests in the order their output first appeared (i.e. the order they were started)
+	blocks map[string]string // each test's lines
+	pkg    string            // lines that don't belong to a test
+}
+
+// outputLine is a line of a run's output (with its newline) and the test it
+// belongs to ("" for package-level output)
+type outputLine struct {
+	test string
+	text string
+	name bool // "=== NAME" line, only printed to tell interleaved output apart
+}
+
+// attributeLines splits output into lines and attributes each to a test. Only
+// output the testing package prints for a test (e.g. t.Log) is reliably
+// attributed; a direct write to stdout belongs to whichever test's output came
+// last.
+func attributeLines(output string) []outputLine {
+	var lines []outputLine
+	var a lineAttributor
+	for _, text := range strings.SplitAfter(output, "\n") {
+		if text == "" {
+			continue
+		}
+		test, name := a.attribute(text)
+		lines = append(lines, outputLine{test: test, text: text, name: name})
+	}
+	return lines
+}
+
+// lineAttributor attributes lines of output to tests as they come
+type lineAttributor struct {
+	current string
+}
+
+func (a *lineAttributor) attribute(text string) (test string, name bool) {
+	if parts := reTestMarker.FindStringSubmatch(text); parts != nil {
+		a.current = untagName(parts[2])
+		name = strings.HasPrefix(parts[1], "=== NAME")
+	} else if rePackageSummary.MatchString(text) {
+		a.current = ""
+	}
+	return a.current, name
+}
+
+// packageWriter calls fn with each line of package-level output written to it
+type packageWriter struct {
+	fn      func(b []byte)
+	partial []byte
+	lines   lineAttributor
+}
+
+func (w *packageWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.line(w.partial[:i+1])
+		w.partial = w.partial[i+1:]
+	}
+}
+
+// flush passes on a last line without a newline
+func (w *packageWriter) flush() {
+	if len(w.partial) > 0 {
+		w.line(w.partial)
+		w.partial = nil
+	}
+}
+
+func (w *packageWriter) line(b []byte) {
+	if test, _ := w.lines.attribute(string(b)); test == "" {
+		w.fn(append([]byte(nil), b...))
+	}
+}
+
+// splitOutputByTest splits output into one block per test (see attributeLines)
+func splitOutputByTest(output string) testOutput {
+	split := testOutput{blocks: make(map[string]string)}
+	blocks := make(map[string]*strings.Builder)
+	var pkgLines strings.Builder
+
+	for _, line := range attributeLines(output) {
+		if line.name {
+			continue // not needed once the output is grouped
+		}
+		if line.test == "" {
+			pkgLines.WriteString(line.text)
+			continue
+		}
+		b, ok := blocks[line.test]
+		if !ok {
+			b = &strings.Builder{}
+			blocks[line.test] = b
+			split.names = append(split.names, line.test)
+		}
+		b.WriteString(line.text)
+	}
+
+	for name, b := range blocks {
+		split.blocks[name] = b.String()
+	}
+	split.pkg = pkgLines.String()
+	return split
+}
+
+// splitLogOutput splits a test's block of output (see splitOutputByTest) into
+// the lines logged with t.Log etc. and the lines written to stdout or stderr
+// directly. The testing markers belong to neither. The testing package
+// indents the continuation lines of a logged message further than its first
+// line, so indented lines following a logged line are logged too.
+func splitLogOutput(block string) (logged, raw string) {
+	var l, w strings.Builder
+	inLog := false
+	for _, text := range strings.SplitAfter(block, "\n") {
+		switch {
+		case text == "" || reTestMarker.MatchString(text):
+			inLog = false
+		case reLogLine.MatchString(text), inLog && strings.HasPrefix(text, "        "):
+			inLog = true
+			l.WriteString(text)
+		default:
+			inLog = false
+			w.WriteString(text)
+		}
+	}
+	return l.String(), w.String()
+}
+
+// grouped returns the output with each test's lines as a contiguous block,
+// in the order the tests were started, followed by the package lines.
+func (o testOutput) grouped() string {
+	var b strings.Builder
+	for _, name := range o.names {
+		b.WriteString(o.blocks[name])
+	}
+	b.WriteString(o.pkg)
+	return b.String()
+}
+
+// filterReader passes each chunk read from r through fn (see SetOutputFilter)
+type filterReader struct {
+	r       io.Reader
+	fn      func(test string, b []byte) []byte
+	lines   lineAttributor
+	partial []byte // the start of a line not read completely yet
+	pending []byte // filtered output not read yet
+	chunk   [32 * 1024]byte
+}
+
+func (f *filterReader) Read(p []byte) (int, error) {
+	for len(f.pending) == 0 {
+		n, err := f.r.Read(f.chunk[:])
+		if n > 0 {
+			f.filter(f.chunk[:n])
+		}
+		if err != nil && len(f.pending) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, f.pending)
+	f.pending = f.pending[n:]
+	return n, nil
+}
+
+// filter passes chunk to fn in pieces split where the test the output belongs
+// to changes. Lines are attributed unfiltered, so the filter's changes can't
+// affect which test later output belongs to.
+func (f *filterReader) filter(chunk []byte) {
+	var piece []byte
+	pieceTest := ""
+	flush := func() {
+		if len(piece) > 0 {
+			f.pending = append(f.pending, f.fn(pieceTest, piece)...)
+			piece = nil
+		}
+	}
+	for len(chunk) > 0 {
+		text := chunk
+		test := f.lines.current
+		if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+			text = chunk[:i+1]
+			// the line may have started in an earlier chunk
+			test, _ = f.lines.attribute(string(f.partial) + string(text))
+			f.partial = nil
+		} else {
+			f.partial = append(f.partial, text...)
+		}
+		chunk = chunk[len(text):]
+
+		if test != pieceTest {
+			flush()
+			pieceTest = test
+		}
+		piece = append(piece, text...)
+	}
+	flush()
+}
+
+// cappedBuffer keeps up to max bytes (0 is unlimited) of what is written to it
+// and drops the rest, calling onTruncate the first time it does. Only the
+// capture goroutine writes to it, so the output of parallel tests is already
+// serialized. The buffer is not embedded so io.Copy can't bypass Write with
+// bytes.Buffer's ReadFrom.
+type cappedBuffer struct {
+	buf        bytes.Buffer
+	max        int
+	truncated  bool
+	onTruncate func()
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); b.max > 0 && len(p) > room {
+		b.buf.Write(p[:room])
+		if !b.truncated {
+			b.truncated = true
+			b.onTruncate()
+		}
+		return len(p), nil // dropped output is not an error for the writer
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
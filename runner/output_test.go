@@ -0,0 +1,517 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const interleavedOutput = `=== RUN   TestA
+=== PAUSE TestA
+=== RUN   TestB
+=== PAUSE TestB
+=== CONT  TestA
+    a_test.go:10: A line 0
+=== CONT  TestB
+    b_test.go:10: B line 0
+    b_test.go:10: B line 1
+=== NAME  TestA
+    a_test.go:10: A line 1
+    a_test.go:10: A line 2
+=== NAME  TestB
+    b_test.go:10: B line 2
+--- FAIL: TestB (0.02s)
+=== NAME  TestA
+    a_test.go:10: A line 3
+--- PASS: TestA (0.02s)
+FAIL
+`
+
+func Test_SplitOutputByTest_ShouldGroupInterleavedOutput(t *testing.T) {
+	// Act
+	split := splitOutputByTest(interleavedOutput)
+
+	// Assert
+	assert.Equal(t, []string{"TestA", "TestB"}, split.names)
+	assert.Equal(t, `=== RUN   TestA
+=== PAUSE TestA
+=== CONT  TestA
+    a_test.go:10: A line 0
+    a_test.go:10: A line 1
+    a_test.go:10: A line 2
+    a_test.go:10: A line 3
+--- PASS: TestA (0.02s)
+`, split.blocks["TestA"])
+	assert.Equal(t, `=== RUN   TestB
+=== PAUSE TestB
+=== CONT  TestB
+    b_test.go:10: B line 0
+    b_test.go:10: B line 1
+    b_test.go:10: B line 2
+--- FAIL: TestB (0.02s)
+`, split.blocks["TestB"])
+	assert.Equal(t, split.blocks["TestA"]+split.blocks["TestB"]+"FAIL\n", split.grouped())
+}
+
+func Test_Runner_ShouldGroupOutputOfParallelTests(t *testing.T) {
+	// Arrange
+	defer setTestVerbose()()
+	turnA, turnB := make(chan struct{}), make(chan struct{})
+	// the tests log in turns, so their lines are interleaved
+	logInTurns := func(t *testing.T, label string, mine, theirs chan struct{}) {
+		t.Parallel()
+		for i := 0; i < 3; i++ {
+			<-mine
+			t.Logf("%s line %d", label, i)
+			theirs <- struct{}{}
+		}
+	}
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestA", F: func(t *testing.T) {
+			go func() { turnA <- struct{}{} }()
+			logInTurns(t, "A", turnA, turnB)
+			<-turnA // B's last turn
+		}},
+		{Name: "TestB", F: func(t *testing.T) { logInTurns(t, "B", turnB, turnA) }},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetGroupOutput(true)
+	r.SetParallel(2)
+	r.parseResults = true // the tests don't use testdeck.Test
+	ok := runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	require.True(t, *ok)
+	logged := regexp.MustCompile(`[AB] line \d`).FindAllString(r.Output(), -1)
+	assert.Equal(t, []string{"A line 0", "A line 1", "A line 2", "B line 0", "B line 1", "B line 2"}, logged)
+	stats := r.Statistics()
+	require.Len(t, stats, 2)
+	for _, s := range stats {
+		other := map[string]string{"TestA": "B line", "TestB": "A line"}[s.Name]
+		assert.NotContains(t, s.Output, other, s.Name)
+	}
+}
+
+func Test_SplitOutputByTest_ShouldUntagMatchWorkaroundNames(t *testing.T) {
+	// Arrange
+	output := "=== RUN   TestA\x00TestA\n    a_test.go:10: A line\n--- PASS: TestA\x00TestA (0.00s)\nPASS\n"
+
+	// Act
+	split := splitOutputByTest(output)
+
+	// Assert
+	assert.Equal(t, []string{"TestA"}, split.names)
+	assert.Contains(t, split.blocks["TestA"], "A line")
+}
+
+func Test_Runner_ShouldGroupOutputWithUnanchoredPattern(t *testing.T) {
+	// Arrange
+	defer setTestVerbose()()
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestA", F: func(t *testing.T) { t.Log("A line") }},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetGroupOutput(true)
+	require.NoError(t, r.Match("TestA"))
+	r.parseResults = true // the test doesn't use testdeck.Test
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.NotContains(t, r.Output(), "\x00")
+	stats := r.Statistics()
+	require.Len(t, stats, 1)
+	assert.Contains(t, stats[0].Output, "A line")
+}
+
+func Test_SplitOutputByTest_ShouldAttributeNonVerboseFailureLogs(t *testing.T) {
+	// Arrange
+	output := `package setup
+--- FAIL: TestA (0.00s)
+    a_test.go:10: want 1, got 2
+    --- FAIL: TestA/sub (0.00s)
+        a_test.go:20: sub failed
+FAIL
+exit status 1
+`
+
+	// Act
+	split := splitOutputByTest(output)
+
+	// Assert
+	assert.Equal(t, []string{"TestA", "TestA/sub"}, split.names)
+	assert.Equal(t, "--- FAIL: TestA (0.00s)\n    a_test.go:10: want 1, got 2\n", split.blocks["TestA"])
+	assert.Equal(t, "    --- FAIL: TestA/sub (0.00s)\n        a_test.go:20: sub failed\n", split.blocks["TestA/sub"])
+	assert.Equal(t, "package setup\nFAIL\nexit status 1\n", split.pkg)
+}
+
+func Test_CappedBuffer_ShouldDropOutputPastCap(t *testing.T) {
+	// Arrange
+	truncations := 0
+	buf := cappedBuffer{max: 10, onTruncate: func() { truncations++ }}
+
+	// Act
+	_, err := io.Copy(&buf, strings.NewReader("0123456789abcdef"))
+	n, err2 := buf.Write([]byte("more"))
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, err2)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, "0123456789", buf.String())
+	assert.Equal(t, 1, truncations)
+}
+
+func Test_Runner_ShouldCapTotalOutputOfParallelTests(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetMaxTotalOutputBytes(1000)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					fmt.Printf("test %d line %d\n", i, j)
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Len(t, r.Output(), 1000)
+	require.Equal(t, 1, len(r.Warnings()))
+	assert.Contains(t, r.Warnings()[0], "1000 bytes")
+}
+
+func Test_Runner_ShouldSeparatePackageOutput(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	var lines []string
+	r.SetOnPackageOutput(func(b []byte) {
+		lines = append(lines, string(b))
+	})
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		fmt.Println("connected to fixture DB")
+		fmt.Println("=== RUN   TestA")
+		fmt.Println("    a_test.go:10: in TestA")
+		fmt.Println("--- PASS: TestA (0.00s)")
+		fmt.Println("PASS")
+		fmt.Print("teardown without newline")
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, []string{"connected to fixture DB\n", "PASS\n", "teardown without newline"}, lines)
+	assert.Equal(t, "connected to fixture DB\nPASS\nteardown without newline", r.RunInfo().PackageOutput)
+}
+
+func Test_Runner_ShouldPrintToConfiguredOutput(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(true)
+	var out, errOut bytes.Buffer
+	r.SetOutput(&out)
+	r.SetErrorOutput(&errOut)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		fmt.Println("=== RUN   TestA")
+		fmt.Println("--- PASS: TestA (0.00s)")
+		r.AddStatistics(&constants.Statistics{Name: "TestA"})
+	}
+
+	// Act
+	r.Run()
+	r.ReportStatistics()
+
+	// Assert
+	assert.Equal(t, "=== RUN   TestA\n--- PASS: TestA (0.00s)\n0 false TestA\n", out.String())
+	assert.Empty(t, errOut.String())
+}
+
+func Test_PackageWriter_ShouldJoinPartialWrites(t *testing.T) {
+	// Arrange
+	var lines []string
+	w := &packageWriter{fn: func(b []byte) { lines = append(lines, string(b)) }}
+
+	// Act
+	fmt.Fprint(w, "start")
+	fmt.Fprint(w, "ing\n=== RUN   TestA\n    in test\n--- FA")
+	fmt.Fprint(w, "IL: TestA (0.00s)\nFAIL\n")
+	w.flush()
+
+	// Assert
+	assert.Equal(t, []string{"starting\n", "FAIL\n"}, lines)
+}
+
+func Test_Runner_ShouldSplitLoggedAndRawOutput(t *testing.T) {
+	// Arrange
+	defer setTestVerbose()()
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestPrints", F: func(t *testing.T) {
+			fmt.Println("printed to stdout")
+			t.Log("logged")
+			fmt.Fprintln(os.Stderr, "printed to stderr")
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetSplitLogOutput(true)
+	r.parseResults = true // the test doesn't use testdeck.Test
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	stats := r.Statistics()
+	require.Len(t, stats, 1)
+	assert.Regexp(t, `^ +output_test\.go:\d+: logged\n$`, stats[0].LogOutput)
+	assert.Equal(t, "printed to stdout\nprinted to stderr\n", stats[0].RawOutput)
+}
+
+func Test_Runner_ShouldSplitLoggedAndRawOutputWithUnanchoredPattern(t *testing.T) {
+	// Arrange
+	defer setTestVerbose()()
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestA", F: func(t *testing.T) {
+			fmt.Println("printed to stdout")
+			t.Log("logged")
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetSplitLogOutput(true)
+	require.NoError(t, r.Match("TestA"))
+	r.parseResults = true // the test doesn't use testdeck.Test
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	stats := r.Statistics()
+	require.Len(t, stats, 1)
+	assert.Regexp(t, `^ +output_test\.go:\d+: logged\n$`, stats[0].LogOutput)
+	assert.Equal(t, "printed to stdout\n", stats[0].RawOutput)
+}
+
+func Test_SplitLogOutput_ShouldKeepContinuationLinesWithTheirLog(t *testing.T) {
+	// Arrange
+	block := "=== RUN   TestA\n" +
+		"    a_test.go:10: first\n" +
+		"        second line\n" +
+		"raw\n" +
+		"        indented raw\n" +
+		"--- FAIL: TestA (0.00s)\n"
+
+	// Act
+	logged, raw := splitLogOutput(block)
+
+	// Assert
+	assert.Equal(t, "    a_test.go:10: first\n        second line\n", logged)
+	assert.Equal(t, "raw\n        indented raw\n", raw)
+}
+
+func Test_Runner_ShouldCapFailureOutputLines(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetGroupOutput(true)
+	r.SetMaxFailureLines(3)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		fmt.Println("=== RUN   TestFails")
+		for i := 1; i <= 10; i++ {
+			fmt.Printf("    a_test.go:%d: assertion %d failed\n", i, i)
+		}
+		fmt.Println("--- FAIL: TestFails (0.00s)")
+		fmt.Println("=== RUN   TestPasses")
+		for i := 1; i <= 5; i++ {
+			fmt.Printf("    b_test.go:%d: line %d\n", i, i)
+		}
+		fmt.Println("--- PASS: TestPasses (0.00s)")
+		r.AddStatistics(&constants.Statistics{Name: "TestFails", Failed: true})
+		r.AddStatistics(&constants.Statistics{Name: "TestPasses"})
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	stats := r.Statistics()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "=== RUN   TestFails\n"+
+		"    a_test.go:1: assertion 1 failed\n"+
+		"    a_test.go:2: assertion 2 failed\n"+
+		"... 9 more lines dropped\n", stats[0].Output)
+	assert.Equal(t, 7, strings.Count(stats[1].Output, "\n"), "passed tests are not capped")
+}
+
+func Test_FirstLines_ShouldKeepFirstNLines(t *testing.T) {
+	cases := map[string]struct {
+		s    string
+		want string
+	}{
+		"Empty":             {s: "", want: ""},
+		"ExactlyN":          {s: "a\nb\n", want: "a\nb\n"},
+		"NoTrailingNewline": {s: "a\nb\nc", want: "a\nb\n... 1 more lines dropped\n"},
+		"LongerThanN":       {s: "a\nb\nc\nd\n", want: "a\nb\n... 2 more lines dropped\n"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Act
+			got := firstLines(tc.s, 2)
+
+			// Assert
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_SetVerbose_ShouldMatchGoTestVerboseFormat(t *testing.T) {
+	// Arrange
+	defer setTestFlag("test.v", "false")()
+	defer setTestFlag("test.run", "")()         // selects the tests of the outer go test too
+	defer setTestFlag("test.testlogfile", "")() // see Test_Run_ShouldCallDepsAroundTests
+	defer setTestFlag("test.shuffle", "off")()  // the outer go test -shuffle prints its seed and reorders
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestSerial", F: func(t *testing.T) {
+			t.Run("sub", func(t *testing.T) {})
+			t.Run("par", func(t *testing.T) {
+				t.Parallel()
+				t.Log("resumed")
+			})
+		}},
+		{Name: "TestFails", F: func(t *testing.T) {
+			t.Run("sub", func(t *testing.T) { t.Error("boom") })
+		}},
+		{Name: "TestSkips", F: func(t *testing.T) { t.Skip("not today") }},
+		{Name: "TestParallel", F: func(t *testing.T) {
+			t.Parallel()
+			t.Run("nested", func(t *testing.T) {})
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.deps = &fakeDeps{}
+	r.SetCount(1) // not the outer go test's -count
+	r.SetVerbose(true)
+
+	// Act
+	r.Run()
+
+	// Assert
+	output := regexp.MustCompile(`\(\d+\.\d+s\)`).ReplaceAllString(r.Output(), "(0.00s)")
+	output = regexp.MustCompile(`\w+_test\.go:\d+:`).ReplaceAllString(output, "x_test.go:1:")
+	golden := filepath.Join("testdata", "verbose.golden.txt")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, []byte(output), 0644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), output)
+}
+
+func Test_Runner_ShouldFilterCapturedOutput(t *testing.T) {
+	// Arrange
+	defer setTestVerbose()()
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestLogin", F: func(t *testing.T) {
+			fmt.Println("token=s3cr3t")
+			t.Log("retrying with token s3cr3t")
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.parseResults = true // the test doesn't use testdeck.Test
+	var tests []string
+	r.SetOutputFilter(func(test string, b []byte) []byte {
+		tests = append(tests, test)
+		return bytes.ReplaceAll(b, []byte("s3cr3t"), []byte("******"))
+	})
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.NotContains(t, r.Output(), "s3cr3t")
+	assert.Contains(t, r.Output(), "token=******")
+	stats := r.Statistics()
+	require.Len(t, stats, 1)
+	assert.NotContains(t, stats[0].Output, "s3cr3t")
+	assert.Contains(t, tests, "TestLogin")
+}
+
+// chunkReader returns one of its chunks per Read
+type chunkReader struct {
+	chunks []string
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, c.chunks[0])
+	c.chunks = c.chunks[1:]
+	return n, nil
+}
+
+func Test_FilterReader_ShouldPassChunksWithTheirTest(t *testing.T) {
+	// Arrange
+	var calls []string
+	f := &filterReader{
+		r: &chunkReader{chunks: []string{"=== RUN   TestA\n", "out", "put of A\n=== RUN   Te", "stB\n", "B\n"}},
+		fn: func(test string, b []byte) []byte {
+			calls = append(calls, test+": "+string(b))
+			return bytes.ToUpper(b)
+		},
+	}
+
+	// Act
+	out, err := io.ReadAll(f)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "=== RUN   TESTA\nOUTPUT OF A\n=== RUN   TESTB\nB\n", string(out))
+	assert.Equal(t, []string{
+		"TestA: === RUN   TestA\n",
+		"TestA: out",
+		"TestA: put of A\n=== RUN   Te",
+		"TestB: stB\n",
+		"TestB: B\n",
+	}, calls)
+}
@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"testing"
+)
+
+/*
+probe.go: A yes/no "does the suite pass" check for embedders such as health endpoints
+*/
+
+// AllPass runs tests with the settings in cfg and returns true only if every
+// selected test passed. Skipped tests count as passed unless cfg.FailOnSkip is
+// set. A Count that is not set or runs nothing is taken as 1 since running
+// nothing would always pass. The settings are applied to a separate runner,
+// so the Instance is not changed; the stdout settings are global and restored
+// afterwards.
+//
+// Results are read from the verbose output (see RunTestMain). A test that
+// panics or runs past cfg.RunTimeout still ends the process, the same as with
+// go test.
+func AllPass(cfg WireConfig, tests []testing.InternalTest) (bool, error) {
+	defer func(stdout, eventLog bool) {
+		printStdout = stdout
+		printOutputToEventLog = eventLog
+	}(printStdout, printOutputToEventLog)
+
+	if cfg.Count <= 0 {
+		cfg.Count = 1
+	}
+
+	r := newInstance(nil).(*runner)
+	if err := r.FromWire(cfg); err != nil {
+		return false, err
+	}
+	// filter up front: the match workaround only works for tests using testdeck.Test
+	tests = matchingTests(r.matcher, tests)
+	r.m = testing.MainStart(r.deps, tests, make([]testing.InternalBenchmark, 0), make([]testing.InternalFuzzTarget, 0), make([]testing.InternalExample, 0))
+
+	r.parseResults = true
+	restoreVerbose := setTestVerbose()
+	r.Run()
+	restoreVerbose()
+	return r.Passed(), nil
+}
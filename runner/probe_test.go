@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSuite replaces runnerMainStart with one that prints a verbose result
+// line for each test it is given, using results (default PASS)
+func fakeSuite(t *testing.T, results map[string]string) {
+	prev := runnerMainStart
+	t.Cleanup(func() { runnerMainStart = prev })
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for _, test := range tests {
+			_, _, name := MatchTag(test.Name)
+			result, ok := results[name]
+			if !ok {
+				result = "PASS"
+			}
+			fmt.Printf("=== RUN   %s\n--- %s: %s (0.00s)\n", name, result, name)
+		}
+	}
+}
+
+var probeTests = []testing.InternalTest{
+	{Name: "TestA", F: func(t *testing.T) {}},
+	{Name: "TestB", F: func(t *testing.T) {}},
+}
+
+func Test_AllPass_ShouldReturnTrueWhenAllTestsPass(t *testing.T) {
+	// Arrange
+	fakeSuite(t, nil)
+
+	// Act
+	ok, err := AllPass(WireConfig{}, probeTests)
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func Test_AllPass_ShouldReturnFalseWhenAnyTestFails(t *testing.T) {
+	// Arrange
+	fakeSuite(t, map[string]string{"TestB": "FAIL"})
+
+	// Act
+	ok, err := AllPass(WireConfig{}, probeTests)
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func Test_AllPass_ShouldIgnoreFailuresOfUnselectedTests(t *testing.T) {
+	// Arrange
+	fakeSuite(t, map[string]string{"TestB": "FAIL"})
+
+	// Act
+	ok, err := AllPass(WireConfig{MatchPattern: "TestA"}, probeTests)
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func Test_AllPass_ShouldFailOnSkipOnlyWhenSet(t *testing.T) {
+	// Arrange
+	fakeSuite(t, map[string]string{"TestA": "SKIP"})
+
+	// Act
+	lenient, err1 := AllPass(WireConfig{}, probeTests)
+	strict, err2 := AllPass(WireConfig{FailOnSkip: true}, probeTests)
+
+	// Assert
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	assert.True(t, lenient)
+	assert.False(t, strict)
+}
+
+func Test_AllPass_ShouldRejectInvalidPattern(t *testing.T) {
+	// Act
+	ok, err := AllPass(WireConfig{MatchPattern: "Test("}, probeTests)
+
+	// Assert
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
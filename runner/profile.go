@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"io"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+/*
+profile.go: CPU profiling for TestDeps, with an optional limit on how long the profile samples
+*/
+
+// These are pulled out so they can be replaced for unit testing
+var pprofStartCPUProfile = pprof.StartCPUProfile
+var pprofStopCPUProfile = pprof.StopCPUProfile
+
+// cpuProfile is the state of the process' CPU profile (there can only be one)
+var cpuProfile struct {
+	mu         sync.Mutex
+	running    bool
+	generation int // tells a limit timer which profile it was started for
+	stopTimer  func() bool
+}
+
+// startCPUProfile starts the CPU profile and, if limit > 0, stops it again
+// after limit on clk
+func startCPUProfile(w io.Writer, limit time.Duration, clk clock) error {
+	cpuProfile.mu.Lock()
+	defer cpuProfile.mu.Unlock()
+	if err := pprofStartCPUProfile(w); err != nil {
+		return err
+	}
+	cpuProfile.running = true
+	cpuProfile.generation++
+	cpuProfile.stopTimer = nil
+	if limit > 0 {
+		generation := cpuProfile.generation
+		cpuProfile.stopTimer = clk.AfterFunc(limit, func() {
+			stopCPUProfile(generation)
+		})
+	}
+	return nil
+}
+
+// stopCPUProfile stops the CPU profile if it is still running. generation is
+// the profile to stop, or 0 for the current one. Stopping more than once is a
+// no-op.
+func stopCPUProfile(generation int) {
+	cpuProfile.mu.Lock()
+	defer cpuProfile.mu.Unlock()
+	if !cpuProfile.running || (generation != 0 && generation != cpuProfile.generation) {
+		return
+	}
+	cpuProfile.running = false
+	if cpuProfile.stopTimer != nil {
+		cpuProfile.stopTimer()
+		cpuProfile.stopTimer = nil
+	}
+	pprofStopCPUProfile()
+}
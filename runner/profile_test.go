@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProfiler replaces pprof and has a fake clock for the TestDeps, so the
+// profile limit can be tested without profiling or waiting
+type fakeProfiler struct {
+	starts, stops int
+	clock         fakeClock
+}
+
+func newFakeProfiler(t *testing.T) *fakeProfiler {
+	p := &fakeProfiler{}
+	prevStart, prevStop := pprofStartCPUProfile, pprofStopCPUProfile
+	t.Cleanup(func() {
+		pprofStartCPUProfile, pprofStopCPUProfile = prevStart, prevStop
+	})
+	pprofStartCPUProfile = func(w io.Writer) error {
+		p.starts++
+		return nil
+	}
+	pprofStopCPUProfile = func() {
+		p.stops++
+	}
+	return p
+}
+
+func Test_CPUProfile_ShouldStopAtConfiguredDuration(t *testing.T) {
+	// Arrange
+	p := newFakeProfiler(t)
+	deps := TestDeps{cpuProfileDuration: 30 * time.Second, clock: &p.clock}
+	require.NoError(t, deps.StartCPUProfile(io.Discard))
+	require.Len(t, p.clock.timers, 1)
+	require.Equal(t, 30*time.Second, p.clock.timers[0].d)
+	assert.Equal(t, 0, p.stops)
+
+	// Act
+	p.clock.timers[0].fire()
+	deps.StopCPUProfile() // the normal end-of-run stop
+
+	// Assert
+	assert.Equal(t, 1, p.starts)
+	assert.Equal(t, 1, p.stops)
+}
+
+func Test_CPUProfile_ShouldNotLimitByDefault(t *testing.T) {
+	// Arrange
+	p := newFakeProfiler(t)
+	deps := TestDeps{clock: &p.clock}
+
+	// Act
+	require.NoError(t, deps.StartCPUProfile(io.Discard))
+	deps.StopCPUProfile()
+
+	// Assert
+	assert.Empty(t, p.clock.timers)
+	assert.Equal(t, 1, p.stops)
+}
+
+func Test_CPUProfile_ShouldIgnoreTimerOfEarlierProfile(t *testing.T) {
+	// Arrange
+	p := newFakeProfiler(t)
+	deps := TestDeps{cpuProfileDuration: time.Second, clock: &p.clock}
+	require.NoError(t, deps.StartCPUProfile(io.Discard))
+	deps.StopCPUProfile()
+	require.NoError(t, deps.StartCPUProfile(io.Discard))
+
+	// Act
+	p.clock.timers[0].fire() // a timer that fired while the first run was stopping
+
+	// Assert
+	assert.Equal(t, 1, p.stops)
+	p.clock.timers[1].fire()
+	assert.Equal(t, 2, p.stops)
+}
+
+func Test_Runner_ShouldSetCPUProfileDuration(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+
+	// Act
+	r.SetCPUProfileDuration(time.Minute)
+
+	// Assert
+	assert.Equal(t, time.Minute, r.deps.(*TestDeps).cpuProfileDuration)
+}
@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"strings"
+)
+
+/*
+race.go: Attributing the reports of the race detector (in a binary built with -race) to the tests that were running.
+The race detector writes each report straight to the process' stderr, between two lines of "=" and starting with
+"WARNING: DATA RACE", while the test is running, so the report belongs to the test whose output came last.
+*/
+
+const raceReportRule = "=================="
+
+// SetDetectRaces makes a Run capture stderr along with stdout, including the
+// race detector's reports written to the stderr file descriptor where the
+// platform allows redirecting it (Linux), and record each report in the
+// Statistics of the test it was printed during (RaceDetected and
+// RaceReport), failing the test. Tests run verbosely while detecting races so
+// their output can be told apart. Like Statistics.Output, a report printed
+// while parallel tests run may be attributed to another running test, and
+// the result reported to SetOnTestEnd is the one testing reported.
+func (r *runner) SetDetectRaces(yes bool) {
+	r.detectRaces = yes
+}
+
+// recordRaces applies SetDetectRaces to the statistics from first on, with the
+// output of the run that added them
+func (r *runner) recordRaces(first int, output string) {
+	if !r.detectRaces {
+		return
+	}
+	reports := raceReports(output)
+	if len(reports) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := first; i < len(r.stats); i++ {
+		report, ok := reports[r.stats[i].Name]
+		if !ok {
+			continue
+		}
+		r.stats[i].RaceDetected = true
+		r.stats[i].RaceReport = report
+		if !r.stats[i].Failed {
+			r.stats[i].Failed = true
+			r.failures++
+		}
+	}
+}
+
+// raceReports returns the race reports in output by the test they were
+// printed during (see attributeLines), several reports of the same test
+// concatenated
+func raceReports(output string) map[string]string {
+	reports := make(map[string]string)
+	lines := attributeLines(output)
+	for i := 0; i < len(lines); i++ {
+		if !isRaceReportStart(lines, i) {
+			continue
+		}
+		test := lines[i].test
+		var b strings.Builder
+		b.WriteString(lines[i].text)
+		for i++; i < len(lines); i++ {
+			b.WriteString(lines[i].text)
+			if strings.TrimSpace(lines[i].text) == raceReportRule {
+				break
+			}
+		}
+		reports[test] += b.String()
+	}
+	delete(reports, "") // printed outside of any test
+	return reports
+}
+
+func isRaceReportStart(lines []outputLine, i int) bool {
+	return strings.TrimSpace(lines[i].text) == raceReportRule &&
+		i+1 < len(lines) && strings.TrimSpace(lines[i+1].text) == "WARNING: DATA RACE"
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const syntheticRaceReport = "==================\n" +
+	"WARNING: DATA RACE\n" +
+	"Write at 0x00c000012345 by goroutine 8:\n" +
+	"  example.com/pkg.TestRacy.func1()\n" +
+	"      /src/pkg/racy_test.go:12 +0x44\n" +
+	"\n" +
+	"Previous read at 0x00c000012345 by goroutine 7:\n" +
+	"  example.com/pkg.TestRacy()\n" +
+	"      /src/pkg/racy_test.go:15 +0x88\n" +
+	"==================\n"
+
+func Test_Runner_ShouldAttributeRaceReportToTest(t *testing.T) {
+	// Arrange
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestRacy", F: func(t *testing.T) {
+			fmt.Fprint(os.Stderr, syntheticRaceReport)
+		}},
+		{Name: "TestClean", F: func(t *testing.T) {
+			fmt.Println("no race here")
+		}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetDetectRaces(true)
+	r.parseResults = true // the tests don't use testdeck.Test
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	stats := r.Statistics()
+	require.Len(t, stats, 2)
+	assert.Equal(t, "TestRacy", stats[0].Name)
+	assert.True(t, stats[0].RaceDetected)
+	assert.Equal(t, syntheticRaceReport, stats[0].RaceReport)
+	assert.True(t, stats[0].Failed)
+	assert.Equal(t, "TestClean", stats[1].Name)
+	assert.False(t, stats[1].RaceDetected)
+	assert.Empty(t, stats[1].RaceReport)
+	assert.False(t, stats[1].Failed)
+}
+
+func Test_RaceReports_ShouldSplitReportsByTest(t *testing.T) {
+	// Arrange
+	output := "=== RUN   TestA\n" +
+		syntheticRaceReport +
+		"=== RUN   TestB\n" +
+		"==================\n" + // not a race report
+		"=== NAME  TestA\n" +
+		syntheticRaceReport +
+		"--- PASS: TestA (0.00s)\n" +
+		"--- PASS: TestB (0.00s)\n" +
+		"PASS\n" +
+		syntheticRaceReport // after the tests
+
+	// Act
+	reports := raceReports(output)
+
+	// Assert
+	assert.Equal(t, map[string]string{"TestA": syntheticRaceReport + syntheticRaceReport}, reports)
+}
@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"math/rand"
+	"os"
+)
+
+/*
+randseed.go: Seeding the global math/rand source so that tests using it get the same numbers in every Run
+*/
+
+// SetRandSeed makes Run seed the global math/rand source with seed before the
+// tests run, so tests calling rand.Intn etc. get the same numbers in every
+// Run with the same seed, and records it in RunInfo().RandSeed to reproduce
+// a Run. Only the global source of math/rand is affected: sources made with
+// rand.New, other packages' own sources and math/rand/v2 (whose global
+// generator can't be seeded) are not. 0 keeps the global source randomly
+// seeded.
+func (r *runner) SetRandSeed(seed int64) {
+	r.randSeed = seed
+}
+
+// seedGlobalRand seeds the global math/rand source. Since Go 1.24 rand.Seed
+// does nothing unless GODEBUG has randseednop=0, which is read when rand.Seed
+// is called, so it is set for the call only.
+func seedGlobalRand(seed int64) {
+	prev, ok := os.LookupEnv("GODEBUG")
+	godebug := "randseednop=0"
+	if prev != "" {
+		godebug = prev + "," + godebug // the last setting wins
+	}
+	os.Setenv("GODEBUG", godebug)
+	defer func() {
+		if ok {
+			os.Setenv("GODEBUG", prev)
+		} else {
+			os.Unsetenv("GODEBUG")
+		}
+	}()
+	rand.Seed(seed)
+}
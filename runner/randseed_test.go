@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runRandSuite runs a suite that prints numbers from the global math/rand
+// source with seed and returns its output
+func runRandSuite(t *testing.T, seed int64) string {
+	r := newInstance(newTestingM("TestRand")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetRandSeed(seed)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for i := 0; i < 5; i++ {
+			fmt.Println(rand.Intn(1000000))
+		}
+	}
+	r.Run()
+	assert.Equal(t, seed, r.RunInfo().RandSeed)
+	return r.Output()
+}
+
+func Test_SetRandSeed_ShouldRepeatNumbersForSameSeed(t *testing.T) {
+	// Arrange
+	prev, ok := os.LookupEnv("GODEBUG")
+
+	// Act
+	first := runRandSuite(t, 42)
+	second := runRandSuite(t, 42)
+	other := runRandSuite(t, 7)
+
+	// Assert
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, other)
+	after, afterOK := os.LookupEnv("GODEBUG")
+	assert.Equal(t, ok, afterOK, "GODEBUG is restored")
+	assert.Equal(t, prev, after)
+}
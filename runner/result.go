@@ -0,0 +1,208 @@
+package runner
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+result.go: The result of a Run as one value, and merging the results of shards of a suite run on separate machines
+*/
+
+// Result is the outcome of a Run: its summary, the build it ran from and the
+// statistics it added
+type Result struct {
+	ResultSummary
+	BuildInfo
+	Stats  []constants.Statistics `json:"tests"`
+	Shards []string               `json:"shards,omitempty"` // the RunIDs of the results merged with MergeResults, in order
+
+	Artifacts *Artifacts `json:"artifacts,omitempty"` // the files written with SetArtifactDir; nil in a merged result
+}
+
+// BuildInfo identifies the toolchain and the code of the running binary, to
+// tell apart results of runs across Go versions and commits
+type BuildInfo struct {
+	GoVersion   string `json:"go_version"`             // runtime.Version()
+	ModulePath  string `json:"module_path,omitempty"`  // the main module, if built as a module
+	VCSRevision string `json:"vcs_revision,omitempty"` // the commit built from, if go build stamped it (go test doesn't)
+}
+
+// This is pulled out so it can be replaced for unit testing
+var readBuildInfo = debug.ReadBuildInfo
+
+// currentBuildInfo returns the BuildInfo of the running binary
+func currentBuildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+	bi, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+	info.ModulePath = bi.Main.Path
+	for _, setting := range bi.Settings {
+		if setting.Key == "vcs.revision" {
+			info.VCSRevision = setting.Value
+		}
+	}
+	return info
+}
+
+// Result returns the Result of the last Run. Its statistics are sorted by
+// the registration order of their top-level test, then by name, attempt and
+// retry, instead of the order the tests finished in, so that two runs of a
+// suite with parallel tests list them the same way.
+func (r *runner) Result() *Result {
+	stats := append([]constants.Statistics(nil), r.stats[r.statsStart:]...)
+	sortResultStats(stats, r.allTests())
+	return &Result{ResultSummary: Summarize(r.RunInfo(), stats), BuildInfo: currentBuildInfo(), Stats: stats, Artifacts: r.artifacts}
+}
+
+// sortResultStats sorts stats by the index of their top-level test in tests,
+// then by name, attempt and retry. Statistics of tests that are not in tests
+// (e.g. examples) come last.
+func sortResultStats(stats []constants.Statistics, tests []testing.InternalTest) {
+	registered := make(map[string]int, len(tests))
+	for i, test := range tests {
+		// the match workaround tags names, so use the actual name
+		_, _, name := MatchTag(test.Name)
+		if _, ok := registered[name]; !ok {
+			registered[name] = i
+		}
+	}
+	index := func(s constants.Statistics) int {
+		if i, ok := registered[strings.SplitN(s.Name, "/", 2)[0]]; ok {
+			return i
+		}
+		return len(tests)
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if ia, ib := index(a), index(b); ia != ib {
+			return ia < ib
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Attempt != b.Attempt {
+			return a.Attempt < b.Attempt
+		}
+		return a.Retry < b.Retry
+	})
+}
+
+// MergeResults combines the results of shards (e.g. of runs with disjoint
+// patterns on separate machines) into one: the statistics are concatenated,
+// the totals counted from them and the durations summed. A test that ran in
+// more than one shard, with the same final outcome, is only counted once: the
+// statistics of the first shard it ran in are kept and its statistics in the
+// later shards dropped. The merged result has no RunID of its
+// own; the RunIDs of the shards are kept in Shards, flattened if a result was
+// merged already. NoTestsRan is only set if no shard ran a test, and
+// PeakGoroutines is the highest peak of the shards. RandSeed and each
+// BuildInfo field are kept if all the shards agree on them and left empty
+// otherwise. It returns an
+// error if a test has a different final outcome in two shards, which means
+// the shards weren't disjoint.
+func MergeResults(results ...*Result) (*Result, error) {
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no results to merge")
+	}
+
+	merged := &Result{ResultSummary: ResultSummary{OK: true}}
+	outcomes := make(map[string]string) // final outcome of each test so far
+	shardOf := make(map[string]string)  // the shard it was seen in
+	var stats []constants.Statistics
+	var failures []string
+	for i, res := range results {
+		if res == nil {
+			return nil, fmt.Errorf("result %d to merge is nil", i)
+		}
+		if i == 0 {
+			merged.BuildInfo = res.BuildInfo
+			merged.NoTestsRan = res.NoTestsRan
+			merged.RandSeed = res.RandSeed
+		} else {
+			merged.BuildInfo = commonBuildInfo(merged.BuildInfo, res.BuildInfo)
+			merged.NoTestsRan = merged.NoTestsRan && res.NoTestsRan
+			if merged.RandSeed != res.RandSeed {
+				merged.RandSeed = 0
+			}
+		}
+		shards := res.Shards
+		if len(shards) == 0 {
+			shards = []string{res.RunID}
+		}
+		shard := strings.Join(shards, ",")
+
+		mergedBefore := make(map[string]bool) // the tests of res already merged from an earlier shard
+		final := finalOutcomes(res.Stats)
+		// in the order of the statistics, so the same conflict is reported every time
+		for _, s := range res.Stats {
+			name := s.Name
+			outcome, ok := final[name]
+			if !ok {
+				continue // already checked
+			}
+			delete(final, name)
+			if prev, ok := outcomes[name]; ok {
+				if prev != outcome {
+					return nil, fmt.Errorf("conflicting outcomes of %s: %s in shard %s, %s in shard %s", name, prev, shardOf[name], outcome, shard)
+				}
+				mergedBefore[name] = true
+				continue
+			}
+			outcomes[name] = outcome
+			shardOf[name] = shard
+		}
+		for _, s := range res.Stats {
+			if !mergedBefore[s.Name] {
+				stats = append(stats, s)
+			}
+		}
+
+		if peak := res.PeakGoroutines; peak != nil && (merged.PeakGoroutines == nil || peak.Count > merged.PeakGoroutines.Count) {
+			merged.PeakGoroutines = peak
+		}
+		merged.Shards = append(merged.Shards, shards...)
+		merged.OK = merged.OK && res.OK
+		if res.Failure != "" {
+			failures = append(failures, fmt.Sprintf("shard %s: %s", shard, res.Failure))
+		}
+		merged.Duration += res.Duration
+	}
+	merged.Stats = stats
+	merged.countTests(stats)
+	merged.Failure = strings.Join(failures, "; ")
+	return merged, nil
+}
+
+// commonBuildInfo returns the fields a and b agree on
+func commonBuildInfo(a, b BuildInfo) BuildInfo {
+	common := func(x, y string) string {
+		if x != y {
+			return ""
+		}
+		return x
+	}
+	return BuildInfo{
+		GoVersion:   common(a.GoVersion, b.GoVersion),
+		ModulePath:  common(a.ModulePath, b.ModulePath),
+		VCSRevision: common(a.VCSRevision, b.VCSRevision),
+	}
+}
+
+// finalOutcomes returns the Outcome of each test in stats, of its last
+// statistics if it ran more than once (e.g. retried)
+func finalOutcomes(stats []constants.Statistics) map[string]string {
+	outcomes := make(map[string]string)
+	for _, s := range stats {
+		outcomes[s.Name] = Outcome(s)
+	}
+	return outcomes
+}
@@ -0,0 +1,295 @@
+package runner
+
+import (
+	"encoding/json"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shardResult returns the result of a shard with the given statistics
+func shardResult(runID string, d time.Duration, stats ...constants.Statistics) *Result {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &Result{
+		ResultSummary: Summarize(RunInfo{RunID: runID, StartedAt: start, FinishedAt: start.Add(d)}, stats),
+		Stats:         stats,
+	}
+}
+
+func Test_MergeResults_ShouldSumTotalsOfDisjointShards(t *testing.T) {
+	// Arrange
+	a := shardResult("run-a", 2*time.Second,
+		constants.Statistics{Name: "TestA"},
+		constants.Statistics{Name: "TestB", Failed: true},
+	)
+	b := shardResult("run-b", 3*time.Second,
+		constants.Statistics{Name: "TestC"},
+		constants.Statistics{Name: "TestD", Statuses: []constants.Status{{Status: constants.StatusSkip}}},
+		constants.Statistics{Name: "TestE", Flaky: true},
+	)
+
+	// Act
+	merged, err := MergeResults(a, b)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run-a", "run-b"}, merged.Shards)
+	assert.Equal(t, 5*time.Second, merged.Duration)
+	assert.Equal(t, 5, merged.Total)
+	assert.Equal(t, 2, merged.Passed)
+	assert.Equal(t, 1, merged.Flaky)
+	assert.Equal(t, 1, merged.Failed)
+	assert.Equal(t, 1, merged.Skipped)
+	assert.Equal(t, []string{"TestB"}, merged.Failures)
+	assert.False(t, merged.OK)
+	assert.Len(t, merged.Stats, 5)
+	assert.Empty(t, merged.RunID)
+}
+
+func Test_MergeResults_ShouldFlattenShardsOfMergedResults(t *testing.T) {
+	// Arrange
+	ab, err := MergeResults(shardResult("run-a", time.Second), shardResult("run-b", time.Second))
+	require.NoError(t, err)
+
+	// Act
+	merged, err := MergeResults(ab, shardResult("run-c", time.Second))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run-a", "run-b", "run-c"}, merged.Shards)
+	assert.True(t, merged.OK)
+}
+
+func Test_MergeResults_ShouldJoinRunFailures(t *testing.T) {
+	// Arrange
+	a := shardResult("run-a", time.Second)
+	a.Failure, a.OK = "coverage too low", false
+	b := shardResult("run-b", time.Second)
+
+	// Act
+	merged, err := MergeResults(a, b)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "shard run-a: coverage too low", merged.Failure)
+	assert.False(t, merged.OK)
+}
+
+func Test_MergeResults_ShouldRejectInvalidResults(t *testing.T) {
+	cases := map[string]struct {
+		results []*Result
+	}{
+		"NoResults": {},
+		"NilResult": {results: []*Result{shardResult("run-a", time.Second), nil}},
+		"Conflict": {results: []*Result{
+			shardResult("run-a", time.Second, constants.Statistics{Name: "TestA"}),
+			shardResult("run-b", time.Second, constants.Statistics{Name: "TestA", Failed: true}),
+		}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Act
+			_, err := MergeResults(tc.results...)
+
+			// Assert
+			assert.Error(t, err)
+		})
+	}
+}
+
+func Test_MergeResults_ShouldReportFirstConflictInOrder(t *testing.T) {
+	// Arrange
+	var statsA, statsB []constants.Statistics
+	for _, name := range []string{"TestD", "TestB", "TestC", "TestA"} {
+		statsA = append(statsA, constants.Statistics{Name: name})
+		statsB = append(statsB, constants.Statistics{Name: name, Failed: true})
+	}
+	a, b := shardResult("run-a", time.Second, statsA...), shardResult("run-b", time.Second, statsB...)
+
+	for i := 0; i < 20; i++ { // a map order would vary
+		// Act
+		_, err := MergeResults(a, b)
+
+		// Assert
+		require.Error(t, err)
+		assert.Equal(t, "conflicting outcomes of TestD: Pass in shard run-a, Fail in shard run-b", err.Error())
+	}
+}
+
+func Test_MergeResults_ShouldUseFinalOutcomeOfRetriedTest(t *testing.T) {
+	// Arrange
+	a := shardResult("run-a", time.Second,
+		constants.Statistics{Name: "TestA", Failed: true},
+		constants.Statistics{Name: "TestA", Retry: 1, Flaky: true},
+	)
+	b := shardResult("run-b", time.Second, constants.Statistics{Name: "TestA"})
+
+	// Act
+	_, err := MergeResults(a, b)
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func Test_MergeResults_ShouldCountTestOfOverlappingShardsOnce(t *testing.T) {
+	// Arrange
+	a := shardResult("run-a", time.Second,
+		constants.Statistics{Name: "TestA"},
+		constants.Statistics{Name: "TestB", Failed: true},
+	)
+	b := shardResult("run-b", time.Second,
+		constants.Statistics{Name: "TestB", Failed: true},
+		constants.Statistics{Name: "TestC"},
+	)
+
+	// Act
+	merged, err := MergeResults(a, b)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 3, merged.Total)
+	assert.Equal(t, 2, merged.Passed)
+	assert.Equal(t, 1, merged.Failed)
+	assert.Equal(t, []string{"TestB"}, merged.Failures)
+	var names []string
+	for _, s := range merged.Stats {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"TestA", "TestB", "TestC"}, names)
+}
+
+func Test_Runner_ShouldReturnResultOfLastRun(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	r.SetRunID("shard-1")
+	r.startRunInfo()
+	r.AddStatistics(&constants.Statistics{Name: "TestA"})
+	r.AddStatistics(&constants.Statistics{Name: "TestB", Failed: true})
+
+	// Act
+	res := r.Result()
+
+	// Assert
+	assert.Equal(t, "shard-1", res.RunID)
+	assert.Equal(t, 2, res.Total)
+	assert.Equal(t, []string{"TestB"}, res.Failures)
+	assert.Len(t, res.Stats, 2)
+}
+
+func Test_Runner_ShouldSortResultIndependentOfCompletionOrder(t *testing.T) {
+	// Arrange
+	// like testdeck.Test: parallel, statistics added when the test finishes
+	var r *runner
+	delays := map[string]time.Duration{}
+	test := func(name string) testing.InternalTest {
+		return testing.InternalTest{Name: name, F: func(t *testing.T) {
+			t.Parallel()
+			t.Run("Sub", func(t *testing.T) {
+				r.AddStatistics(&constants.Statistics{Name: t.Name()})
+			})
+			time.Sleep(delays[name])
+			r.AddStatistics(&constants.Statistics{Name: name, Duration: delays[name]})
+		}}
+	}
+	r = newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		test("TestSlow"), test("TestFast"), test("TestMiddle"),
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	runIsolated(t)
+	names := func(res *Result) []string {
+		data, err := json.Marshal(res.Stats)
+		require.NoError(t, err)
+		var entries []struct{ Name string }
+		require.NoError(t, json.Unmarshal(data, &entries))
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+
+	// Act
+	delays["TestSlow"], delays["TestMiddle"] = 20*time.Millisecond, 10*time.Millisecond
+	r.Run()
+	first := r.Result()
+	delays["TestSlow"], delays["TestFast"] = 0, 20*time.Millisecond
+	r.Run()
+	second := r.Result()
+
+	// Assert
+	want := []string{"TestSlow", "TestSlow/Sub", "TestFast", "TestFast/Sub", "TestMiddle", "TestMiddle/Sub"}
+	assert.Equal(t, want, names(first))
+	assert.Equal(t, want, names(second))
+	for _, s := range first.Stats {
+		if s.Name == "TestSlow" {
+			assert.Equal(t, 20*time.Millisecond, s.Duration) // durations stay with their test
+		}
+	}
+}
+
+func Test_Runner_ShouldRecordBuildInfoInResult(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	r.startRunInfo()
+
+	// Act
+	res := r.Result()
+
+	// Assert
+	assert.Equal(t, runtime.Version(), res.GoVersion)
+	assert.NotEmpty(t, res.GoVersion)
+	assert.Equal(t, "github.com/mercari/testdeck", res.ModulePath) // the test binary's main module
+}
+
+func Test_CurrentBuildInfo_ShouldReadVCSRevision(t *testing.T) {
+	// Arrange
+	defer func(prev func() (*debug.BuildInfo, bool)) { readBuildInfo = prev }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/app"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs", Value: "git"},
+				{Key: "vcs.revision", Value: "0123abcd"},
+			},
+		}, true
+	}
+
+	// Act
+	info := currentBuildInfo()
+
+	// Assert
+	assert.Equal(t, BuildInfo{GoVersion: runtime.Version(), ModulePath: "example.com/app", VCSRevision: "0123abcd"}, info)
+}
+
+func Test_CurrentBuildInfo_ShouldOnlyHaveGoVersionWithoutBuildInfo(t *testing.T) {
+	// Arrange
+	defer func(prev func() (*debug.BuildInfo, bool)) { readBuildInfo = prev }(readBuildInfo)
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return nil, false }
+
+	// Act
+	info := currentBuildInfo()
+
+	// Assert
+	assert.Equal(t, BuildInfo{GoVersion: runtime.Version()}, info)
+}
+
+func Test_MergeResults_ShouldKeepBuildInfoFieldsShardsAgreeOn(t *testing.T) {
+	// Arrange
+	a := shardResult("run-a", time.Second)
+	a.BuildInfo = BuildInfo{GoVersion: "go1.27.1", ModulePath: "example.com/app", VCSRevision: "aaaa"}
+	b := shardResult("run-b", time.Second)
+	b.BuildInfo = BuildInfo{GoVersion: "go1.27.1", ModulePath: "example.com/app", VCSRevision: "bbbb"}
+
+	// Act
+	merged, err := MergeResults(a, b)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, BuildInfo{GoVersion: "go1.27.1", ModulePath: "example.com/app"}, merged.BuildInfo)
+}
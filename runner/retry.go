@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+retry.go: Re-running failed tests within a Run, with an optional backoff between attempts
+*/
+
+// SetRetries makes Run re-run the (top-level) tests that failed up to n more
+// times, until they pass. A test that passes on a retry doesn't fail the run
+// (see Passed) but is marked Statistics.Flaky; its failed attempts are kept in
+// the statistics with a lower Statistics.Retry.
+func (r *runner) SetRetries(n int) {
+	r.retries = n
+}
+
+// SetRetryBackoff makes Run wait before each retry (see SetRetries), starting
+// with d and multiplying the delay by factor after every retry, e.g. 1s, 2s,
+// 4s with a factor of 2. A factor below 1 is taken as 1 (a constant delay).
+func (r *runner) SetRetryBackoff(d time.Duration, factor float64) {
+	r.retryBackoff = d
+	r.retryFactor = factor
+}
+
+// SetRetryBudget caps the number of retries (see SetRetries) of all the tests
+// of a Run together at n, e.g. to bound the time a very flaky suite takes.
+// Each retry of a test takes one from the budget; once it is used up, the
+// tests that still fail are not retried even if they have retries left. The
+// tests are retried in the order they failed. What is left is in
+// RunInfo().RetryBudgetLeft and the Result. 0 means no cap.
+func (r *runner) SetRetryBudget(n int) {
+	r.retryBudget = n
+}
+
+// retryFailures re-runs the tests that failed in the statistics from index
+// first, as set by SetRetries
+func (r *runner) retryFailures(first int) {
+	defer func() { r.retry = 0 }()
+
+	factor := r.retryFactor
+	if factor < 1 {
+		factor = 1
+	}
+	delay := r.retryBackoff
+	var waited time.Duration
+
+	for r.retry = 1; r.retry <= r.retries && r.cancelled() == nil; r.retry++ {
+		failed := failedTests(r.stats[first:])
+		if len(failed) == 0 {
+			return
+		}
+		if failed = r.takeRetryBudget(failed); len(failed) == 0 {
+			return
+		}
+		if delay > 0 {
+			r.clock.Sleep(delay)
+			waited += delay
+			delay = time.Duration(float64(delay) * factor)
+		}
+		r.LogEvent(fmt.Sprintf("Retry %d of %d: %s", r.retry, r.retries, strings.Join(failed, ", ")))
+
+		first = len(r.stats)
+		pattern := namesPattern(failed)
+		m, err := NewMatcher(pattern)
+		if err != nil {
+			panic(err) // the names are quoted
+		}
+		r.output += r.runOnce(m, pattern)
+		for i := first; i < len(r.stats); i++ {
+			r.stats[i].RetryBackoff = waited
+			r.stats[i].Flaky = !r.stats[i].Failed && failedBefore(r.stats[r.statsStart:first], r.stats[i].Name)
+		}
+	}
+}
+
+// takeRetryBudget returns the failed tests that are retried with the budget
+// of SetRetryBudget, taking them from it
+func (r *runner) takeRetryBudget(failed []string) []string {
+	if r.runInfo.RetryBudgetLeft == nil {
+		return failed
+	}
+	left := *r.runInfo.RetryBudgetLeft
+	if len(failed) > left {
+		r.LogEvent(fmt.Sprintf("Retry budget of %d used up, not retrying: %s", r.retryBudget, strings.Join(failed[left:], ", ")))
+		failed = failed[:left]
+	}
+	left -= len(failed)
+	r.mu.Lock()
+	r.runInfo.RetryBudgetLeft = &left // a new one, as RunInfo() copies are shared
+	r.mu.Unlock()
+	return failed
+}
+
+// failedBefore returns true if the test named name failed in stats
+func failedBefore(stats []constants.Statistics, name string) bool {
+	for _, s := range stats {
+		if s.Name == name && s.Failed {
+			return true
+		}
+	}
+	return false
+}
+
+// failedTests returns the (top-level) tests that failed in their last retry
+func failedTests(stats []constants.Statistics) []string {
+	lastRetry := make(map[string]int)
+	for _, s := range stats {
+		name := strings.SplitN(s.Name, "/", 2)[0]
+		if s.Retry > lastRetry[name] {
+			lastRetry[name] = s.Retry
+		}
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, s := range stats {
+		name := strings.SplitN(s.Name, "/", 2)[0]
+		if s.Failed && s.Retry == lastRetry[name] && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,208 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock records sleeps instead of waiting and timers instead of starting
+// them
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+	timers []fakeTimer
+	ticks  []chan time.Time // of the tickers, in order; a send "advances the clock" to the next tick
+}
+
+// fakeTimer is a timer of a fakeClock. Calling fire "advances the clock" to it.
+type fakeTimer struct {
+	d       time.Duration
+	fire    func()
+	stopped bool
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) func() bool {
+	i := len(c.timers)
+	c.timers = append(c.timers, fakeTimer{d: d, fire: f})
+	return func() bool {
+		c.timers[i].stopped = true
+		return true
+	}
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	tick := make(chan time.Time)
+	c.ticks = append(c.ticks, tick)
+	return tick, func() {}
+}
+
+// fakeFlakySuite replaces runnerMainStart with one that runs the selected
+// tests of r, failing each test for its first failures[name] runs
+func fakeFlakySuite(t *testing.T, r Runner, failures map[string]int) (runs map[string]int) {
+	runs = make(map[string]int)
+	prev := runnerMainStart
+	t.Cleanup(func() { runnerMainStart = prev })
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for _, test := range tests {
+			if tagged, matched, _ := MatchTag(test.Name); tagged && !matched {
+				continue
+			}
+			_, _, name := MatchTag(test.Name)
+			runs[name]++
+			r.AddStatistics(&constants.Statistics{Name: name, Failed: runs[name] <= failures[name]})
+		}
+	}
+	return runs
+}
+
+func Test_Retry_ShouldGrowBackoffByFactor(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestDown")).(*runner)
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r.setClock(clock)
+	r.SetRetries(3)
+	r.SetRetryBackoff(time.Second, 2)
+	runs := fakeFlakySuite(t, r, map[string]int{"TestDown": 10})
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, clock.sleeps)
+	assert.Equal(t, 4, runs["TestDown"])
+	stats := r.Statistics()
+	require.Equal(t, 4, len(stats))
+	for i, want := range []time.Duration{0, time.Second, 3 * time.Second, 7 * time.Second} {
+		assert.Equal(t, i, stats[i].Retry)
+		assert.Equal(t, want, stats[i].RetryBackoff)
+	}
+	assert.False(t, r.Passed())
+	assert.Equal(t, 7*time.Second, r.RunInfo().FinishedAt.Sub(r.RunInfo().StartedAt))
+}
+
+func Test_Retry_ShouldOnlyRerunFailedTestsUntilTheyPass(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestStable", "TestFlaky")).(*runner)
+	clock := &fakeClock{}
+	r.setClock(clock)
+	r.SetRetries(3)
+	runs := fakeFlakySuite(t, r, map[string]int{"TestFlaky": 1})
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, map[string]int{"TestStable": 1, "TestFlaky": 2}, runs)
+	assert.Empty(t, clock.sleeps, "no backoff set")
+	assert.True(t, r.Passed(), "the flaky test passed on its retry")
+	assert.Equal(t, 3, len(r.Statistics()))
+}
+
+func Test_SetRetryBudget_ShouldStopRetryingOnceUsedUp(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestDownA", "TestDownB", "TestFlaky")).(*runner)
+	r.setClock(&fakeClock{})
+	r.SetRetries(3)
+	r.SetRetryBudget(4)
+	runs := fakeFlakySuite(t, r, map[string]int{"TestDownA": 10, "TestDownB": 10, "TestFlaky": 1})
+
+	// Act
+	r.Run()
+
+	// Assert
+	// 3 retries in the first round, 1 in the second; each test had 3
+	assert.Equal(t, map[string]int{"TestDownA": 3, "TestDownB": 2, "TestFlaky": 2}, runs)
+	require.NotNil(t, r.RunInfo().RetryBudgetLeft)
+	assert.Equal(t, 0, *r.RunInfo().RetryBudgetLeft)
+	assert.Equal(t, 0, *r.Result().RetryBudgetLeft)
+}
+
+func Test_SetRetryBudget_ShouldRecordBudgetLeft(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestStable", "TestFlaky")).(*runner)
+	r.setClock(&fakeClock{})
+	r.SetRetries(3)
+	r.SetRetryBudget(5)
+	fakeFlakySuite(t, r, map[string]int{"TestFlaky": 1})
+
+	// Act
+	r.Run()
+
+	// Assert
+	require.NotNil(t, r.Result().RetryBudgetLeft)
+	assert.Equal(t, 4, *r.Result().RetryBudgetLeft)
+	assert.True(t, r.Passed())
+}
+
+func Test_Retry_ShouldMarkTestPassingOnRetryFlaky(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestStable", "TestFlaky", "TestDown")).(*runner)
+	r.setClock(&fakeClock{})
+	r.SetRetries(2)
+	fakeFlakySuite(t, r, map[string]int{"TestFlaky": 1, "TestDown": 10})
+
+	// Act
+	r.Run()
+
+	// Assert
+	flaky := make(map[string][]bool)
+	for _, s := range r.Statistics() {
+		flaky[s.Name] = append(flaky[s.Name], s.Flaky)
+	}
+	assert.Equal(t, map[string][]bool{
+		"TestStable": {false},
+		"TestFlaky":  {false, true},
+		"TestDown":   {false, false, false},
+	}, flaky)
+	summary := Summarize(r.RunInfo(), r.Statistics())
+	assert.Equal(t, 3, summary.Total, "by last retry")
+	assert.Equal(t, 6, summary.Attempts)
+	assert.Equal(t, 1, summary.Passed, "clean passes only")
+	assert.Equal(t, 1, summary.Flaky)
+	assert.Equal(t, 1, summary.Failed)
+}
+
+func Test_Retry_ShouldUseConstantBackoffForFactorBelowOne(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestDown")).(*runner)
+	clock := &fakeClock{}
+	r.setClock(clock)
+	r.SetRetries(2)
+	r.SetRetryBackoff(500*time.Millisecond, 0)
+	fakeFlakySuite(t, r, map[string]int{"TestDown": 10})
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}, clock.sleeps)
+}
+
+func Test_FailedTests_ShouldUseLastRetry(t *testing.T) {
+	// Arrange
+	stats := []constants.Statistics{
+		{Name: "TestA", Failed: true},
+		{Name: "TestA/sub", Failed: true},
+		{Name: "TestB", Failed: true},
+		{Name: "TestC"},
+		{Name: "TestA", Retry: 1},
+		{Name: "TestB", Failed: true, Retry: 1},
+	}
+
+	// Act
+	failed := failedTests(stats)
+
+	// Assert
+	assert.Equal(t, []string{"TestB"}, failed)
+}
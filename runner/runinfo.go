@@ -0,0 +1,200 @@
+package runner
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+/*
+runinfo.go: Run-level metadata for correlating the artifacts (profiles, logs, saved results) of a Run
+*/
+
+// RunInfo identifies a Run
+type RunInfo struct {
+	RunID      string // the ID set with SetRunID, or a random UUID generated for the Run
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Host       string
+
+	CoverMode       string  // the -covermode of a coverage build (go test -cover); empty otherwise
+	CoveragePercent float64 // statement coverage of the process at the end of the Run, if CoverMode is set
+
+	Failure       string // why the Run failed other than by failed tests (see SetStrict, SetMinCoverage and SetPerTestBudget); empty if it didn't
+	PackageOutput string // the output that didn't belong to a test (see SetOnPackageOutput)
+	NoTestsRan    bool   // the run and skip patterns left no test or example to run (see SetFailOnNoTests)
+
+	HeapBackoff time.Duration // total time parallel tests waited for the heap to shrink (see SetHeapBackoff)
+	RandSeed    int64         // the seed of the global math/rand source set with SetRandSeed; 0 if it wasn't set
+
+	PeakGoroutines  *GoroutinePeak // the most goroutines sampled during the Run (see SetTrackPeakGoroutines); nil if not tracked
+	RetryBudgetLeft *int           // the retries left of the budget set with SetRetryBudget; nil without a budget
+
+	// memory use of the whole process during the Run, from runtime.MemStats
+	TotalAlloc uint64        // bytes allocated
+	Mallocs    uint64        // heap objects allocated
+	NumGC      uint32        // completed GC cycles
+	GCPause    time.Duration // total stop-the-world GC pause time
+}
+
+// RunInfo returns the metadata of the last Run
+func (r *runner) RunInfo() RunInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.runInfo
+}
+
+// SetRunID sets the ID of the following Runs, e.g. to keep the same ID when
+// retrying a run. An empty id generates a new ID for each Run.
+func (r *runner) SetRunID(id string) {
+	r.runID = id
+}
+
+// startRunInfo stamps the start of a Run
+func (r *runner) startRunInfo() {
+	id := r.runID
+	if id == "" {
+		id = newRunID()
+	}
+	host, _ := os.Hostname()
+	r.runInfo = RunInfo{
+		RunID:     id,
+		StartedAt: r.clock.Now(),
+		Host:      host,
+	}
+	if r.retryBudget > 0 {
+		left := r.retryBudget
+		r.runInfo.RetryBudgetLeft = &left
+	}
+	runtime.ReadMemStats(&r.memStart)
+	r.statsStart = len(r.stats)
+}
+
+// finishRunInfo stamps the end of a Run
+func (r *runner) finishRunInfo() {
+	r.runInfo.FinishedAt = r.clock.Now()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	r.runInfo.TotalAlloc = mem.TotalAlloc - r.memStart.TotalAlloc
+	r.runInfo.Mallocs = mem.Mallocs - r.memStart.Mallocs
+	r.runInfo.NumGC = mem.NumGC - r.memStart.NumGC
+	r.runInfo.GCPause = time.Duration(mem.PauseTotalNs - r.memStart.PauseTotalNs)
+	// testing keeps the coverage state of the test binary's own MainStart since
+	// TestDeps doesn't register any, so this works for the runner's runs as well
+	r.runInfo.CoverMode = testing.CoverMode()
+	if r.runInfo.CoverMode != "" {
+		r.runInfo.CoveragePercent = testing.Coverage() * 100
+	}
+	r.checkCancelled()
+	r.checkTimeouts()
+	r.checkCoverage()
+	r.checkBudget()
+}
+
+// checkCancelled fails the Run if tests were held back because its context
+// was done, see RunContext
+func (r *runner) checkCancelled() {
+	r.mu.Lock()
+	heldBack := r.heldBack
+	r.mu.Unlock()
+	if heldBack == 0 || r.runInfo.Failure != "" {
+		return
+	}
+	r.failRun("", &CancelledError{NotRun: heldBack, Err: r.cancelled()})
+}
+
+// checkTimeouts fails the Run if tests ran past the per-test timeout, see
+// SetPerTestTimeout
+func (r *runner) checkTimeouts() {
+	if r.testTimeout <= 0 || r.runInfo.Failure != "" {
+		return
+	}
+	timedOut := &TimeoutError{Timeout: r.testTimeout}
+	for _, s := range r.stats[r.statsStart:] {
+		if s.TimeoutStacks != "" {
+			timedOut.Tests = append(timedOut.Tests, r.reportName(s.Name))
+		}
+	}
+	if len(timedOut.Tests) > 0 {
+		r.failRun("", timedOut)
+	}
+}
+
+// SetMinCoverage fails the Run (see Passed and RunInfo().Failure) if the
+// statement coverage at its end is below percent. It only applies to coverage
+// builds (go test -cover); otherwise a warning is recorded instead. 0 turns
+// the check off.
+func (r *runner) SetMinCoverage(percent float64) {
+	r.minCoverage = percent
+}
+
+func (r *runner) checkCoverage() {
+	if r.minCoverage <= 0 || r.runInfo.Failure != "" {
+		return
+	}
+	if r.runInfo.CoverMode == "" {
+		r.addWarning(fmt.Sprintf("minimum coverage of %.1f%% ignored: not a coverage build", r.minCoverage))
+		return
+	}
+	if r.runInfo.CoveragePercent < r.minCoverage {
+		r.runInfo.Failure = fmt.Sprintf("coverage %.1f%% of statements is below the minimum of %.1f%%", r.runInfo.CoveragePercent, r.minCoverage)
+	}
+}
+
+// SetPerTestBudget fails the Run (see Passed and RunInfo().Failure) if any
+// test or subtest it ran took longer than d, listing the tests over budget.
+// Unlike SetPerTestTimeout the tests are not stopped or failed themselves;
+// only the Run's result is. 0 turns the check off.
+func (r *runner) SetPerTestBudget(d time.Duration) {
+	r.testBudget = d
+}
+
+func (r *runner) checkBudget() {
+	if r.testBudget <= 0 || r.runInfo.Failure != "" {
+		return
+	}
+	over := &BudgetError{Budget: r.testBudget}
+	for _, s := range r.stats[r.statsStart:] {
+		if s.Duration > r.testBudget {
+			over.Tests = append(over.Tests, r.reportName(s.Name))
+			over.Durations = append(over.Durations, s.Duration)
+		}
+	}
+	if len(over.Tests) > 0 {
+		r.failRun("", over)
+	}
+}
+
+// coverageLine returns the "coverage: NN.N% of statements" line that go test
+// -cover prints, or false if info is not from a coverage build
+func coverageLine(info RunInfo) (string, bool) {
+	if info.CoverMode == "" {
+		return "", false
+	}
+	return fmt.Sprintf("coverage: %.1f%% of statements", info.CoveragePercent), true
+}
+
+// SetMemSummary makes ReportStatistics print a line with the memory use of
+// the last Run (see RunInfo)
+func (r *runner) SetMemSummary(yes bool) {
+	r.memSummary = yes
+}
+
+// memLine returns the line ReportStatistics prints for SetMemSummary
+func memLine(info RunInfo) string {
+	return fmt.Sprintf("memory: %d bytes in %d allocations, %d GCs pausing %s", info.TotalAlloc, info.Mallocs, info.NumGC, info.GCPause)
+}
+
+// newRunID returns a random (version 4) UUID
+func newRunID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err) // crypto/rand.Read doesn't fail on supported platforms
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
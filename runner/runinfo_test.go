@@ -0,0 +1,252 @@
+package runner
+
+import (
+	"bytes"
+	"regexp"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunInfo_ShouldBracketRun(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	var during time.Time
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		during = time.Now()
+		r.AddStatistics(&constants.Statistics{Name: "TestA"})
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	info := r.RunInfo()
+	assert.False(t, info.StartedAt.After(during))
+	assert.False(t, info.FinishedAt.Before(during))
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), info.RunID)
+	assert.NotEmpty(t, info.Host)
+	assert.Equal(t, info.RunID, r.Statistics()[0].RunID)
+}
+
+func Test_RunInfo_ShouldGenerateNewIDPerRun(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {}
+
+	// Act
+	r.Run()
+	first := r.RunInfo().RunID
+	r.Run()
+
+	// Assert
+	assert.NotEqual(t, first, r.RunInfo().RunID)
+}
+
+func Test_RunInfo_ShouldKeepProvidedRunID(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		r.AddStatistics(&constants.Statistics{Name: "TestA"})
+	}
+	defer r.PrintToStdout(printStdout)
+	defer r.PrintOutputToEventLog(printOutputToEventLog)
+	require.NoError(t, r.FromWire(WireConfig{RunID: "nightly-42", SuppressStdout: !printStdout}))
+
+	// Act
+	r.Run()
+	r.Run() // a retry
+
+	// Assert
+	assert.Equal(t, "nightly-42", r.RunInfo().RunID)
+	stats := r.Statistics()
+	require.Equal(t, 2, len(stats))
+	assert.Equal(t, "nightly-42", stats[0].RunID)
+	assert.Equal(t, "nightly-42", stats[1].RunID)
+}
+
+func Test_RunInfo_ShouldReportCoverageOfCoverageBuild(t *testing.T) {
+	if testing.CoverMode() == "" {
+		t.Skip("needs a coverage build (go test -cover)")
+	}
+
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {}
+
+	// Act
+	r.Run()
+
+	// Assert
+	info := r.RunInfo()
+	assert.NotEmpty(t, info.CoverMode)
+	assert.Greater(t, info.CoveragePercent, 0.0)
+	assert.Less(t, info.CoveragePercent, 100.0, "the runner package is only partly covered")
+	line, ok := coverageLine(info)
+	assert.True(t, ok)
+	assert.Regexp(t, `^coverage: \d+\.\d% of statements$`, line)
+}
+
+func Test_CoverageLine_ShouldBeOmittedWithoutCoverage(t *testing.T) {
+	// Act
+	_, ok := coverageLine(RunInfo{CoveragePercent: 0})
+	line, okCovered := coverageLine(RunInfo{CoverMode: "set", CoveragePercent: 42.06})
+
+	// Assert
+	assert.False(t, ok)
+	assert.True(t, okCovered)
+	assert.Equal(t, "coverage: 42.1% of statements", line)
+}
+
+func Test_MinCoverage_ShouldFailRunBelowThreshold(t *testing.T) {
+	if testing.CoverMode() == "" {
+		t.Skip("needs a coverage build (go test -cover)")
+	}
+	cases := map[string]struct {
+		minCoverage float64
+		wantPassed  bool
+	}{
+		"Below": {minCoverage: 100, wantPassed: false},
+		"Above": {minCoverage: 0.01, wantPassed: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			r := newInstance(newFakeTestingM()).(*runner)
+			r.SetMinCoverage(tc.minCoverage)
+			defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+			runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {}
+
+			// Act
+			r.Run()
+
+			// Assert
+			assert.Equal(t, tc.wantPassed, r.Passed())
+			if tc.wantPassed {
+				assert.Empty(t, r.RunInfo().Failure)
+			} else {
+				assert.Contains(t, r.RunInfo().Failure, "below the minimum of 100.0%")
+			}
+		})
+	}
+}
+
+func Test_MinCoverage_ShouldBeIgnoredWithoutCoverage(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	r.SetMinCoverage(80)
+	r.runInfo = RunInfo{} // not a coverage build
+
+	// Act
+	r.checkCoverage()
+
+	// Assert
+	assert.True(t, r.Passed())
+	require.Equal(t, 1, len(r.Warnings()))
+	assert.Contains(t, r.Warnings()[0], "not a coverage build")
+}
+
+func Test_MinCoverage_ShouldCompareWithCoverage(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	r.SetMinCoverage(80)
+	r.runInfo = RunInfo{CoverMode: "set", CoveragePercent: 79.94}
+
+	// Act
+	r.checkCoverage()
+
+	// Assert
+	assert.False(t, r.Passed())
+	assert.Equal(t, "coverage 79.9% of statements is below the minimum of 80.0%", r.RunInfo().Failure)
+}
+
+func Test_PerTestBudget_ShouldFailRunWithSlowTest(t *testing.T) {
+	cases := map[string]struct {
+		budget      time.Duration
+		wantPassed  bool
+		wantFailure string
+	}{
+		"Over":  {budget: time.Second, wantPassed: false, wantFailure: "over the per-test budget of 1s: TestSlow (1.5s)"},
+		"Under": {budget: 2 * time.Second, wantPassed: true},
+		"Off":   {budget: 0, wantPassed: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			r := newInstance(newFakeTestingM()).(*runner)
+			r.SetPerTestBudget(tc.budget)
+			r.AddStatistics(&constants.Statistics{Name: "TestSlowEarlierRun", Duration: time.Hour})
+			defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+			runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+				r.AddStatistics(&constants.Statistics{Name: "TestFast", Duration: time.Millisecond})
+				r.AddStatistics(&constants.Statistics{Name: "TestSlow", Duration: 1500 * time.Millisecond})
+			}
+
+			// Act
+			r.Run()
+
+			// Assert
+			assert.Equal(t, tc.wantPassed, r.Passed())
+			assert.Equal(t, tc.wantFailure, r.RunInfo().Failure)
+		})
+	}
+}
+
+func Test_RunInfo_ShouldRecordMemoryUseOfRun(t *testing.T) {
+	// Arrange
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	r := newInstance(newFakeTestingM()).(*runner)
+	var sink [][]byte
+	allocate := 0
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for i := 0; i < allocate; i++ {
+			sink = append(sink, make([]byte, 1024))
+		}
+		runtime.GC()
+	}
+	allocate = 10
+	r.Run()
+	small := r.RunInfo()
+	sink = nil
+
+	// Act
+	allocate = 10000
+	r.Run()
+	large := r.RunInfo()
+
+	// Assert
+	assert.GreaterOrEqual(t, large.TotalAlloc, uint64(10000*1024))
+	assert.Greater(t, large.TotalAlloc, small.TotalAlloc)
+	assert.GreaterOrEqual(t, large.Mallocs, uint64(10000))
+	assert.Greater(t, large.Mallocs, small.Mallocs)
+	assert.NotZero(t, large.NumGC)
+	assert.NotZero(t, large.GCPause)
+	assert.NotEmpty(t, sink)
+}
+
+func Test_ReportStatistics_ShouldPrintMemSummary(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	r.runInfo = RunInfo{TotalAlloc: 2048, Mallocs: 10, NumGC: 2, GCPause: 3 * time.Millisecond}
+	r.SetMemSummary(true)
+	var out bytes.Buffer
+	r.SetOutput(&out)
+
+	// Act
+	r.ReportStatistics()
+
+	// Assert
+	assert.Equal(t, "memory: 2048 bytes in 10 allocations, 2 GCs pausing 3ms\n", out.String())
+}
@@ -1,15 +1,20 @@
 package runner
 
 import (
-	"bytes"
+	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"io"
 	tdlog "log"
 	"os"
 	"reflect"
 	"regexp"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 	"unsafe"
 
 	"github.com/mercari/testdeck/constants"
@@ -41,9 +46,7 @@ var instance Runner
 var once sync.Once
 
 // Regex for matching test cases so that single test cases can be run
-// FIXME: This feature is currently not working (single test cases cannot be run by name)
 var reMatchTag = regexp.MustCompile("^(.*)\x00(.*)$")
-var reFilterTags = regexp.MustCompile("\\^.+\x00")
 var EnableMatchWorkaround = true
 
 // EventLogger will log test events
@@ -54,27 +57,192 @@ type EventLogger interface {
 // Contains the custom test runner and test run constants (output, logs, etc.)
 type runner struct {
 	m            TestRunner
-	deps         *TestDeps
+	deps         testDeps
 	output       string
 	stats        []constants.Statistics
 	eventLogger  EventLogger
 	matchRe      *regexp.Regexp
+	matcher      *Matcher // matchRe split by subtest level
 	matchPattern string
+	exactNames   []string // set by MatchNames
+	skipPattern  string
+	envPatterns  bool     // see SetAllowEnvPatterns
+	envRead      []string // the variables consulted for the Run
+	skipMatcher  *Matcher
+	skipIf       func(name string) (skip bool, reason string) // see SetSkipIf
+	runTimeout   time.Duration
+	parallel     int
+	cpuProfile   string
+	artifactDir  string     // see SetArtifactDir
+	artifacts    *Artifacts // of the running or last Run
+	cpuProfDur   time.Duration
+	coerceCorpus bool // see SetCorpusCoercion
+	testLogOut   io.Writer
+	checkpoint   io.ReadWriter // see SetCheckpoint
+	testLogFlush time.Duration // see SetTestLogFlushInterval
+	trackFiles   bool          // see SetTrackFileAccess
+	fileAccess   map[string][]constants.FileAccess
+	trackOpen    bool                // see SetTrackOpenFiles
+	openFiles    map[string][]string // of the running tests, by name
+	count        int
+	maxFailures  int
+	failFast     bool // see SetFailFast
+	onTestStart  func(name string)
+	onTestEnd    func(name string, outcome string, d time.Duration)
+	nameMapper   func(name string) string
+	failOnSkip   bool
+	failNoTests  bool // see SetFailOnNoTests
+	groupOutput  bool
+	verbose      bool
+	splitLog     bool // see SetSplitLogOutput
+	detectRaces  bool // see SetDetectRaces
+	failStderr   bool // see SetFailOnStderr
+	splitStderr  bool // see SetCombineOutput
+	stderrWatch  *stderrWatch
+	ctxValues    map[any]any
+	runID        string
+	runInfo      RunInfo
+	outputFilter func(test string, b []byte) []byte // see SetOutputFilter
+	maxOutput    int
+	maxFailLines int // see SetMaxFailureLines
+	strict       bool
+	minCoverage  float64
+	clock        clock
+	retries      int
+	retryBackoff time.Duration
+	retryFactor  float64
+	retryBudget  int // see SetRetryBudget
+	retry        int // the retry being run, 0 for the first attempt
+	onPkgOutput  func(b []byte)
+	testTimeout  time.Duration
+	parseResults bool // add statistics from the verbose output, for tests that don't use testdeck.Test
+	listPattern  string
+	listMatcher  *Matcher // set by SetList
+	listOutput   io.Writer
+	heapBackoff  HeapBackoff
+	webhookURL   string
+	webhookWait  time.Duration
+	out          io.Writer // see SetOutput
+	errOut       io.Writer
+	sortTests    string
+	sortPrior    []constants.Statistics
+	memSummary   bool
+	peakRoutines bool             // see SetTrackPeakGoroutines
+	goSampleTick time.Duration    // see SetGoroutineSampleInterval
+	memStart     runtime.MemStats // at the start of the Run
+	statsStart   int              // the index of the first statistics of the Run
+	testBudget   time.Duration
+	randSeed     int64
+	registered   []registeredTest // see RegisterWithWrapper
+	hooks        []testHook
+	hookScope    string                              // see SetHookScope
+	hookFinish   []func(ctx context.Context) []error // see finishHooks
+	variants     []Variant
+	variant      string // the name of the variant being run
+
+	mu       sync.Mutex // guards the counters below, which are updated by parallel tests
+	failures int
+	notRun   int
+	heldBack int             // of notRun, because the Run was cancelled
+	runErr   error           // see Err
+	runCtx   context.Context // see RunContext
+	warnings []string
 }
 
 // Interface for the custom test runner (contains Golang's Run() and some other custom methods that we need for recording statistics, etc.)
 type Runner interface {
 	Run()
+	RunContext(ctx context.Context) error
+	Err() error
 	AddStatistics(stats *constants.Statistics)
 	Statistics() []constants.Statistics
 	ClearStatistics()
+	SetMaxFailures(n int)
+	SetFailFast(yes bool)
+	Admit(name string) (ok bool, reason string)
+	NotRun() int
 	Match(pattern string) error
+	MatchNames(names []string) error
+	RunTestMain(run func() int) int
+	RepeatUntilFail(test string, maxRuns int, maxDuration time.Duration) (iterations int, failed bool, err error)
+	MatchFile(path string) error
+	Skip(pattern string) error
+	SetAllowEnvPatterns(yes bool)
+	EffectiveRunPattern() string
+	EffectiveSkipPattern() string
+	SkipFile(path string) error
+	SetSkipIf(fn func(name string) (skip bool, reason string))
+	ShouldRun(name string) (run bool, skipReason string)
+	RerunFailures(prev *Result) error
 	PrintToStdout(yes bool)
+	SetOutput(w io.Writer)
+	SetErrorOutput(w io.Writer)
+	SetGroupOutput(yes bool)
+	SetVerbose(yes bool)
+	SetSplitLogOutput(yes bool)
+	SetDetectRaces(yes bool)
+	SetList(pattern string) error
+	SetListOutput(w io.Writer)
+	SetContextValues(values map[any]any) error
+	SetVariants(variants []Variant) error
+	HookFor(pattern string, setup, teardown func(ctx context.Context, name string) error) error
+	SetHookScope(scope string) error
+	RegisterWithWrapper(name string, fn func(t *testing.T), wrap func(t *testing.T) any)
+	SetRunID(id string)
+	SetMaxTotalOutputBytes(n int)
+	SetOutputFilter(fn func(test string, b []byte) []byte)
+	SetMaxFailureLines(n int)
+	Validate() error
+	SetStrict(yes bool)
+	SetMinCoverage(percent float64)
+	SetPerTestBudget(d time.Duration)
+	SetRandSeed(seed int64)
+	SetMemSummary(yes bool)
+	SetTrackPeakGoroutines(yes bool)
+	SetGoroutineSampleInterval(d time.Duration)
+	SetRetries(n int)
+	SetRetryBackoff(d time.Duration, factor float64)
+	SetRetryBudget(n int)
+	SetOnPackageOutput(fn func(b []byte))
+	SetPerTestTimeout(d time.Duration)
+	SetHeapBackoff(b HeapBackoff)
+	SetSortTests(order string, prior []constants.Statistics) error
+	SetWebhook(url string, timeout time.Duration)
+	BackOffForHeap(name string) time.Duration
+	WatchTest(report func(d time.Duration, stacks string)) (stop func())
+	RunInfo() RunInfo
+	SetKeepFailedTempDirs(yes bool)
+	Warnings() []string
+	SetRunTimeout(d time.Duration)
+	SetParallel(n int)
+	SetCount(n int)
+	SetCPUProfile(path string)
+	SetArtifactDir(dir string)
+	SetCheckpoint(rw io.ReadWriter)
+	SetCPUProfileDuration(d time.Duration)
+	SetCorpusCoercion(yes bool)
+	SetTestLogWriter(w io.Writer)
+	SetTestLogFlushInterval(d time.Duration)
+	SetTrackFileAccess(yes bool)
+	SetTrackOpenFiles(yes bool)
 	PrintOutputToEventLog(yes bool)
 	SetEventLogger(e EventLogger)
+	SetOnTestStart(fn func(name string))
+	SetOnTestEnd(fn func(name string, outcome string, d time.Duration))
+	TestStarted(name string)
 	LogEvent(message string)
 	ReportStatistics()
+	SetNameMapper(fn func(name string) string)
+	GroupedStatistics() map[string][]constants.Statistics
+	Wire() WireConfig
+	FromWire(c WireConfig) error
 	Passed() bool
+	Result() *Result
+	SetFailOnSkip(yes bool)
+	SetFailOnNoTests(yes bool)
+	SetFailOnStderr(yes bool)
+	SetCombineOutput(yes bool)
+	Skipped() []constants.Statistics
 	Output() string
 }
 
@@ -90,7 +258,7 @@ type TestRunner interface {
 // This is pulled out so we can replace it for unit testing. The Go testing
 // package has too much assumed global state so we can't actually use the real
 // thing for unit tests.
-var runnerMainStart = func(deps *TestDeps, tests []testing.InternalTest) {
+var runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
 	// We need to instantiate our own "m" so we can feed it our implementation of
 	// testDeps. This allows us to control the running match pattern between Runs.
 	m2 := testing.MainStart(deps, tests, make([]testing.InternalBenchmark, 0), make([]testing.InternalFuzzTarget, 0), make([]testing.InternalExample, 0))
@@ -117,8 +285,10 @@ func Instance(m TestRunner) Runner {
 // make accessible for testing
 func newInstance(m TestRunner) Runner {
 	return &runner{
-		m:    m,
-		deps: &TestDeps{},
+		m:     m,
+		deps:  &TestDeps{},
+		count: -1,
+		clock: realClock{},
 	}
 }
 
@@ -129,61 +299,239 @@ func Initialized() bool {
 
 // Run starts the test runner
 func (r *runner) Run() {
+	r.mu.Lock()
+	r.failures = 0
+	r.notRun = 0
+	r.heldBack = 0
+	r.runErr = nil
+	r.warnings = nil
+	r.mu.Unlock()
+
+	defer r.postResult() // after finishRunInfo
+	r.startRunInfo()
+	defer r.writeArtifacts()
+	defer r.prepareArtifacts()()
+	defer r.finishRunInfo()
+	defer r.sampleGoroutines()() // stopped before finishRunInfo
+
+	restorePatterns, err := r.applyEnvPatterns()
+	defer restorePatterns()
+	if err != nil {
+		r.failRun("not running any tests: ", err)
+		r.addWarning(r.runInfo.Failure)
+		return
+	}
+	if err := r.cancelled(); err != nil {
+		r.failRun("not running any tests: ", &CancelledError{Err: err})
+		r.addWarning(r.runInfo.Failure)
+		return
+	}
+
+	if r.listMatcher != nil {
+		if err := r.listTests(); err != nil {
+			r.failRun("", &SetupError{Op: "listing tests", Err: err})
+			r.addWarning(r.runInfo.Failure)
+		}
+		return
+	}
+
+	if r.strict {
+		if err := r.Validate(); err != nil {
+			r.failRun("not running any tests: ", &SetupError{Op: "validating the tests", Err: err})
+			r.addWarning(r.runInfo.Failure)
+			return
+		}
+	}
+
+	if r.randSeed != 0 {
+		seedGlobalRand(r.randSeed)
+		r.runInfo.RandSeed = r.randSeed
+	}
+
+	if len(r.variants) > 0 {
+		r.runVariants()
+		return
+	}
+
+	first := len(r.stats)
+	r.output = r.runOnce(r.matcher, r.matchPattern)
+	r.retryFailures(first)
+}
+
+// runOnce runs the tests matching the pattern and returns the output. The
+// statistics added by the run get their share of the output.
+func (r *runner) runOnce(matcher *Matcher, matchPattern string) string {
+	tests := filterTestsWorkaround(matcher, r.sortedTests(r.allTests()), EnableMatchWorkaround, matchPattern)
+	tests = trackCurrentTests(r.selectTests(matcher, tests))
+	examples := r.selectExamples(matcher)
+	if len(tests) == 0 && len(examples) == 0 && r.retry == 0 {
+		r.noTestsSelected()
+	}
+	tests = r.resumeTests(tests)
+
+	first := len(r.stats)
+	output := r.captureOutput(func() {
+		if r.count == 0 {
+			return // like go test -count=0, run nothing
+		}
+		state := &runState{r: r}
+		defer state.cleanup()
+		state.add(r.setTestFlags())
+		state.add(r.startTestLog())
+		r.logEnvPatterns()
+		state.add(r.observeFileAccess())
+		state.add(r.finishHooks)
+		runnerMainStart(r.deps, tests)
+		r.runExamples(examples)
+		state.finished = true
+	})
+
+	if EnableMatchWorkaround {
+		output = strings.ReplaceAll(output, matchPattern+"\x00", "") // the tags of filterTestsWorkaround
+	}
+
+	for _, line := range attributeLines(output) {
+		if line.test == "" {
+			r.runInfo.PackageOutput += line.text
+		}
+	}
+
+	if r.parseResults {
+		r.addParsedResults(first, output)
+	}
+
+	r.recordRaces(first, output)
+	r.recordFailureLocations(first, output)
+
+	if r.splitLog {
+		split := splitOutputByTest(output)
+		for i := first; i < len(r.stats); i++ {
+			r.stats[i].LogOutput, r.stats[i].RawOutput = splitLogOutput(split.blocks[r.stats[i].Name])
+		}
+	}
 
-	// FIXME: Filtering tests to run by name is not working right now
-	tests := filterTestsWorkaround(r.matchRe, getInternalTests(r.m), EnableMatchWorkaround, r.matchPattern)
+	if r.groupOutput {
+		split := splitOutputByTest(output)
+		output = split.grouped()
+		for i := first; i < len(r.stats); i++ {
+			r.stats[i].Output = split.blocks[r.stats[i].Name]
+		}
+		r.capFailureOutput(first)
+		if printStdout {
+			fmt.Fprint(r.stdout(), output)
+		}
+		return output
+	}
 
+	// FIXME: Each test case is saving the entire test run's output. This should be fixed so that only the test case's output is saved.
+	for i := first; i < len(r.stats); i++ {
+		r.stats[i].Output = output
+	}
+	r.capFailureOutput(first)
+	return output
+}
+
+// captureOutput runs fn and returns everything it wrote to stdout. The output
+// is also copied to the real stdout and/or the event log if enabled.
+func (r *runner) captureOutput(fn func()) string {
 	// Create a tee to duplicate stdout writes to a buffer we can read later.
 	// idea from: https://stackoverflow.com/a/10476304
 	RealStdout := os.Stdout
+	out := r.stdout()
 	rp, wp, _ := os.Pipe()
-	outChannel := make(chan string)
+	src := io.Reader(rp)
+	if r.outputFilter != nil {
+		src = &filterReader{r: rp, fn: r.outputFilter}
+	}
+	// the settings are read here, as the reader may run after fn returns
+	maxOutput := r.maxOutput
+	stream := printStdout && !r.groupOutput // grouped output is printed after the run instead
+	eventLog := printOutputToEventLog
+	var pkgOutput *packageWriter
+	if r.onPkgOutput != nil {
+		pkgOutput = &packageWriter{fn: r.onPkgOutput}
+	}
+	outChannel := make(chan string, 1)
 	go func() {
-		var buf bytes.Buffer
-		if printStdout || printOutputToEventLog {
+		buf := cappedBuffer{max: maxOutput, onTruncate: func() {
+			r.addWarning(fmt.Sprintf("captured output reached %d bytes; the rest of the run's output was dropped", maxOutput))
+		}}
+		if stream || eventLog || pkgOutput != nil {
 			var writers []io.Writer
 
-			if printStdout {
-				writers = append(writers, RealStdout)
+			if stream {
+				writers = append(writers, out)
 			}
 
-			if printOutputToEventLog {
+			if eventLog {
 				writers = append(writers, NewEventWriter(r))
 			}
 
-			teeStdout := io.TeeReader(rp, io.MultiWriter(writers...))
+			if pkgOutput != nil {
+				writers = append(writers, pkgOutput)
+			}
+
+			teeStdout := io.TeeReader(src, io.MultiWriter(writers...))
 			_, err := io.Copy(&buf, teeStdout)
 			if err != nil {
-				tdlog.Println("testdeck output capture issue, io.Copy err:", err)
+				r.errorLog().Println("testdeck output capture issue, io.Copy err:", err)
 			}
 		} else {
-			_, err := io.Copy(&buf, rp)
+			_, err := io.Copy(&buf, src)
 			if err != nil {
-				tdlog.Println("testdeck output capture issue, io.Copy err:", err)
+				r.errorLog().Println("testdeck output capture issue, io.Copy err:", err)
 			}
 		}
+		if pkgOutput != nil {
+			pkgOutput.flush()
+		}
+		rp.Close()
 		outChannel <- buf.String()
 	}()
 
 	os.Stdout = wp
-
-	runnerMainStart(r.deps, tests)
-
-	wp.Close()             // close the pipe so the io.Copy gets EOF
-	os.Stdout = RealStdout // reset stdout
-
-	r.output = <-outChannel
-	rp.Close()
-
-	// FIXME: Running individual test cases by matching name is not working now
-	if EnableMatchWorkaround {
-		r.output = reFilterTags.ReplaceAllString(r.output, "")
+	realStderr := os.Stderr
+	stderr, stopStderrWatch := wp, func() {}
+	if r.watchesStderr() {
+		// on a pipe of its own, copied to wp unless kept split
+		var copyTo io.Writer = wp
+		if r.splitsStderr() {
+			copyTo = io.Discard
+			if printStdout && !r.groupOutput {
+				copyTo = out
+			}
+		}
+		if watched, stop := r.watchStderr(copyTo); watched != nil {
+			stderr, stopStderrWatch = watched, stop
+		}
 	}
-
-	// FIXME: Each test case is saving the entire test run's output. This should be fixed so that only the test case's output is saved.
-	for i, _ := range r.stats {
-		r.stats[i].Output = r.output
+	if r.splitLog || r.detectRaces || r.watchesStderr() {
+		os.Stderr = stderr // so direct writes to stderr are attributed too
 	}
+	restoreStderrFd := func() {}
+	if r.detectRaces || r.watchesStderr() {
+		// the race detector writes to the file descriptor, not os.Stderr
+		if restore, err := redirectStderrFd(stderr); err == nil {
+			restoreStderrFd = restore
+		}
+	}
+
+	var output string
+	func() {
+		// also if fn panics, so the process' output isn't left in the pipe
+		// and no reader outlives the Run
+		defer func() {
+			restoreStderrFd()      // before closing, as it holds the pipe open too
+			stopStderrWatch()      // before closing wp, which it copies to
+			wp.Close()             // close the pipe so the io.Copy gets EOF
+			os.Stdout = RealStdout // reset stdout
+			os.Stderr = realStderr
+			output = <-outChannel
+		}()
+		fn()
+	}()
+
+	return output
 }
 
 // -----
@@ -191,7 +539,42 @@ func (r *runner) Run() {
 // -----
 
 func (r *runner) AddStatistics(stats *constants.Statistics) {
+	leaveTest(goroutineID(), stats.Name)
+	if r.watchesStderr() {
+		stats.Stderr = r.stopStderr(stats.Name)
+		if r.failStderr && stats.Stderr != "" {
+			stats.Failed = true
+		}
+	}
+	r.mu.Lock()
+	if stats.RunID == "" {
+		stats.RunID = r.runInfo.RunID
+	}
+	stats.Retry = r.retry
+	if accesses, ok := r.fileAccess[stats.Name]; ok {
+		stats.FileAccesses = accesses
+		delete(r.fileAccess, stats.Name)
+	}
+	if open, ok := r.openFiles[stats.Name]; ok {
+		stats.OpenFiles = open
+		delete(r.openFiles, stats.Name)
+	}
+	// number repeated runs of the same test (e.g. with SetCount)
+	stats.Attempt = 1
+	for _, s := range r.stats {
+		if s.Name == stats.Name {
+			stats.Attempt++
+		}
+	}
 	r.stats = append(r.stats, *stats)
+	if stats.Failed {
+		r.failures++
+	}
+	r.mu.Unlock()
+
+	if r.onTestEnd != nil {
+		r.onTestEnd(r.reportName(r.variantName(stats.Name)), Outcome(*stats), stats.Duration)
+	}
 }
 
 func (r *runner) Statistics() []constants.Statistics {
@@ -204,18 +587,96 @@ func (r *runner) ClearStatistics() {
 }
 
 func (r *runner) ReportStatistics() {
+	w := r.stdout()
 	for i, s := range r.stats {
-		fmt.Println(i, s.Failed, s.Name)
+		fmt.Fprintln(w, i, s.Failed, r.reportName(s.Name))
+	}
+	if line, ok := coverageLine(r.runInfo); ok {
+		fmt.Fprintln(w, line)
+	}
+	if r.memSummary {
+		fmt.Fprintln(w, memLine(r.runInfo))
+	}
+}
+
+// SetNameMapper sets a func that rewrites test names for reporting, e.g. to
+// group tests by feature area. It only changes the names that are reported
+// (ReportStatistics, GroupedStatistics and the test callbacks); matching and
+// the saved statistics always use the real test name.
+func (r *runner) SetNameMapper(fn func(name string) string) {
+	r.nameMapper = fn
+}
+
+// GroupedStatistics returns the statistics grouped by their reported name
+func (r *runner) GroupedStatistics() map[string][]constants.Statistics {
+	groups := make(map[string][]constants.Statistics)
+	for _, s := range r.stats {
+		name := r.reportName(s.Name)
+		groups[name] = append(groups[name], s)
+	}
+	return groups
+}
+
+// reportName returns the name to report for a test
+func (r *runner) reportName(name string) string {
+	if r.nameMapper == nil {
+		return name
 	}
+	return r.nameMapper(name)
 }
 
+// Passed returns false if any test failed (in its last retry, see
+// SetRetries), if any test was skipped when
+// SetFailOnSkip is on, or if the last Run failed for another reason (see
+// RunInfo().Failure).
 func (r *runner) Passed() bool {
+	if r.runInfo.Failure != "" {
+		return false
+	}
+	if len(failedTests(r.stats)) > 0 {
+		return false
+	}
+	if r.failOnSkip && len(r.Skipped()) > 0 {
+		return false
+	}
+	return true
+}
+
+// SetFailOnSkip makes the run fail (see Passed) if any test was skipped
+func (r *runner) SetFailOnSkip(yes bool) {
+	r.failOnSkip = yes
+}
+
+// SetFailOnNoTests makes a Run fail (see Passed and RunInfo().Failure) if no
+// test or example is left to run once the run and skip patterns are applied,
+// e.g. after a typo in the pattern given to Match. Such a Run has
+// RunInfo().NoTestsRan set either way; go test only warns about it.
+func (r *runner) SetFailOnNoTests(yes bool) {
+	r.failNoTests = yes
+}
+
+// noTestsSelected records that a Run had no tests to run, see SetFailOnNoTests
+func (r *runner) noTestsSelected() {
+	r.runInfo.NoTestsRan = true
+	if !r.failNoTests || r.runInfo.Failure != "" {
+		return
+	}
+	r.runInfo.Failure = fmt.Sprintf("no tests to run: no test matches the run pattern %q", r.EffectiveRunPattern())
+	if skip := r.EffectiveSkipPattern(); skip != "" {
+		r.runInfo.Failure += fmt.Sprintf(" and not the skip pattern %q", skip)
+	}
+	r.addWarning(r.runInfo.Failure)
+}
+
+// Skipped returns the statistics of the skipped tests
+func (r *runner) Skipped() []constants.Statistics {
+	var skipped []constants.Statistics
 	for _, s := range r.stats {
-		if s.Failed {
-			return false
+		if Outcome(s) == constants.StatusSkip {
+			skipped = append(skipped, s)
 		}
 	}
-	return true
+	return skipped
 }
 
 func (r *runner) Output() string {
@@ -226,6 +687,223 @@ func (r *runner) PrintToStdout(yes bool) {
 	printStdout = yes
 }
 
+// SetOutput sets where the runner prints for humans: the test output (see
+// PrintToStdout), ReportStatistics and the names of SetList. Nil means
+// os.Stdout.
+func (r *runner) SetOutput(w io.Writer) {
+	r.out = w
+}
+
+// SetErrorOutput sets where the runner prints its own diagnostics, such as
+// problems capturing the output. Nil means os.Stderr.
+func (r *runner) SetErrorOutput(w io.Writer) {
+	r.errOut = w
+}
+
+// stdout returns the writer set by SetOutput
+func (r *runner) stdout() io.Writer {
+	if r.out == nil {
+		return os.Stdout
+	}
+	return r.out
+}
+
+// errorLog returns a logger writing to the writer set by SetErrorOutput
+func (r *runner) errorLog() *tdlog.Logger {
+	if r.errOut == nil {
+		return tdlog.Default()
+	}
+	return tdlog.New(r.errOut, "", tdlog.LstdFlags)
+}
+
+// SetRunTimeout sets the timeout for each Run, the same as go test -timeout.
+// Tests can read the resulting deadline via t.Deadline() or Context(t).
+// Zero keeps the timeout given on the command line.
+func (r *runner) SetRunTimeout(d time.Duration) {
+	r.runTimeout = d
+}
+
+// SetParallel sets the max number of tests run in parallel, the same as
+// go test -parallel. Zero keeps the value given on the command line, which
+// defaults to GOMAXPROCS (see WireConfig.ResolveParallel).
+func (r *runner) SetParallel(n int) {
+	r.parallel = n
+}
+
+// SetCount runs each test n times, the same as go test -count. Each run adds
+// its own Statistics entry, numbered by Attempt. Zero runs nothing (as with
+// go test) and a negative n keeps the value given on the command line. The
+// runner has no result cache, so there is nothing for count to disable.
+func (r *runner) SetCount(n int) {
+	r.count = n
+}
+
+// SetCPUProfile writes a CPU profile of each Run to path, the same as
+// go test -cpuprofile. Empty keeps the value given on the command line.
+func (r *runner) SetCPUProfile(path string) {
+	r.cpuProfile = path
+}
+
+// SetCPUProfileDuration stops the CPU profile (see SetCPUProfile) after d even
+// if the run continues, to cap the size of the profile of a long run. The
+// profile then only covers the first d of the run. 0 profiles the whole run.
+func (r *runner) SetCPUProfileDuration(d time.Duration) {
+	r.cpuProfDur = d
+	if deps, ok := r.deps.(*TestDeps); ok {
+		deps.cpuProfileDuration = d
+	}
+}
+
+// SetCorpusCoercion makes the corpus read for fuzz targets (TestDeps.ReadCorpus)
+// convert numeric values to the type of the target's argument when that is
+// lossless, e.g. an int(42) written for an int64 argument, instead of
+// rejecting the entry for its type. A value that doesn't fit (e.g. int(300)
+// for an int8) is still an error.
+func (r *runner) SetCorpusCoercion(yes bool) {
+	r.coerceCorpus = yes
+	if deps, ok := r.deps.(*TestDeps); ok {
+		deps.coerceCorpus = yes
+	}
+}
+
+// SetTestLogWriter makes a Run write the test log (the "# test log" of the
+// files and environment variables the tests used, see log.go) to w as well.
+// Under go test the log still goes to the -test.testlogfile too; otherwise
+// the runner starts the log for every Run itself. As package os only reports
+// to the real internal/testlog, the log has the ops reported with the Open,
+// Stat, Getenv, Chdir and Close funcs of this package. nil turns it off.
+func (r *runner) SetTestLogWriter(w io.Writer) {
+	r.testLogOut = w
+	if deps, ok := r.deps.(*TestDeps); ok {
+		deps.testLogOut = w
+	}
+}
+
+// SetTestLogFlushInterval makes the test log flush its buffer every d while
+// it is active, instead of only when it is stopped at the end of the run, so
+// the ops of a long test can be followed as they happen (e.g. with
+// SetTestLogWriter). 0 turns the periodic flush off.
+func (r *runner) SetTestLogFlushInterval(d time.Duration) {
+	r.testLogFlush = d
+	if deps, ok := r.deps.(*TestDeps); ok {
+		deps.testLogFlush = d
+	}
+}
+
+// startTestLog starts the test log for SetTestLogWriter if testing won't
+func (r *runner) startTestLog() (stop func()) {
+	if r.testLogOut == nil && !r.trackFiles && !r.trackOpen && r.artifacts == nil {
+		return func() {}
+	}
+	if f := flag.Lookup("test.testlogfile"); f != nil && f.Value.String() != "" {
+		return func() {} // testing starts it, with the writer added by TestDeps
+	}
+	r.deps.StartTestLog(io.Discard)
+	return func() {
+		if err := r.deps.StopTestLog(); err != nil {
+			r.addWarning(fmt.Sprintf("writing the test log: %v", err))
+		}
+	}
+}
+
+// SetGroupOutput buffers the output of a Run and prints it when the run
+// finishes with each test's output as one block (in the order the tests were
+// started) instead of interleaved as parallel tests produce it. Each test's
+// Statistics.Output then only holds its own block.
+func (r *runner) SetGroupOutput(yes bool) {
+	r.groupOutput = yes
+}
+
+// SetVerbose makes Run print the output of the tests in the verbose format of
+// go test -v, as the testing package does with -test.v: "=== RUN" when a test
+// or subtest starts, "=== PAUSE" and "=== CONT" around t.Parallel, and a
+// "--- PASS/FAIL/SKIP" line for every result, indented by subtest level. Off
+// keeps the command line -test.v.
+func (r *runner) SetVerbose(yes bool) {
+	r.verbose = yes
+}
+
+// SetSplitLogOutput makes a Run split each test's output into the lines it
+// logged with t.Log, t.Error etc. (Statistics.LogOutput) and the lines it
+// wrote to stdout or stderr directly, e.g. with fmt.Println
+// (Statistics.RawOutput), to find tests whose output go test can't attribute.
+// Stderr is captured along with stdout for this, so direct writes to stderr
+// are printed to the runner's output too. Like Statistics.Output, a direct
+// write from a parallel test may be attributed to another running test.
+func (r *runner) SetSplitLogOutput(yes bool) {
+	r.splitLog = yes
+}
+
+// SetMaxFailureLines keeps only the first n lines of the Statistics.Output
+// of a failed test, followed by a line saying how many were dropped, so that
+// reports of tests with many failed assertions stay readable. It applies
+// independently of SetMaxTotalOutputBytes, and the output printed while the
+// tests run is not affected. 0 is unlimited.
+func (r *runner) SetMaxFailureLines(n int) {
+	r.maxFailLines = n
+}
+
+// capFailureOutput applies SetMaxFailureLines to the statistics from first on
+func (r *runner) capFailureOutput(first int) {
+	if r.maxFailLines <= 0 {
+		return
+	}
+	for i := first; i < len(r.stats); i++ {
+		if r.stats[i].Failed {
+			r.stats[i].Output = firstLines(r.stats[i].Output, r.maxFailLines)
+		}
+	}
+}
+
+// firstLines returns the first n lines of s and a line saying how many more
+// were dropped, or s if it has at most n lines
+func firstLines(s string, n int) string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= n {
+		return s
+	}
+	kept := strings.Join(lines[:n], "")
+	if !strings.HasSuffix(kept, "\n") {
+		kept += "\n"
+	}
+	return kept + fmt.Sprintf("... %d more lines dropped\n", len(lines)-n)
+}
+
+// SetMaxTotalOutputBytes caps how much of a Run's output is captured (see
+// Output and Statistics.Output) to protect long-lived hosts from suites that
+// print a lot. Output past n bytes is dropped and a warning is recorded once
+// (see Warnings). Printing to stdout or the event log is not affected. 0 is
+// unlimited.
+func (r *runner) SetMaxTotalOutputBytes(n int) {
+	r.maxOutput = n
+}
+
+// SetOutputFilter makes a Run pass its output through fn as it is captured,
+// before it is printed, stored (see Output and Statistics.Output) or sent to
+// the event log, e.g. to mask secrets or strip volatile paths. fn gets the
+// output in chunks as it is read from the capture, split where the test it
+// belongs to (see attributeLines) changes, "" for package output. A chunk may
+// hold several writes or part of one: fn is not called per write or per line,
+// so a value split across two chunks is not seen whole. Like
+// SetContextValues, fn is kept in memory only and is not part of WireConfig.
+// nil turns filtering off.
+func (r *runner) SetOutputFilter(fn func(test string, b []byte) []byte) {
+	r.outputFilter = fn
+}
+
+// SetOnPackageOutput sets a func that is called with each line of output that
+// doesn't belong to a test (e.g. printed by TestMain setup or before the first
+// test starts, and the package summary lines) while a Run is in progress. The
+// lines are attributed as for SetGroupOutput. fn is called from the goroutine
+// capturing the output, so it must not print to stdout. The lines are also
+// recorded in RunInfo().PackageOutput.
+func (r *runner) SetOnPackageOutput(fn func(b []byte)) {
+	r.onPkgOutput = fn
+}
+
 func (r *runner) PrintOutputToEventLog(yes bool) {
 	printOutputToEventLog = yes
 }
@@ -234,15 +912,113 @@ func (r *runner) SetEventLogger(e EventLogger) {
 	r.eventLogger = e
 }
 
+// SetOnTestStart sets a func that is called synchronously when a test starts.
+// The name is passed through the name mapper (see SetNameMapper).
+// Parallel tests call it from their own goroutines, so calls may interleave.
+func (r *runner) SetOnTestStart(fn func(name string)) {
+	r.onTestStart = fn
+}
+
+// SetOnTestEnd sets a func that is called synchronously when a test's
+// statistics are added, with its outcome (constants.StatusPass, StatusFail or
+// StatusSkip) and duration. Like SetOnTestStart, calls may interleave.
+func (r *runner) SetOnTestEnd(fn func(name string, outcome string, d time.Duration)) {
+	r.onTestEnd = fn
+}
+
+// TestStarted is called by the test harness when a test starts running.
+func (r *runner) TestStarted(name string) {
+	r.startFileAccess(name)
+	r.startOpenFiles(name)
+	r.startStderr(name)
+	enterTest(name) // left in AddStatistics, on the same goroutine
+	if r.onTestStart != nil {
+		r.onTestStart(r.reportName(r.variantName(name)))
+	}
+}
+
 func (r *runner) LogEvent(message string) {
 	if r.eventLogger != nil {
 		r.eventLogger.Log(message)
 	}
 }
 
+// Warnings returns the problems recorded during the last Run that did not fail
+// it (e.g. a temp dir that could not be removed)
+func (r *runner) Warnings() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.warnings...)
+}
+
+func (r *runner) addWarning(message string) {
+	r.mu.Lock()
+	r.warnings = append(r.warnings, message)
+	r.mu.Unlock()
+	r.LogEvent("Warning: " + message)
+}
+
+// -----
+// TEST ADMISSION
+// -----
+
+// SetMaxFailures stops the run from starting new tests once n tests have
+// failed. Zero means no limit. Tests that are already running when the limit
+// is reached (e.g. parallel tests) still finish, so a run with parallel tests
+// may record more than n failures.
+func (r *runner) SetMaxFailures(n int) {
+	r.maxFailures = n
+}
+
+// SetFailFast stops the run from starting new tests after the first failure,
+// with the semantics of go test -failfast: parallel tests that already
+// started still run to the end and are reported, including the ones paused
+// in t.Parallel until the serial tests finish, as testdeck.Test admits a
+// test before it pauses; no serial or parallel test starts after the
+// failure. Only tests that use testdeck.Test are stopped. The testing
+// package's own -test.failfast isn't used, as it counts the failures of
+// every earlier Run in the process (e.g. of retries) too.
+func (r *runner) SetFailFast(yes bool) {
+	r.failFast = yes
+}
+
+// Admit reports whether the named test may start. If it may not, the test is
+// counted as not run and reason explains why; the caller should skip it.
+func (r *runner) Admit(name string) (ok bool, reason string) {
+	if r.matchesSkip(name) {
+		return false, "skipped: matches skip pattern"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxFailures > 0 && r.failures >= r.maxFailures {
+		r.notRun++
+		return false, fmt.Sprintf("not run: reached max failures (%d)", r.maxFailures)
+	}
+	if r.failFast && r.failures > 0 {
+		r.notRun++
+		return false, "not run: failfast after a failure"
+	}
+	if r.cancelled() != nil {
+		r.notRun++
+		r.heldBack++
+		return false, "not run: the Run was cancelled"
+	}
+	return true, ""
+}
+
+// NotRun returns how many tests Admit held back in the last Run: because the
+// max failures were reached (SetMaxFailures), because of failfast after a
+// failure (SetFailFast), or because the Run was cancelled (RunContext). A
+// non-zero value means the run is incomplete.
+func (r *runner) NotRun() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.notRun
+}
+
 // -----
 // TEST NAME MATCHING
-// FIXME: This feature is not working now, tests cannot be run individually by name
 // -----
 
 // MatchTag returns ok = true if the name has a test tag. If the tag is present,
@@ -260,28 +1036,276 @@ func MatchTag(name string) (ok bool, matched bool, actualName string) {
 		parts := reMatchTag.FindStringSubmatch(name)
 		tagPattern := parts[1]
 		actual := parts[2]
-		re := regexp.MustCompile(tagPattern)
-		return true, re.MatchString(actual), actual
+		m, err := tagMatcher(tagPattern)
+		if err != nil {
+			panic(err)
+		}
+		// a partial match still has to run so its matching subtests are reached
+		ok, _ := m.MatchFullName(actual)
+		return true, ok, actual
 	}
 
 	return false, false, name
 }
 
-// Match sets the regular expression pattern to filter tests to run.
+// untagName returns name without the tag added by filterTestsWorkaround
+func untagName(name string) string {
+	if i := strings.IndexByte(name, 0); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// tagMatchers caches the Matcher of the last tag pattern, since every tagged
+// test calls MatchTag with the same pattern
+var tagMatchers struct {
+	mu      sync.Mutex
+	pattern string
+	m       *Matcher
+}
+
+func tagMatcher(pattern string) (*Matcher, error) {
+	tagMatchers.mu.Lock()
+	defer tagMatchers.mu.Unlock()
+	if tagMatchers.m == nil || tagMatchers.pattern != pattern {
+		m, err := NewMatcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+		tagMatchers.pattern, tagMatchers.m = pattern, m
+	}
+	return tagMatchers.m, nil
+}
+
+// cacheTagMatcher makes MatchTag use m, already compiled from pattern
+func cacheTagMatcher(pattern string, m *Matcher) {
+	tagMatchers.mu.Lock()
+	tagMatchers.pattern, tagMatchers.m = pattern, m
+	tagMatchers.mu.Unlock()
+}
+
+// Match sets the regular expression pattern to filter tests to run. Like
+// go test -run, the pattern is split by "/" to match each level of subtests,
+// and an empty pattern runs all tests.
 func (r *runner) Match(pattern string) error {
-	re, err := regexp.Compile(pattern)
+	if pattern == "" {
+		pattern = ".*" // explicitly, instead of relying on "" matching any name
+	}
+	re, err := compileRegexp(pattern)
 	if err != nil {
-		return err
+		return &PatternError{Pattern: pattern, Err: err}
+	}
+	m, err := NewMatcher(pattern)
+	if err != nil {
+		return &PatternError{Pattern: pattern, Err: err}
 	}
+	r.setMatch(pattern, re, m)
+	return nil
+}
+
+func (r *runner) setMatch(pattern string, re *regexp.Regexp, m *Matcher) {
 	r.matchRe = re
+	r.matcher = m
 	r.matchPattern = pattern // for temporary workaround
+	r.exactNames = nil
+}
+
+// MatchNames selects exactly the named top-level tests (and their subtests),
+// taking the names literally instead of as regular expressions, e.g. for
+// names with "." or "(" in them. An empty list runs all tests.
+func (r *runner) MatchNames(names []string) error {
+	if len(names) == 0 {
+		return r.Match(".*")
+	}
+	if err := checkTopLevelNames(names); err != nil {
+		return err
+	}
+	if err := r.Match(namesPattern(names)); err != nil {
+		return err
+	}
+	r.exactNames = append([]string(nil), names...)
 	return nil
 }
 
+func checkTopLevelNames(names []string) error {
+	for _, name := range names {
+		if strings.Contains(name, "/") {
+			return fmt.Errorf("MatchNames selects top-level tests, %q is a subtest name", name)
+		}
+	}
+	return nil
+}
+
+// RerunFailures sets the match pattern so that only the tests that failed in
+// prev (e.g. the Result() of an earlier run) are run again, see OnlyFailures.
+func (r *runner) RerunFailures(prev *Result) error {
+	failed := OnlyFailures(prev)
+	if len(failed) == 0 {
+		return r.Match(namesPattern(nil)) // nothing to rerun
+	}
+	return r.MatchNames(failed)
+}
+
+// OnlyFailures returns the names of the top-level tests that failed in prev.
+// A failed subtest resolves to its top-level parent since only top-level tests
+// can be selected for a run. A retried test is judged by its last retry like
+// in Result.Failures, so a test that passed on a retry is not returned.
+func OnlyFailures(prev *Result) []string {
+	if prev == nil {
+		return nil
+	}
+	return failedTests(prev.Stats)
+}
+
+// namesPattern returns a pattern matching exactly the given top-level test
+// names (and their subtests). An empty list matches nothing.
+func namesPattern(names []string) string {
+	if len(names) == 0 {
+		return "^$"
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")(/|$)"
+}
+
+// MatchFile sets the pattern to filter tests to run from a file of patterns,
+// one per line. See patternsFromFile.
+func (r *runner) MatchFile(path string) error {
+	pattern, err := patternsFromFile(path)
+	if err != nil {
+		return err
+	}
+	if pattern == "" {
+		pattern = "^$" // an empty list selects nothing
+	}
+	return r.Match(pattern)
+}
+
+// Skip sets the regular expression pattern of tests not to run, like
+// go test -skip. An empty pattern skips nothing.
+func (r *runner) Skip(pattern string) error {
+	if pattern == "" {
+		r.skipPattern = ""
+		r.skipMatcher = nil
+		return nil
+	}
+	m, err := NewMatcher(pattern)
+	if err != nil {
+		return &PatternError{Pattern: pattern, Err: err}
+	}
+	r.skipPattern = pattern
+	r.skipMatcher = m
+	return nil
+}
+
+// EffectiveRunPattern returns the pattern a Run selects tests with, as the
+// runner applies it: after defaulting an empty pattern to ".*", joining the
+// lines of MatchFile, quoting the names of MatchNames and RerunFailures, and
+// rewriting each element like go test does (see Matcher.Pattern). It is ".*"
+// if no pattern was set.
+func (r *runner) EffectiveRunPattern() string {
+	if r.matcher == nil {
+		return ".*"
+	}
+	return r.matcher.Pattern()
+}
+
+// EffectiveSkipPattern returns the pattern a Run skips tests with, normalized
+// like EffectiveRunPattern, or "" if no test is skipped
+func (r *runner) EffectiveSkipPattern() string {
+	if r.skipMatcher == nil {
+		return ""
+	}
+	return r.skipMatcher.Pattern()
+}
+
+// SkipFile sets the pattern of tests not to run from a file of patterns, one
+// per line. See patternsFromFile.
+func (r *runner) SkipFile(path string) error {
+	pattern, err := patternsFromFile(path)
+	if err != nil {
+		return err
+	}
+	return r.Skip(pattern)
+}
+
+// patternsFromFile reads newline-separated patterns from a file and joins them
+// into a single alternation. Blank lines and lines starting with "#" are
+// ignored. The patterns are not grouped, so each line keeps its own
+// per-subtest "/" elements.
+func patternsFromFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(patterns, "|"), nil
+}
+
+// RepeatUntilFail runs only the named test (a full name, e.g. "TestA/sub")
+// over and over until an iteration fails or maxRuns iterations or maxDuration
+// have been reached, to reproduce a flaky test. Zero disables a limit but at
+// least one must be set. It returns the number of iterations run and whether
+// the last one failed; the statistics and Output() are those of the last
+// iteration. The previous match pattern (or names, see MatchNames) is
+// restored afterwards.
+func (r *runner) RepeatUntilFail(test string, maxRuns int, maxDuration time.Duration) (iterations int, failed bool, err error) {
+	if maxRuns <= 0 && maxDuration <= 0 {
+		return 0, false, fmt.Errorf("RepeatUntilFail needs maxRuns or maxDuration")
+	}
+
+	prevPattern, prevRe, prevMatcher, prevNames := r.matchPattern, r.matchRe, r.matcher, r.exactNames
+	defer func() {
+		r.setMatch(prevPattern, prevRe, prevMatcher)
+		r.exactNames = prevNames
+	}()
+	if err := r.Match(fullNamePattern(test)); err != nil {
+		return 0, false, err
+	}
+
+	start := r.clock.Now()
+	for maxRuns <= 0 || iterations < maxRuns {
+		if maxDuration > 0 && r.clock.Now().Sub(start) >= maxDuration {
+			break
+		}
+		r.ClearStatistics()
+		r.Run()
+		iterations++
+		if !r.Passed() {
+			return iterations, true, nil
+		}
+	}
+	return iterations, false, nil
+}
+
+// fullNamePattern returns a pattern matching exactly one test by its full
+// name, matching each slash-separated level literally.
+func fullNamePattern(name string) string {
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		elems[i] = "^" + regexp.QuoteMeta(elem) + "$"
+	}
+	return strings.Join(elems, "/")
+}
+
 // Temporary workaround to run individual test cases by name
-// FIXME: This is not working now, individual test cases cannot be run by name
-func filterTestsWorkaround(re *regexp.Regexp, tests []testing.InternalTest, matchWorkaround bool, rePattern string) []testing.InternalTest {
-	if matchWorkaround && rePattern != ".*" {
+func filterTestsWorkaround(m *Matcher, tests []testing.InternalTest, matchWorkaround bool, rePattern string) []testing.InternalTest {
+	if matchWorkaround && rePattern != ".*" && rePattern != "" {
 		var tagged []testing.InternalTest
 
 		for _, test := range tests {
@@ -289,19 +1313,36 @@ func filterTestsWorkaround(re *regexp.Regexp, tests []testing.InternalTest, matc
 			clone.Name = rePattern + "\x00" + test.Name
 			tagged = append(tagged, clone)
 		}
+		if m != nil {
+			cacheTagMatcher(rePattern, m) // so MatchTag doesn't compile it again
+		}
 
 		return tagged
 	}
 
-	return filterTests(re, tests)
+	return matchingTests(m, tests)
 }
 
-// FIXME: This is not working now, individual test cases cannot be run by name
+// filterTests returns the tests matching re, with the parents of matching
+// subtests
 func filterTests(re *regexp.Regexp, tests []testing.InternalTest) []testing.InternalTest {
-	var filtered []testing.InternalTest
+	// match per subtest level so the parents of matching subtests are kept
+	m, err := NewMatcher(re.String())
+	if err != nil {
+		panic(err)
+	}
+	return matchingTests(m, tests)
+}
+
+// matchingTests returns the tests that m selects, all of them if m is nil
+func matchingTests(m *Matcher, tests []testing.InternalTest) []testing.InternalTest {
+	if m == nil {
+		return tests
+	}
 
+	var filtered []testing.InternalTest
 	for _, test := range tests {
-		if re.MatchString(test.Name) {
+		if ok, _ := m.MatchFullName(test.Name); ok {
 			filtered = append(filtered, test)
 		}
 	}
@@ -1,8 +1,14 @@
 package runner
 
 import (
+	"flag"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mercari/testdeck/constants"
 	"github.com/pkg/errors"
@@ -143,7 +149,8 @@ func Test_FilterTest_ShouldFilterTests(t *testing.T) {
 func Test_FilterTestWorkaround_ShouldTagTestNames(t *testing.T) {
 	// Arrange
 	pattern := "^AAA$"
-	re := regexp.MustCompile(pattern)
+	m, err := NewMatcher(pattern)
+	require.NoError(t, err)
 	names := []string{
 		"A",
 		"AA",
@@ -164,7 +171,7 @@ func Test_FilterTestWorkaround_ShouldTagTestNames(t *testing.T) {
 	}
 
 	// Act
-	filtered := filterTestsWorkaround(re, internalTests, true, pattern)
+	filtered := filterTestsWorkaround(m, internalTests, true, pattern)
 
 	// Assert
 	assert.Equal(t, len(names), len(filtered))
@@ -201,6 +208,76 @@ func Test_MatchTag_ShouldNotMatchWithTaggedNameNotMatchingPattern(t *testing.T)
 	assert.Equal(t, actual, "AAA")
 }
 
+func Test_MatchTag_ShouldMatchParentOfMatchingSubtest(t *testing.T) {
+	cases := map[string]struct {
+		name        string
+		wantMatched bool
+	}{
+		"Parent":     {name: "TestParent/TestChild\x00TestParent", wantMatched: true},
+		"Child":      {name: "TestParent/TestChild\x00TestParent/TestChild", wantMatched: true},
+		"OtherChild": {name: "TestParent/TestChild\x00TestParent/TestOther", wantMatched: false},
+	}
+
+	for n, tc := range cases {
+		t.Run(n, func(t *testing.T) {
+			// Act
+			tagged, matched, _ := MatchTag(tc.name)
+
+			// Assert
+			assert.True(t, tagged)
+			assert.Equal(t, tc.wantMatched, matched)
+		})
+	}
+}
+
+func Test_FilterTest_ShouldKeepParentOfMatchingSubtest(t *testing.T) {
+	// Arrange
+	testFunc := func(t *testing.T) {}
+	internalTests := []testing.InternalTest{
+		{F: testFunc, Name: "TestParent"},
+		{F: testFunc, Name: "TestOther"},
+	}
+
+	// Act
+	filtered := filterTests(regexp.MustCompile("TestParent/TestChild"), internalTests)
+
+	// Assert
+	require.Equal(t, 1, len(filtered))
+	assert.Equal(t, "TestParent", filtered[0].Name)
+}
+
+func Test_Run_ShouldReachSubtestMatchingPattern(t *testing.T) {
+	// Arrange
+	var ran []string
+	run := func(t *testing.T) {
+		// skip like the test harness does
+		tagged, matched, name := MatchTag(t.Name())
+		if tagged && !matched {
+			t.Skip()
+		}
+		ran = append(ran, name)
+	}
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestParent", F: func(t *testing.T) {
+			run(t)
+			t.Run("TestChild", run)
+			t.Run("TestOther", run)
+		}},
+		{Name: "TestSibling", F: run},
+	}, nil, nil, nil))
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	require.NoError(t, r.Match("TestParent/TestChild"))
+	ok := runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.True(t, *ok)
+	assert.Equal(t, []string{"TestParent", "TestParent/TestChild"}, ran)
+}
+
 func Test_MatchTag_ShouldNotMatchWithUntaggedName(t *testing.T) {
 	// Arrange
 	name := "AAA/aaa"
@@ -214,6 +291,709 @@ func Test_MatchTag_ShouldNotMatchWithUntaggedName(t *testing.T) {
 	assert.Equal(t, name, actual)
 }
 
+func Test_OnlyFailures_ShouldReturnFailedTopLevelTests(t *testing.T) {
+	// Arrange
+	stats := []constants.Statistics{
+		{Name: "TestPass", Failed: false},
+		{Name: "TestFail", Failed: true},
+		{Name: "TestParent/sub_pass", Failed: false},
+		{Name: "TestParent/sub_fail", Failed: true},
+		{Name: "TestParent/other_fail", Failed: true},
+	}
+
+	// Act
+	got := OnlyFailures(&Result{Stats: stats})
+
+	// Assert
+	assert.Equal(t, []string{"TestFail", "TestParent"}, got)
+}
+
+func Test_OnlyFailures_ShouldJudgeRetriedTestsByTheirLastRetry(t *testing.T) {
+	// Arrange
+	prev := &Result{Stats: []constants.Statistics{
+		{Name: "TestFlaky", Failed: true},
+		{Name: "TestFlaky", Retry: 1, Flaky: true},
+		{Name: "TestBroken", Failed: true},
+		{Name: "TestBroken", Retry: 1, Failed: true},
+	}}
+
+	// Act
+	got := OnlyFailures(prev)
+
+	// Assert
+	assert.Equal(t, []string{"TestBroken"}, got)
+}
+
+func Test_RerunFailures_ShouldMatchOnlyFailedTests(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	prev := []constants.Statistics{
+		{Name: "A", Failed: false},
+		{Name: "AA", Failed: true},
+		{Name: "AAA", Failed: false},
+	}
+	testFunc := func(t *testing.T) {}
+	var internalTests []testing.InternalTest
+	for _, name := range []string{"A", "AA", "AAA"} {
+		internalTests = append(internalTests, testing.InternalTest{F: testFunc, Name: name})
+	}
+
+	// Act
+	err := r.RerunFailures(&Result{Stats: prev})
+
+	// Assert
+	require.NoError(t, err)
+	filtered := filterTests(r.matchRe, internalTests)
+	require.Equal(t, 1, len(filtered))
+	assert.Equal(t, "AA", filtered[0].Name)
+	assert.True(t, r.matchRe.MatchString("AA/subtest"))
+}
+
+func Test_RerunFailures_ShouldMatchNothingWithoutFailures(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+
+	// Act
+	err := r.RerunFailures(&Result{Stats: []constants.Statistics{{Name: "A"}}})
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, r.matchRe.MatchString("A"))
+}
+
+func Test_MatchNames_ShouldMatchNamesLiterally(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	testFunc := func(t *testing.T) {}
+	var internalTests []testing.InternalTest
+	for _, name := range []string{"Test(v1.2)", "TestXv1x2", "Test(v1.2)Extra", "TestOther"} {
+		internalTests = append(internalTests, testing.InternalTest{F: testFunc, Name: name})
+	}
+
+	// Act
+	err := r.MatchNames([]string{"Test(v1.2)"})
+
+	// Assert
+	require.NoError(t, err)
+	filtered := filterTests(r.matchRe, internalTests)
+	require.Equal(t, 1, len(filtered))
+	assert.Equal(t, "Test(v1.2)", filtered[0].Name)
+	_, matched, _ := MatchTag(r.matchPattern + "\x00Test(v1.2)/sub")
+	assert.True(t, matched)
+	assert.Equal(t, []string{"Test(v1.2)"}, r.Wire().ExactNames)
+}
+
+func Test_MatchNames_ShouldRejectSubtestNames(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+
+	// Act
+	err := r.MatchNames([]string{"TestA/sub"})
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func Test_MatchNames_ShouldBeClearedByMatch(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	require.NoError(t, r.MatchNames([]string{"TestA"}))
+
+	// Act
+	err := r.Match("TestB")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, r.Wire().ExactNames)
+}
+
+func Test_Admit_ShouldStopAfterMaxFailures(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	r.SetMaxFailures(2)
+	results := []bool{false, true, false, true, false, true}
+	ran := 0
+
+	// Act
+	for _, failed := range results {
+		if ok, _ := r.Admit("test"); !ok {
+			continue
+		}
+		ran++
+		r.AddStatistics(&constants.Statistics{Name: "test", Failed: failed})
+	}
+
+	// Assert
+	assert.Equal(t, 4, ran)
+	assert.Equal(t, 2, r.NotRun())
+	failures := 0
+	for _, s := range r.Statistics() {
+		if s.Failed {
+			failures++
+		}
+	}
+	assert.Equal(t, 2, failures)
+	ok, reason := r.Admit("test")
+	assert.False(t, ok)
+	assert.Equal(t, "not run: reached max failures (2)", reason)
+}
+
+func Test_Admit_ShouldAdmitAllWithoutMaxFailures(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+
+	// Act
+	for i := 0; i < 3; i++ {
+		r.AddStatistics(&constants.Statistics{Name: "test", Failed: true})
+	}
+	ok, _ := r.Admit("test")
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, 0, r.NotRun())
+}
+
+func Test_Admit_ShouldStopAfterFirstFailureWithFailFast(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	r.SetFailFast(true)
+	firstOK, _ := r.Admit("TestA")
+	r.AddStatistics(&constants.Statistics{Name: "TestA", Failed: true})
+
+	// Act
+	ok, reason := r.Admit("TestB")
+
+	// Assert
+	assert.True(t, firstOK)
+	assert.False(t, ok)
+	assert.Equal(t, "not run: failfast after a failure", reason)
+	assert.Equal(t, 1, r.NotRun())
+}
+
+func Test_SetFailFast_ShouldLetStartedParallelTestsFinish(t *testing.T) {
+	// Arrange
+	var r *runner
+	var started []string
+	// like testdeck.Test: admitted before t.Parallel, statistics at the end
+	test := func(name string, parallel bool, failed bool) testing.InternalTest {
+		return testing.InternalTest{Name: name, F: func(t *testing.T) {
+			if ok, reason := r.Admit(name); !ok {
+				t.Skip(reason)
+			}
+			if parallel {
+				t.Parallel() // paused until the serial tests are done
+			}
+			started = append(started, name)
+			r.AddStatistics(&constants.Statistics{Name: name, Failed: failed})
+		}}
+	}
+	r = newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		test("TestInFlight", true, false),
+		test("TestFails", false, true),
+		test("TestSerialAfter", false, false),
+		test("TestParallelAfter", true, false),
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetFailFast(true)
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, []string{"TestFails", "TestInFlight"}, started)
+	outcomes := make(map[string]string)
+	for _, s := range r.Statistics() {
+		outcomes[s.Name] = Outcome(s)
+	}
+	assert.Equal(t, map[string]string{"TestInFlight": constants.StatusPass, "TestFails": constants.StatusFail}, outcomes)
+	assert.Equal(t, 2, r.NotRun())
+}
+
+func Test_MatchFile_ShouldSelectListedTests(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "run.txt")
+	content := "# generated by CI\n^TestA$\n\n  ^TestC$  \n# ^TestB$\nTestParent/TestChild\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	testFunc := func(t *testing.T) {}
+	var internalTests []testing.InternalTest
+	for _, name := range []string{"TestA", "TestB", "TestC", "TestParent"} {
+		internalTests = append(internalTests, testing.InternalTest{F: testFunc, Name: name})
+	}
+
+	// Act
+	err := r.MatchFile(path)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "^TestA$|^TestC$|TestParent/TestChild", r.matchPattern)
+	var names []string
+	for _, test := range filterTests(r.matchRe, internalTests) {
+		names = append(names, test.Name)
+	}
+	assert.Equal(t, []string{"TestA", "TestC", "TestParent"}, names)
+}
+
+func Test_MatchFile_ShouldSelectNothingFromEmptyList(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "run.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# nothing to run\n"), 0644))
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+
+	// Act
+	err := r.MatchFile(path)
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, r.matchRe.MatchString("TestA"))
+}
+
+func Test_SetFailOnNoTests_ShouldSignalEmptySelection(t *testing.T) {
+	cases := map[string]struct {
+		pattern     string
+		failNoTests bool
+		wantNoTests bool
+		wantPassed  bool
+	}{
+		"OverNarrowPattern":    {pattern: "TestTypo", failNoTests: true, wantNoTests: true, wantPassed: false},
+		"OnlyFlaggedByDefault": {pattern: "TestTypo", failNoTests: false, wantNoTests: true, wantPassed: true},
+		"MatchingPattern":      {pattern: "TestA", failNoTests: true, wantNoTests: false, wantPassed: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			r := newInstance(newTestingM("TestA", "TestB")).(*runner)
+			require.NoError(t, r.Match(tc.pattern))
+			r.SetFailOnNoTests(tc.failNoTests)
+			defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+			runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {}
+
+			// Act
+			r.Run()
+
+			// Assert
+			assert.Equal(t, tc.wantNoTests, r.RunInfo().NoTestsRan)
+			assert.Equal(t, tc.wantNoTests, r.Result().NoTestsRan)
+			assert.Equal(t, tc.wantPassed, r.Passed())
+			if !tc.wantPassed {
+				assert.Equal(t, `no tests to run: no test matches the run pattern "TestTypo"`, r.RunInfo().Failure)
+			}
+		})
+	}
+}
+
+func Test_Runner_ShouldReturnEffectivePatterns(t *testing.T) {
+	cases := map[string]struct {
+		apply    func(r *runner) error
+		wantRun  string
+		wantSkip string
+	}{
+		"Defaults": {
+			apply:   func(r *runner) error { return nil },
+			wantRun: ".*",
+		},
+		"EmptyRunPattern": {
+			apply:   func(r *runner) error { return r.Match("") },
+			wantRun: ".*",
+		},
+		"SpacesRewritten": {
+			apply:   func(r *runner) error { return r.Match("TestParent/with space") },
+			wantRun: "TestParent/with_space",
+		},
+		"QuotedNames": {
+			apply:   func(r *runner) error { return r.MatchNames([]string{"TestA", "Test.B"}) },
+			wantRun: `^(TestA|Test\.B)(/|$)`,
+		},
+		"SkipPattern": {
+			apply:    func(r *runner) error { return r.Skip("TestSlow|TestFlaky/retry case") },
+			wantRun:  ".*",
+			wantSkip: "TestSlow|TestFlaky/retry_case",
+		},
+		"SkipCleared": {
+			apply: func(r *runner) error {
+				if err := r.Skip("TestSlow"); err != nil {
+					return err
+				}
+				return r.Skip("")
+			},
+			wantRun: ".*",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			bm := badM{}
+			r := newInstance(&bm).(*runner)
+			require.NoError(t, tc.apply(r))
+
+			// Act
+			run := r.EffectiveRunPattern()
+			skip := r.EffectiveSkipPattern()
+
+			// Assert
+			assert.Equal(t, tc.wantRun, run)
+			assert.Equal(t, tc.wantSkip, skip)
+		})
+	}
+}
+
+func Test_Runner_ShouldReturnEffectivePatternOfMatchFile(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "run.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# generated by CI\n^TestA$\n  Test B  \n"), 0644))
+	bm := badM{}
+	r := newInstance(&bm)
+	require.NoError(t, r.MatchFile(path))
+
+	// Act
+	pattern := r.EffectiveRunPattern()
+
+	// Assert
+	assert.Equal(t, "^TestA$|Test_B", pattern)
+}
+
+func Test_MatchFile_ShouldFailForMissingFile(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+
+	// Act
+	err := r.MatchFile(filepath.Join(t.TempDir(), "missing.txt"))
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func Test_SkipFile_ShouldSkipListedTests(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "skip.txt")
+	require.NoError(t, os.WriteFile(path, []byte("^TestB$\nTestParent/TestChild\n"), 0644))
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	testFunc := func(t *testing.T) {}
+	var internalTests []testing.InternalTest
+	for _, name := range []string{"TestA", "TestB", "TestParent"} {
+		internalTests = append(internalTests, testing.InternalTest{F: testFunc, Name: name})
+	}
+
+	// Act
+	err := r.SkipFile(path)
+
+	// Assert
+	require.NoError(t, err)
+	var names []string
+	for _, test := range r.selectTests(nil, internalTests) {
+		names = append(names, test.Name)
+	}
+	assert.Equal(t, []string{"TestA", "TestParent"}, names)
+	ok, _ := r.Admit("TestParent/TestChild")
+	assert.False(t, ok)
+	ok, _ = r.Admit("TestParent/TestOther")
+	assert.True(t, ok)
+	assert.Equal(t, 0, r.NotRun())
+}
+
+func Test_Skip_ShouldSkipNothingWithEmptyPattern(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA", "TestB")).(*runner)
+	require.NoError(t, r.Skip("TestA"))
+	order := recordOrder(t)
+
+	// Act
+	err := r.Skip("")
+	r.Run()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TestA", "TestB"}, *order)
+	ok, _ := r.Admit("TestA")
+	assert.True(t, ok)
+}
+
+func Test_Match_ShouldRunAllTestsWithEmptyPattern(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA", "TestB")).(*runner)
+	var given []string
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for _, test := range tests {
+			given = append(given, test.Name)
+		}
+	}
+
+	// Act
+	err := r.Match("")
+	r.Run()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, ".*", r.Wire().MatchPattern)
+	assert.Equal(t, []string{"TestA", "TestB"}, given) // not tagged for the match workaround
+}
+
+func Test_Runner_ShouldCallTestCallbacks(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	var started []string
+	ended := make(map[string]string)
+	endCalls := 0
+	r.SetOnTestStart(func(name string) {
+		started = append(started, name)
+	})
+	r.SetOnTestEnd(func(name string, outcome string, d time.Duration) {
+		endCalls++
+		ended[name] = outcome
+		assert.Equal(t, time.Second, d)
+	})
+	stats := []constants.Statistics{
+		{Name: "TestPass", Duration: time.Second},
+		{Name: "TestFail", Failed: true, Duration: time.Second},
+		{Name: "TestSkip", Statuses: []constants.Status{{Status: constants.StatusSkip}}, Duration: time.Second},
+	}
+
+	// Act
+	for i := range stats {
+		r.TestStarted(stats[i].Name)
+		r.AddStatistics(&stats[i])
+	}
+
+	// Assert
+	assert.Equal(t, []string{"TestPass", "TestFail", "TestSkip"}, started)
+	assert.Equal(t, 3, endCalls)
+	assert.Equal(t, map[string]string{
+		"TestPass": constants.StatusPass,
+		"TestFail": constants.StatusFail,
+		"TestSkip": constants.StatusSkip,
+	}, ended)
+}
+
+func Test_NameMapper_ShouldOnlyMapReportedNames(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	r.SetNameMapper(func(name string) string {
+		return strings.SplitN(name, "_", 2)[0]
+	})
+	var ended []string
+	r.SetOnTestEnd(func(name string, outcome string, d time.Duration) {
+		ended = append(ended, name)
+	})
+	require.NoError(t, r.Match("^Payment_Refund$"))
+	testFunc := func(t *testing.T) {}
+	internalTests := []testing.InternalTest{
+		{F: testFunc, Name: "Payment_Refund"},
+		{F: testFunc, Name: "Payment_Charge"},
+	}
+
+	// Act
+	filtered := filterTests(r.matchRe, internalTests)
+	r.AddStatistics(&constants.Statistics{Name: "Payment_Refund"})
+	r.AddStatistics(&constants.Statistics{Name: "Payment_Charge", Failed: true})
+	r.AddStatistics(&constants.Statistics{Name: "User_Login"})
+	groups := r.GroupedStatistics()
+
+	// Assert
+	require.Equal(t, 1, len(filtered))
+	assert.Equal(t, "Payment_Refund", filtered[0].Name)
+	assert.Equal(t, []string{"Payment", "Payment", "User"}, ended)
+	require.Equal(t, 2, len(groups))
+	require.Equal(t, 2, len(groups["Payment"]))
+	assert.Equal(t, "Payment_Refund", groups["Payment"][0].Name)
+	assert.Equal(t, "Payment_Charge", groups["Payment"][1].Name)
+	assert.Equal(t, "User_Login", groups["User"][0].Name)
+	assert.Equal(t, "Payment_Refund", r.Statistics()[0].Name)
+}
+
+// newFakeTestingM returns a real *testing.M (so getInternalTests works) for use
+// with a replaced runnerMainStart.
+func newFakeTestingM() *testing.M {
+	return testing.MainStart(&TestDeps{}, make([]testing.InternalTest, 0), make([]testing.InternalBenchmark, 0), make([]testing.InternalFuzzTarget, 0), make([]testing.InternalExample, 0))
+}
+
+func Test_RepeatUntilFail_ShouldStopAtFirstFailure(t *testing.T) {
+	// Arrange
+	bm := newFakeTestingM()
+	r := newInstance(bm).(*runner)
+	require.NoError(t, r.Match("TestOther"))
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runs := 0
+	var patterns []string
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		runs++
+		patterns = append(patterns, r.matchPattern)
+		r.AddStatistics(&constants.Statistics{Name: "TestFlaky/sub.1", Failed: runs == 3})
+	}
+
+	// Act
+	iterations, failed, err := r.RepeatUntilFail("TestFlaky/sub.1", 10, 0)
+
+	// Assert
+	require.NoError(t, err)
+	assert.True(t, failed)
+	assert.Equal(t, 3, iterations)
+	assert.Equal(t, 3, runs)
+	assert.Equal(t, "^TestFlaky$/^sub\\.1$", patterns[0])
+	require.Equal(t, 1, len(r.Statistics()))
+	assert.True(t, r.Statistics()[0].Failed)
+	assert.Equal(t, "TestOther", r.matchPattern)
+}
+
+func Test_RepeatUntilFail_ShouldStopAtMaxRuns(t *testing.T) {
+	// Arrange
+	bm := newFakeTestingM()
+	r := newInstance(bm)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		r.AddStatistics(&constants.Statistics{Name: "TestStable"})
+	}
+
+	// Act
+	iterations, failed, err := r.RepeatUntilFail("TestStable", 5, 0)
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, failed)
+	assert.Equal(t, 5, iterations)
+}
+
+func Test_RepeatUntilFail_ShouldStopAtMaxDurationOfClock(t *testing.T) {
+	// Arrange
+	bm := newFakeTestingM()
+	r := newInstance(bm).(*runner)
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r.setClock(clock)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		clock.Sleep(time.Second)
+		r.AddStatistics(&constants.Statistics{Name: "TestStable"})
+	}
+
+	// Act
+	iterations, failed, err := r.RepeatUntilFail("TestStable", 0, 3*time.Second)
+
+	// Assert
+	require.NoError(t, err)
+	assert.False(t, failed)
+	assert.Equal(t, 3, iterations)
+}
+
+func Test_RepeatUntilFail_ShouldRestoreMatchedNames(t *testing.T) {
+	// Arrange
+	bm := newFakeTestingM()
+	r := newInstance(bm).(*runner)
+	require.NoError(t, r.MatchNames([]string{"TestA(1)", "TestB"}))
+	prevPattern := r.matchPattern
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		r.AddStatistics(&constants.Statistics{Name: "TestStable"})
+	}
+
+	// Act
+	_, _, err := r.RepeatUntilFail("TestStable", 2, 0)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, prevPattern, r.matchPattern)
+	assert.Equal(t, []string{"TestA(1)", "TestB"}, r.exactNames)
+}
+
+func Test_RepeatUntilFail_ShouldRequireALimit(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+
+	// Act
+	_, _, err := r.RepeatUntilFail("TestStable", 0, 0)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func Test_FailOnSkip_ShouldFailRunWithSkippedTest(t *testing.T) {
+	cases := map[string]struct {
+		failOnSkip bool
+		wantPassed bool
+	}{
+		"FailOnSkip": {failOnSkip: true, wantPassed: false},
+		"Default":    {failOnSkip: false, wantPassed: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			bm := badM{}
+			r := newInstance(&bm)
+			r.SetFailOnSkip(tc.failOnSkip)
+
+			// Act
+			r.AddStatistics(&constants.Statistics{Name: "TestPass", Statuses: []constants.Status{{Status: constants.StatusPass}}})
+			r.AddStatistics(&constants.Statistics{Name: "TestSkip", Statuses: []constants.Status{{Status: constants.StatusSkip}}})
+
+			// Assert
+			assert.Equal(t, tc.wantPassed, r.Passed())
+			skipped := r.Skipped()
+			require.Equal(t, 1, len(skipped))
+			assert.Equal(t, "TestSkip", skipped[0].Name)
+		})
+	}
+}
+
+func Test_SetCount_ShouldRunTestsNTimes(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM())
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		// emulate testing's -test.count loop
+		count, err := strconv.Atoi(flag.Lookup("test.count").Value.String())
+		require.NoError(t, err)
+		for i := 0; i < count; i++ {
+			r.AddStatistics(&constants.Statistics{Name: "TestA", Failed: i == 1})
+		}
+	}
+	r.SetCount(3)
+
+	// Act
+	r.Run()
+
+	// Assert
+	stats := r.Statistics()
+	require.Equal(t, 3, len(stats))
+	for i, s := range stats {
+		assert.Equal(t, i+1, s.Attempt)
+		assert.Equal(t, i == 1, s.Failed)
+	}
+}
+
+func Test_SetCount_ShouldRunNothingForZero(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM())
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runs := 0
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		runs++
+	}
+	r.SetCount(0)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, 0, runs)
+}
+
 type FakeM struct {
 	t         *testing.T
 	deps      *TestDeps
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+select.go: Which tests a Run runs. All the filters are applied by selectTest, for the tests of a Run as well as for
+listing them (SetList) and for ShouldRun, so what is listed can't drift from what runs.
+*/
+
+// selection is the verdict of selectTest on a test
+type selection int
+
+const (
+	selected           selection = iota
+	notMatched                   // by the run pattern; left out of the run
+	skippedByPattern             // fully matched by the skip pattern; left out of the run
+	skippedByPredicate           // by SetSkipIf; run only to be reported as skipped
+)
+
+// ShouldRun reports whether a Run runs the test name (a full name such as
+// "TestParent/TestChild"), and if not why. The filters apply in this order,
+// the first one that excludes the test deciding:
+//
+//  1. The run pattern (see Match, MatchNames, MatchFile and RerunFailures).
+//     A test it doesn't match is not run; a test whose name only matches the
+//     leading elements of the pattern runs to reach its matching subtests.
+//  2. The skip pattern (see Skip and SkipFile), if it fully matches the name.
+//  3. The SetSkipIf predicate, for top-level tests. The test is reported as
+//     skipped with the predicate's reason, while a test excluded by 1 or 2 is
+//     left out of the results.
+//
+// Subtests are only known once their parent runs, and the limit of
+// SetMaxFailures applies while the Run runs (see Admit), so they aren't part
+// of ShouldRun.
+func (r *runner) ShouldRun(name string) (run bool, skipReason string) {
+	sel, reason := r.selectTest(r.matcher, name)
+	return sel == selected, reason
+}
+
+// selectTest applies the filters of ShouldRun with the run pattern matcher
+// (nil selects all tests)
+func (r *runner) selectTest(matcher *Matcher, name string) (selection, string) {
+	if matcher != nil {
+		if ok, _ := matcher.MatchFullName(name); !ok {
+			return notMatched, "not matched by the run pattern"
+		}
+	}
+	if r.matchesSkip(name) {
+		return skippedByPattern, "skipped: matches skip pattern"
+	}
+	if r.skipIf != nil && !strings.Contains(name, "/") {
+		if skip, reason := r.skipIf(name); skip {
+			return skippedByPredicate, reason
+		}
+	}
+	return selected, ""
+}
+
+// matchesSkip reports whether the skip pattern fully matches name
+func (r *runner) matchesSkip(name string) bool {
+	if r.skipMatcher == nil {
+		return false
+	}
+	skip, partial := r.skipMatcher.MatchFullName(name)
+	return skip && !partial
+}
+
+// selectTests returns the tests selectTest selects with matcher, with the
+// hooks of HookFor around them, and the tests SetSkipIf skips replaced by
+// ones that report the skip
+func (r *runner) selectTests(matcher *Matcher, tests []testing.InternalTest) []testing.InternalTest {
+	var kept []testing.InternalTest
+	skipReasons := make(map[int]string)
+	for _, test := range tests {
+		// the match workaround tags names, so select by the actual name
+		_, _, name := MatchTag(test.Name)
+		switch sel, reason := r.selectTest(matcher, name); sel {
+		case notMatched, skippedByPattern:
+			continue
+		case skippedByPredicate:
+			skipReasons[len(kept)] = reason
+		}
+		kept = append(kept, test)
+	}
+
+	kept = r.hookTests(kept)
+	for i, reason := range skipReasons {
+		_, _, name := MatchTag(kept[i].Name)
+		kept[i].F = r.skipTest(name, reason) // without the hooks
+	}
+	return kept
+}
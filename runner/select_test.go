@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ShouldRun_ShouldApplyFiltersInPrecedence(t *testing.T) {
+	skipDB := func(name string) (bool, string) {
+		return strings.HasPrefix(name, "TestDB"), "no database configured"
+	}
+	cases := map[string]struct {
+		run        string
+		skip       string
+		skipIf     func(name string) (bool, string)
+		name       string
+		wantRun    bool
+		wantReason string
+	}{
+		"NoFilters": {name: "TestA", wantRun: true},
+		"NotMatchedByRun": {
+			run: "^TestB$", name: "TestA",
+			wantReason: "not matched by the run pattern",
+		},
+		"PartialRunMatch": {run: "TestParent/TestChild", name: "TestParent", wantRun: true},
+		"MatchedByRunButSkipped": {
+			run: "^TestDB", skip: "Users$", name: "TestDBUsers",
+			wantReason: "skipped: matches skip pattern",
+		},
+		"RunPatternBeforeSkip": {
+			run: "^TestB$", skip: "TestA", name: "TestA",
+			wantReason: "not matched by the run pattern",
+		},
+		"SkipPatternBeforePredicate": {
+			skip: "TestDBUsers", skipIf: skipDB, name: "TestDBUsers",
+			wantReason: "skipped: matches skip pattern",
+		},
+		"SkippedByPredicate": {
+			run: "^TestDB", skipIf: skipDB, name: "TestDBUsers",
+			wantReason: "no database configured",
+		},
+		"RunPatternBeforePredicate": {
+			run: "^TestA$", skipIf: skipDB, name: "TestDBUsers",
+			wantReason: "not matched by the run pattern",
+		},
+		"PredicateOnlyForTopLevel": {skipIf: skipDB, name: "TestOther/TestDBChild", wantRun: true},
+		"PartialSkipMatchRuns":     {skip: "TestParent/TestChild", name: "TestParent", wantRun: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			r := newInstance(newTestingM()).(*runner)
+			if tc.run != "" {
+				require.NoError(t, r.Match(tc.run))
+			}
+			require.NoError(t, r.Skip(tc.skip))
+			r.SetSkipIf(tc.skipIf)
+
+			// Act
+			run, reason := r.ShouldRun(tc.name)
+
+			// Assert
+			assert.Equal(t, tc.wantRun, run)
+			assert.Equal(t, tc.wantReason, reason)
+		})
+	}
+}
+
+func Test_List_ShouldListWhatShouldRun(t *testing.T) {
+	// Arrange
+	names := []string{"TestDBUsers", "TestDBOrders", "TestLogin", "TestSlow"}
+	r := newInstance(newTestingM(names...)).(*runner)
+	require.NoError(t, r.Match("^Test(DB|Slow)"))
+	require.NoError(t, r.Skip("TestSlow"))
+	r.SetSkipIf(func(name string) (bool, string) { return name == "TestDBOrders", "orders disabled" })
+	require.NoError(t, r.SetList(".*"))
+	var buf bytes.Buffer
+	r.SetListOutput(&buf)
+
+	// Act
+	r.Run()
+
+	// Assert
+	var want strings.Builder
+	for _, name := range names {
+		if run, _ := r.ShouldRun(name); run {
+			want.WriteString(name + "\n")
+		}
+	}
+	assert.Equal(t, "TestDBUsers\n", buf.String())
+	assert.Equal(t, want.String(), buf.String())
+}
+
+func Test_SelectTests_ShouldMatchShouldRun(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM()).(*runner)
+	require.NoError(t, r.Match("^Test(DB|Slow)"))
+	require.NoError(t, r.Skip("TestSlow"))
+	r.SetSkipIf(func(name string) (bool, string) { return name == "TestDBOrders", "orders disabled" })
+	var tests []testing.InternalTest
+	for _, name := range []string{"TestDBUsers", "TestDBOrders", "TestLogin", "TestSlow"} {
+		tests = append(tests, testing.InternalTest{Name: name, F: func(t *testing.T) {}})
+	}
+
+	// Act
+	selected := r.selectTests(r.matcher, tests)
+
+	// Assert
+	var names []string
+	for _, test := range selected {
+		names = append(names, test.Name)
+	}
+	// the skipped test is kept to report its skip
+	assert.Equal(t, []string{"TestDBUsers", "TestDBOrders"}, names)
+}
@@ -0,0 +1,39 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+skipif.go: Skipping top-level tests by a predicate, e.g. on the environment, instead of calling t.Skip in each test
+*/
+
+// SetSkipIf makes Run ask fn before each top-level test whether to skip it.
+// A skipped test doesn't run at all (nor do the hooks of HookFor): it is
+// skipped with reason like t.Skip, and its Statistics has the reason as
+// SkipReason. Unlike Skip, which leaves the tests out of the run, the skipped
+// tests are reported. Like SetContextValues, fn is kept in memory only and is
+// not part of WireConfig. nil turns it off.
+func (r *runner) SetSkipIf(fn func(name string) (skip bool, reason string)) {
+	r.skipIf = fn
+}
+
+// skipTest returns a test that records its skip and skips with reason
+func (r *runner) skipTest(name, reason string) func(t *testing.T) {
+	return func(t *testing.T) {
+		// parsed from the output instead, see parseTestOutput
+		if !r.parseResults {
+			now := r.clock.Now()
+			r.AddStatistics(&constants.Statistics{
+				Name:       name,
+				Statuses:   []constants.Status{{Status: constants.StatusSkip, Lifecycle: constants.LifecycleTestSetup}},
+				Start:      now,
+				End:        now,
+				SkipReason: reason,
+			})
+		}
+		t.Skip(reason)
+	}
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SetSkipIf_ShouldSkipMatchingTestsWithReason(t *testing.T) {
+	// Arrange
+	var ran []string
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestDBUsers", F: func(t *testing.T) { ran = append(ran, "TestDBUsers") }},
+		{Name: "TestParse", F: func(t *testing.T) { ran = append(ran, "TestParse") }},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetSkipIf(func(name string) (bool, string) {
+		return strings.HasPrefix(name, "TestDB"), "no database configured"
+	})
+	ok := runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.True(t, *ok)
+	assert.Equal(t, []string{"TestParse"}, ran)
+	skipped := r.Skipped()
+	require.Len(t, skipped, 1)
+	assert.Equal(t, "TestDBUsers", skipped[0].Name)
+	assert.Equal(t, constants.StatusSkip, Outcome(skipped[0]))
+	assert.Equal(t, "no database configured", skipped[0].SkipReason)
+}
+
+func Test_SetSkipIf_ShouldNotRunHooksOfSkippedTests(t *testing.T) {
+	// Arrange
+	r := newInstance(testing.MainStart(&TestDeps{}, []testing.InternalTest{
+		{Name: "TestDB", F: func(t *testing.T) {}},
+	}, nil, nil, nil)).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	setups := 0
+	require.NoError(t, r.HookFor("TestDB", func(ctx context.Context, name string) error {
+		setups++
+		return nil
+	}, nil))
+	r.SetSkipIf(func(name string) (bool, string) { return true, "skipped" })
+	runIsolated(t)
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, 0, setups)
+}
@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+stacks.go: A per-test watchdog that reports the stacks of a test that runs longer than SetPerTestTimeout.
+
+A hung goroutine can't be stopped, so the watchdog only reports it. To report
+only the test's own goroutines, the ID of the test's goroutine is recorded when
+the test starts. Since Go 1.21 the stack of every goroutine ends with
+"created by F in goroutine N", so the goroutines started within the test are
+the ones whose chain of creators leads back to that ID. No other bookkeeping is
+needed. Older toolchains print only "created by F", so with them just the
+stack of the test's own goroutine is reported.
+*/
+
+var reGoroutineHeader = regexp.MustCompile(`^goroutine (\d+) `)
+var reCreatedBy = regexp.MustCompile(`(?m)^created by .* in goroutine (\d+)$`)
+
+// SetPerTestTimeout makes tests using testdeck.Test fail if they don't finish
+// within d, with the stacks of their goroutines in the failure message and in
+// Statistics.TimeoutStacks. The test is not stopped: its statistics are only
+// added if it finishes eventually, and then the Run fails with a
+// *TimeoutError (see Err). The run's timeout (see SetRunTimeout) still
+// applies. 0 turns the watchdog off.
+func (r *runner) SetPerTestTimeout(d time.Duration) {
+	r.testTimeout = d
+}
+
+// WatchTest is called by the test harness from a test's goroutine when the
+// test starts. If the test doesn't call stop within the per-test timeout,
+// report is called with the stacks of the test's goroutines. stop waits for a
+// report that is in progress.
+func (r *runner) WatchTest(report func(d time.Duration, stacks string)) (stop func()) {
+	d := r.testTimeout
+	if d <= 0 {
+		return func() {}
+	}
+
+	root := goroutineID()
+	var mu sync.Mutex
+	stopped := false
+	stopTimer := r.clock.AfterFunc(d, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			report(d, goroutineStacks(root))
+		}
+	})
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		stopTimer()
+	}
+}
+
+// goroutineID returns the ID of the calling goroutine
+func goroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	parts := reGoroutineHeader.FindSubmatch(buf)
+	if parts == nil {
+		return 0
+	}
+	id, _ := strconv.ParseInt(string(parts[1]), 10, 64)
+	return id
+}
+
+// goroutineStacks returns the stacks of the goroutine root and of all the
+// goroutines it started, directly or indirectly, or only root's if the stacks
+// don't name their creators (before Go 1.21)
+func goroutineStacks(root int64) string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	return selectStacks(string(buf), root)
+}
+
+// selectStacks returns the stacks of root and its descendants from dump, the
+// output of runtime.Stack for all goroutines
+func selectStacks(dump string, root int64) string {
+	stacks := make(map[int64]string)
+	parents := make(map[int64]int64)
+	var order []int64
+	for _, stack := range strings.Split(strings.TrimSpace(dump), "\n\n") {
+		header := reGoroutineHeader.FindStringSubmatch(stack)
+		if header == nil {
+			continue
+		}
+		id, _ := strconv.ParseInt(header[1], 10, 64)
+		stacks[id] = stack
+		order = append(order, id)
+		if created := reCreatedBy.FindStringSubmatch(stack); created != nil {
+			parents[id], _ = strconv.ParseInt(created[1], 10, 64)
+		}
+	}
+
+	if len(parents) == 0 {
+		stack, ok := stacks[root]
+		if !ok {
+			return fmt.Sprintf("goroutine %d has exited", root)
+		}
+		return stack + "\n\n(the goroutines it started are not included: this Go version doesn't print their creators)"
+	}
+
+	var selected []string
+	for _, id := range order {
+		// walk up the creators; the chain ends at a goroutine that was not
+		// created by another one (or whose creator has exited)
+		for ancestor, ok := id, true; ok; ancestor, ok = parents[ancestor] {
+			if ancestor == root {
+				selected = append(selected, stacks[id])
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Sprintf("goroutine %d has exited", root)
+	}
+	return strings.Join(selected, "\n\n")
+}
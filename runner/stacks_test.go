@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:noinline
+func hangInTest(block chan struct{}) {
+	<-block
+}
+
+//go:noinline
+func hangInHelper(block chan struct{}) {
+	<-block
+}
+
+//go:noinline
+func idleOutsideTest(block chan struct{}) {
+	<-block
+}
+
+func Test_WatchTest_ShouldReportStacksOfHungTest(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	r.SetPerTestTimeout(20 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	go idleOutsideTest(block)
+	reported := make(chan string, 1)
+
+	// Act
+	go func() { // the "test" goroutine
+		r.WatchTest(func(d time.Duration, stacks string) {
+			reported <- stacks
+		})
+		go func() {
+			go hangInHelper(block) // started by a goroutine the test started
+			<-block
+		}()
+		hangInTest(block)
+	}()
+
+	// Assert
+	var stacks string
+	select {
+	case stacks = <-reported:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no timeout reported")
+	}
+	assert.Contains(t, stacks, "runner.hangInTest")
+	assert.Contains(t, stacks, "runner.hangInHelper")
+	assert.NotContains(t, stacks, "runner.idleOutsideTest")
+	assert.NotContains(t, stacks, "Test_WatchTest_ShouldReportStacksOfHungTest(", "the goroutine running this test is not part of the watched test")
+}
+
+func Test_WatchTest_ShouldReportAtTimeoutOfClock(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	clock := &fakeClock{}
+	r.setClock(clock)
+	r.SetPerTestTimeout(time.Minute)
+	var reported []time.Duration
+	stop := r.WatchTest(func(d time.Duration, stacks string) {
+		reported = append(reported, d)
+	})
+	defer stop()
+	require.Len(t, clock.timers, 1)
+	require.Equal(t, time.Minute, clock.timers[0].d)
+
+	// Act
+	clock.timers[0].fire()
+
+	// Assert
+	assert.Equal(t, []time.Duration{time.Minute}, reported)
+}
+
+func Test_WatchTest_ShouldNotReportAfterStop(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	clock := &fakeClock{}
+	r.setClock(clock)
+	r.SetPerTestTimeout(time.Minute)
+	reported := false
+
+	// Act
+	stop := r.WatchTest(func(d time.Duration, stacks string) {
+		reported = true
+	})
+	stop()
+	clock.timers[0].fire() // a timer that fired while stopping
+
+	// Assert
+	assert.True(t, clock.timers[0].stopped)
+	assert.False(t, reported)
+}
+
+func Test_WatchTest_ShouldDoNothingWithoutTimeout(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	clock := &fakeClock{}
+	r.setClock(clock)
+
+	// Act
+	r.WatchTest(func(d time.Duration, stacks string) {})()
+
+	// Assert
+	assert.Empty(t, clock.timers)
+}
+
+func Test_SelectStacks_ShouldFallBackToRootWithoutCreatorIDs(t *testing.T) {
+	// Arrange
+	dump := `goroutine 7 [chan receive]:
+runner.hangInTest(...)
+created by runner.Test_X
+	/src/x_test.go:10 +0x1
+
+goroutine 9 [chan receive]:
+runner.hangInHelper(...)
+created by runner.Test_X.func1
+	/src/x_test.go:12 +0x1`
+
+	// Act
+	stacks := selectStacks(dump, 7)
+
+	// Assert
+	assert.Contains(t, stacks, "runner.hangInTest")
+	assert.NotContains(t, stacks, "runner.hangInHelper")
+	assert.Contains(t, stacks, "the goroutines it started are not included")
+}
@@ -0,0 +1,204 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+/*
+stderr.go: Failing the tests that write to stderr, or keeping stderr apart from stdout. Stderr is captured on a pipe
+of its own, so what was written to it can be told apart from stdout, and forwarded to the output of the Run if
+combined. A pipe is read asynchronously, so when a test ends a sync marker is written to the pipe and waited for:
+everything the test wrote before it has been read by then.
+*/
+
+// stderrSyncMarker starts the lines the runner writes to the stderr pipe to
+// sync with its reader, followed by the name of the test and a newline
+const stderrSyncMarker = "\x00testdeck-stderr-sync "
+
+// SetFailOnStderr makes a Run capture stderr, including the file descriptor
+// where the platform allows redirecting it (Linux), and fail each test that
+// writes to it while it runs, recording what was written in its
+// Statistics.Stderr. The writes still show in the output of the Run. Race
+// detector reports are not counted, they are left to SetDetectRaces. Only
+// tests started with testdeck.Test are checked, and a write is attributed to
+// all the tests running at the time. Like SetDetectRaces, the result
+// reported by testing is not changed; the one reported to SetOnTestEnd is.
+func (r *runner) SetFailOnStderr(yes bool) {
+	r.failStderr = yes
+}
+
+// SetCombineOutput sets whether stderr is combined with stdout in the output
+// of a Run, like go test does (the default), or kept apart: in the
+// Statistics.Stderr of each test started with testdeck.Test instead of its
+// Output. Kept apart, it is still printed while the tests run. SetDetectRaces
+// keeps it combined, as the race reports are read from the output.
+func (r *runner) SetCombineOutput(yes bool) {
+	r.splitStderr = !yes
+}
+
+// splitsStderr returns true if stderr is kept apart, see SetCombineOutput
+func (r *runner) splitsStderr() bool {
+	return r.splitStderr && !r.detectRaces
+}
+
+// watchesStderr returns true if stderr is captured on a pipe of its own
+func (r *runner) watchesStderr() bool {
+	return r.failStderr || r.splitsStderr()
+}
+
+// stderrWatch reads the stderr pipe of a Run for SetFailOnStderr and
+// SetCombineOutput
+type stderrWatch struct {
+	w    *os.File      // the write end of the pipe
+	done chan struct{} // closed when the pipe is drained
+
+	mu      sync.Mutex
+	running map[string]*strings.Builder // by test
+	synced  map[string]chan struct{}    // by test, closed when the test's marker is read
+	pending string                      // a rule line that may start a race report
+	inRace  bool
+}
+
+// watchStderr starts capturing stderr on a pipe, copying it to out. stop
+// closes the pipe and waits for its reader.
+func (r *runner) watchStderr(out io.Writer) (stderr *os.File, stop func()) {
+	rp, wp, err := os.Pipe()
+	if err != nil {
+		r.addWarning(fmt.Sprintf("capturing stderr: %v", err))
+		return nil, func() {}
+	}
+	watch := &stderrWatch{
+		w:       wp,
+		done:    make(chan struct{}),
+		running: make(map[string]*strings.Builder),
+		synced:  make(map[string]chan struct{}),
+	}
+	go watch.copy(rp, out)
+	r.mu.Lock()
+	r.stderrWatch = watch
+	r.mu.Unlock()
+	return wp, func() {
+		r.mu.Lock()
+		r.stderrWatch = nil
+		r.mu.Unlock()
+		wp.Close()
+		<-watch.done
+	}
+}
+
+// copy copies the pipe to out line by line, without the sync markers, and
+// records the lines in the running tests
+func (watch *stderrWatch) copy(rp *os.File, out io.Writer) {
+	defer close(watch.done)
+	defer rp.Close()
+	br := bufio.NewReader(rp)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			text, test, isSync := strings.Cut(line, stderrSyncMarker)
+			if text != "" {
+				io.WriteString(out, text)
+				watch.record(text)
+			}
+			if isSync {
+				watch.sync(strings.TrimSuffix(test, "\n"))
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// record adds a line written to stderr to the running tests, leaving out the
+// race detector's reports
+func (watch *stderrWatch) record(text string) {
+	watch.mu.Lock()
+	defer watch.mu.Unlock()
+	trimmed := strings.TrimSpace(text)
+	if watch.inRace {
+		watch.inRace = trimmed != raceReportRule
+		return
+	}
+	if watch.pending != "" {
+		pending := watch.pending
+		watch.pending = ""
+		if strings.HasPrefix(trimmed, "WARNING: DATA RACE") {
+			watch.inRace = true
+			return
+		}
+		watch.add(pending)
+	}
+	if trimmed == raceReportRule {
+		watch.pending = text
+		return
+	}
+	watch.add(text)
+}
+
+func (watch *stderrWatch) add(text string) {
+	for _, b := range watch.running {
+		b.WriteString(text)
+	}
+}
+
+// sync signals that everything test wrote before its marker has been read
+func (watch *stderrWatch) sync(test string) {
+	watch.mu.Lock()
+	defer watch.mu.Unlock()
+	if ch, ok := watch.synced[test]; ok {
+		close(ch)
+		delete(watch.synced, test)
+	}
+}
+
+// startStderr makes name one of the running tests that get stderr writes
+func (r *runner) startStderr(name string) {
+	r.mu.Lock()
+	watch := r.stderrWatch
+	r.mu.Unlock()
+	if watch == nil {
+		return
+	}
+	watch.mu.Lock()
+	defer watch.mu.Unlock()
+	watch.running[name] = &strings.Builder{}
+}
+
+// stopStderr returns what was written to stderr while the test name ran, once
+// it has all been read from the pipe
+func (r *runner) stopStderr(name string) string {
+	r.mu.Lock()
+	watch := r.stderrWatch
+	r.mu.Unlock()
+	if watch == nil {
+		return ""
+	}
+	watch.mu.Lock()
+	if _, ok := watch.running[name]; !ok {
+		watch.mu.Unlock()
+		return ""
+	}
+	synced := make(chan struct{})
+	watch.synced[name] = synced
+	watch.mu.Unlock()
+
+	if _, err := fmt.Fprintf(watch.w, "%s%s\n", stderrSyncMarker, name); err == nil {
+		select {
+		case <-synced:
+		case <-watch.done:
+		}
+	}
+
+	watch.mu.Lock()
+	defer watch.mu.Unlock()
+	delete(watch.synced, name)
+	out := watch.running[name].String()
+	delete(watch.running, name)
+	return out
+}
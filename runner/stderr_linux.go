@@ -0,0 +1,26 @@
+package runner
+
+import (
+	"os"
+	"syscall"
+)
+
+/*
+stderr_linux.go: Redirecting the stderr file descriptor, which the runtime (e.g. the race detector) writes to directly
+*/
+
+// redirectStderrFd points file descriptor 2 at w until restore is called
+func redirectStderrFd(w *os.File) (restore func(), err error) {
+	saved, err := syscall.Dup(2)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Dup3(int(w.Fd()), 2, 0); err != nil {
+		syscall.Close(saved)
+		return nil, err
+	}
+	return func() {
+		syscall.Dup3(saved, 2, 0)
+		syscall.Close(saved)
+	}, nil
+}
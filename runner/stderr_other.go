@@ -0,0 +1,16 @@
+//go:build !linux
+
+package runner
+
+import (
+	"errors"
+	"os"
+)
+
+/*
+stderr_other.go: The stderr file descriptor can't be redirected on this platform; only writes through os.Stderr are captured
+*/
+
+func redirectStderrFd(w *os.File) (restore func(), err error) {
+	return nil, errors.New("redirecting the stderr file descriptor is not supported on this platform")
+}
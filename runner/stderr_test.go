@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runWritingStderr runs a test that writes to stderr and one that writes to
+// stdout only, the way the test harness reports them, and returns what
+// reached the process' stderr, which is a file for the duration of the Run
+func runWritingStderr(t *testing.T, r *runner) (processStderr string) {
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		r.TestStarted("TestWritesStderr")
+		fmt.Fprint(os.Stderr, "unexpected warning\n")
+		fmt.Fprint(os.Stderr, "no newline")
+		r.AddStatistics(&constants.Statistics{Name: "TestWritesStderr"})
+		r.TestStarted("TestQuiet")
+		fmt.Println("stdout is fine")
+		r.AddStatistics(&constants.Statistics{Name: "TestQuiet"})
+	}
+	stderr, err := os.CreateTemp(t.TempDir(), "stderr")
+	require.NoError(t, err)
+	defer func(prev *os.File) { os.Stderr = prev }(os.Stderr)
+	os.Stderr = stderr
+	r.Run()
+	require.NoError(t, stderr.Close())
+	written, err := os.ReadFile(stderr.Name())
+	require.NoError(t, err)
+	return string(written)
+}
+
+func Test_SetFailOnStderr_ShouldFailTestWritingToStderr(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestWritesStderr", "TestQuiet")).(*runner)
+	r.SetFailOnStderr(true)
+
+	// Act
+	runWritingStderr(t, r)
+
+	// Assert
+	require.Len(t, r.Statistics(), 2)
+	assert.True(t, r.Statistics()[0].Failed)
+	assert.Equal(t, "unexpected warning\nno newline", r.Statistics()[0].Stderr)
+	assert.False(t, r.Statistics()[1].Failed)
+	assert.Empty(t, r.Statistics()[1].Stderr)
+	assert.False(t, r.Passed())
+	assert.Contains(t, r.Output(), "unexpected warning\n")
+	assert.NotContains(t, r.Output(), stderrSyncMarker)
+}
+
+func Test_SetFailOnStderr_ShouldNotFailTestsWhenOff(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestWritesStderr", "TestQuiet")).(*runner)
+
+	// Act
+	processStderr := runWritingStderr(t, r)
+
+	// Assert
+	assert.Equal(t, "unexpected warning\nno newline", processStderr)
+	require.Len(t, r.Statistics(), 2)
+	for _, s := range r.Statistics() {
+		assert.False(t, s.Failed, s.Name)
+		assert.Empty(t, s.Stderr, s.Name)
+	}
+}
+
+func Test_StderrWatch_ShouldLeaveOutRaceReports(t *testing.T) {
+	// Arrange
+	watch := &stderrWatch{running: map[string]*strings.Builder{"TestRacy": {}}}
+	lines := []string{
+		"==================\n",
+		"WARNING: DATA RACE\n",
+		"Write at 0x00c000012345 by goroutine 8:\n",
+		"==================\n",
+		"before the rule\n",
+		"==================\n",
+		"not a race report\n",
+	}
+
+	// Act
+	for _, line := range lines {
+		watch.record(line)
+	}
+
+	// Assert
+	assert.Equal(t, "before the rule\n==================\nnot a race report\n", watch.running["TestRacy"].String())
+}
+
+func Test_SetCombineOutput_ShouldCaptureStderrPerStream(t *testing.T) {
+	cases := map[string]struct {
+		combine      bool
+		splitLog     bool
+		detectRaces  bool
+		wantStderr   string
+		wantInOutput bool
+	}{
+		"Combined":             {combine: true, splitLog: true, wantStderr: "", wantInOutput: true},
+		"Split":                {combine: false, splitLog: true, wantStderr: "unexpected warning\nno newline", wantInOutput: false},
+		"SplitWithDetectRaces": {combine: false, splitLog: true, detectRaces: true, wantStderr: "", wantInOutput: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			r := newInstance(newTestingM("TestWritesStderr", "TestQuiet")).(*runner)
+			r.SetCombineOutput(tc.combine)
+			r.SetSplitLogOutput(tc.splitLog)
+			r.SetDetectRaces(tc.detectRaces)
+
+			// Act
+			runWritingStderr(t, r)
+
+			// Assert
+			require.Len(t, r.Statistics(), 2)
+			assert.Equal(t, tc.wantStderr, r.Statistics()[0].Stderr)
+			assert.False(t, r.Statistics()[0].Failed)
+			assert.Empty(t, r.Statistics()[1].Stderr)
+			assert.Equal(t, tc.wantInOutput, strings.Contains(r.Output(), "unexpected warning\n"))
+			assert.Contains(t, r.Output(), "stdout is fine")
+			assert.NotContains(t, r.Output(), stderrSyncMarker)
+		})
+	}
+}
+
+func Test_SetCombineOutput_ShouldStillFailOnStderrWhenSplit(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestWritesStderr", "TestQuiet")).(*runner)
+	r.SetCombineOutput(false)
+	r.SetFailOnStderr(true)
+
+	// Act
+	runWritingStderr(t, r)
+
+	// Assert
+	require.Len(t, r.Statistics(), 2)
+	assert.True(t, r.Statistics()[0].Failed)
+	assert.Equal(t, "unexpected warning\nno newline", r.Statistics()[0].Stderr)
+	assert.NotContains(t, r.Output(), "unexpected warning")
+}
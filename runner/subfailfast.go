@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"sync"
+	"testing"
+)
+
+/*
+subfailfast.go: Skipping the remaining subtests of a test once one of them failed. testing has no such mode, so it only
+works for subtests started with RunSubtest instead of t.Run, which the runner can hold back.
+*/
+
+// stopOnSubFail holds the parents that called StopOnSubFail, with the name of
+// their first failed subtest, or "" if none failed yet
+var stopOnSubFail sync.Map // *testing.T to string
+
+// StopOnSubFail makes the subtests of t that RunSubtest starts after one of
+// them failed skip instead of running, until t ends. Other tests are not
+// affected. Subtests started with t.Run are neither held back nor noticed
+// when they fail. A parallel subtest's failure is only noticed once it
+// finishes, so the subtests started meanwhile still run.
+func StopOnSubFail(t *testing.T) {
+	if _, loaded := stopOnSubFail.LoadOrStore(t, ""); loaded {
+		return
+	}
+	t.Cleanup(func() { stopOnSubFail.Delete(t) })
+}
+
+// RunSubtest runs f as the subtest name of t like t.Run. If t called
+// StopOnSubFail and an earlier subtest started with RunSubtest failed, the
+// subtest is skipped instead, naming the failed one. Like t.Run it returns
+// false if the subtest failed.
+func RunSubtest(t *testing.T, name string, f func(t *testing.T)) bool {
+	return t.Run(name, func(sub *testing.T) {
+		failed, stopping := stopOnSubFail.Load(t)
+		if !stopping {
+			f(sub)
+			return
+		}
+		if failed != "" {
+			sub.Skipf("not run: subtest %s failed", failed)
+		}
+		sub.Cleanup(func() {
+			// runs last, once sub and its own subtests finished
+			if sub.Failed() {
+				stopOnSubFail.CompareAndSwap(t, "", sub.Name())
+			}
+		})
+		f(sub)
+	})
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runSubtests runs a test whose subtests A, B (failing) and C are started
+// with RunSubtest and Plain with t.Run, and another test with subtest D,
+// through a runner that doesn't print their output, and returns the subtests
+// that ran
+func runSubtests(t *testing.T, stop bool) (ran []string) {
+	sub := func(fail bool) func(t *testing.T) {
+		return func(t *testing.T) {
+			ran = append(ran, t.Name())
+			if fail {
+				t.Fail()
+			}
+		}
+	}
+	tests := []testing.InternalTest{
+		{Name: "TestParent", F: func(t *testing.T) {
+			if stop {
+				StopOnSubFail(t)
+			}
+			RunSubtest(t, "A", sub(false))
+			RunSubtest(t, "B", sub(true))
+			RunSubtest(t, "C", sub(false))
+			t.Run("Plain", sub(false))
+		}},
+		{Name: "TestOther", F: func(t *testing.T) {
+			RunSubtest(t, "D", sub(false))
+		}},
+	}
+	r := newInstance(testing.MainStart(&TestDeps{}, tests, nil, nil, nil))
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	defer setTestFlag("test.v", "false")()
+	runIsolated(t)
+	r.Run()
+	return ran
+}
+
+func Test_StopOnSubFail_ShouldSkipLaterSubtests(t *testing.T) {
+	// Act
+	ran := runSubtests(t, true)
+
+	// Assert
+	assert.Equal(t, []string{"TestParent/A", "TestParent/B", "TestParent/Plain", "TestOther/D"}, ran)
+}
+
+func Test_StopOnSubFail_ShouldRunAllSubtestsWhenOff(t *testing.T) {
+	// Act
+	ran := runSubtests(t, false)
+
+	// Assert
+	assert.Equal(t, []string{"TestParent/A", "TestParent/B", "TestParent/C", "TestParent/Plain", "TestOther/D"}, ran)
+}
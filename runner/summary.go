@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+summary.go: Writers for machine-readable summaries of a test run's statistics
+*/
+
+// Outcome returns the final status of a test case (constants.StatusPass, StatusFail or StatusSkip)
+func Outcome(s constants.Statistics) string {
+	if s.Failed {
+		return constants.StatusFail
+	}
+	for _, status := range s.Statuses {
+		if status.Status == constants.StatusSkip {
+			return constants.StatusSkip
+		}
+	}
+	return constants.StatusPass
+}
+
+// BlockingSerialTests returns the tests that did not call t.Parallel() and
+// took at least threshold, slowest first. A serial test holds the serial slot
+// (no other serial test can start) for its whole duration, so the duration is
+// used as its blocking time. This is an approximation: time spent before a
+// late call to t.Parallel() is not counted, and a serial subtest only blocks
+// its parent.
+func BlockingSerialTests(stats []constants.Statistics, threshold time.Duration) []constants.Statistics {
+	var serial []constants.Statistics
+	for _, s := range stats {
+		if !s.Parallel && s.Duration >= threshold {
+			serial = append(serial, s)
+		}
+	}
+	sort.SliceStable(serial, func(i, j int) bool {
+		return serial[i].Duration > serial[j].Duration
+	})
+	return serial
+}
+
+// WriteSummaryYAML writes a compact YAML document with the run ID (or the shards of a merged result), the seed of
+// SetRandSeed and the failure of the run if set, its totals and the per-test outcome and duration.
+// Tests that passed on a retry (Statistics.Flaky) are counted as flaky instead of passed.
+// Output is only included for failed tests and is written as a block scalar so multi-line output stays readable.
+func WriteSummaryYAML(w io.Writer, res *Result) error {
+	stats := res.Stats
+
+	bw := bufio.NewWriter(w)
+	if res.RunID != "" {
+		fmt.Fprintf(bw, "run_id: %s\n", strconv.Quote(res.RunID))
+	}
+	if len(res.Shards) > 0 {
+		quoted := make([]string, len(res.Shards))
+		for i, shard := range res.Shards {
+			quoted[i] = strconv.Quote(shard)
+		}
+		fmt.Fprintf(bw, "shards: [%s]\n", strings.Join(quoted, ", "))
+	}
+	if res.RandSeed != 0 {
+		fmt.Fprintf(bw, "rand_seed: %d\n", res.RandSeed)
+	}
+	if res.Failure != "" {
+		fmt.Fprintf(bw, "failure: %s\n", strconv.Quote(res.Failure))
+	}
+	fmt.Fprintln(bw, "totals:")
+	fmt.Fprintf(bw, "  total: %d\n", res.Total)
+	fmt.Fprintf(bw, "  passed: %d\n", res.Passed)
+	fmt.Fprintf(bw, "  flaky: %d\n", res.Flaky)
+	fmt.Fprintf(bw, "  failed: %d\n", res.Failed)
+	fmt.Fprintf(bw, "  skipped: %d\n", res.Skipped)
+
+	if len(stats) == 0 {
+		fmt.Fprintln(bw, "tests: []")
+		return bw.Flush()
+	}
+
+	fmt.Fprintln(bw, "tests:")
+	for _, s := range stats {
+		outcome := Outcome(s)
+		fmt.Fprintf(bw, "  - name: %s\n", strconv.Quote(s.Name))
+		fmt.Fprintf(bw, "    outcome: %s\n", outcome)
+		fmt.Fprintf(bw, "    duration: %s\n", strconv.Quote(s.Duration.String()))
+		if s.Flaky {
+			fmt.Fprintf(bw, "    flaky: true\n")
+		}
+		if outcome == constants.StatusSkip && s.SkipReason != "" {
+			fmt.Fprintf(bw, "    skip_reason: %s\n", strconv.Quote(s.SkipReason))
+		}
+		if outcome == constants.StatusFail && s.Output != "" {
+			fmt.Fprintf(bw, "    output: ")
+			writeBlockScalar(bw, s.Output, "      ")
+		}
+	}
+	return bw.Flush()
+}
+
+// writeBlockScalar writes value as a YAML literal block scalar indented by indent.
+// The chomping indicator is chosen so the value round-trips exactly.
+func writeBlockScalar(w *bufio.Writer, value string, indent string) {
+	header := "|"
+	if strings.HasPrefix(value, " ") || strings.HasPrefix(value, "\n") {
+		// leading whitespace would be taken as indentation, so make it explicit
+		// (content is always indented two spaces deeper than its key)
+		header += "2"
+	}
+
+	body := strings.TrimRight(value, "\n")
+	switch trailing := len(value) - len(body); {
+	case trailing == 0:
+		header += "-" // strip: no final line break
+	case trailing > 1:
+		header += "+" // keep: preserve extra blank lines
+	}
+	w.WriteString(header + "\n")
+
+	lines := strings.Split(value, "\n")
+	if strings.HasSuffix(value, "\n") {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		if line == "" {
+			w.WriteString("\n")
+			continue
+		}
+		w.WriteString(indent + line + "\n")
+	}
+}
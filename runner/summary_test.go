@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+func Test_Outcome_ShouldReturnFinalStatus(t *testing.T) {
+	cases := map[string]struct {
+		stats constants.Statistics
+		want  string
+	}{
+		"Pass": {
+			stats: constants.Statistics{Statuses: []constants.Status{{Status: constants.StatusPass}}},
+			want:  constants.StatusPass,
+		},
+		"Fail": {
+			stats: constants.Statistics{Failed: true, Statuses: []constants.Status{{Status: constants.StatusFail}}},
+			want:  constants.StatusFail,
+		},
+		"Skip": {
+			stats: constants.Statistics{Statuses: []constants.Status{{Status: constants.StatusSkip}, {Status: constants.StatusPass}}},
+			want:  constants.StatusSkip,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Outcome(tc.stats))
+		})
+	}
+}
+
+func Test_WriteSummaryYAML_ShouldMatchGolden(t *testing.T) {
+	// Arrange
+	stats := []constants.Statistics{
+		{
+			Name:     "TestPass",
+			Statuses: []constants.Status{{Status: constants.StatusPass}},
+			Duration: 1500 * time.Millisecond,
+		},
+		{
+			Name:     "TestFail",
+			Failed:   true,
+			Statuses: []constants.Status{{Status: constants.StatusFail}},
+			Duration: 20 * time.Millisecond,
+			Output:   "--- FAIL: TestFail (0.02s)\n    fail_test.go:12: want 1, got 2\n\n",
+		},
+		{
+			Name:     "TestFailIndented",
+			Failed:   true,
+			Statuses: []constants.Status{{Status: constants.StatusFail}},
+			Output:   "    indented first line\nno trailing newline",
+		},
+		{
+			Name:     "TestFlaky",
+			Statuses: []constants.Status{{Status: constants.StatusPass}},
+			Retry:    1,
+			Flaky:    true,
+		},
+		{
+			Name:       "TestSkip",
+			Statuses:   []constants.Status{{Status: constants.StatusSkip}},
+			SkipReason: "needs \"staging\" env",
+		},
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	info := RunInfo{RunID: "nightly-42", StartedAt: start, FinishedAt: start.Add(time.Minute), RandSeed: 7, Failure: "coverage 70.0% is below 80.0%"}
+	res := &Result{ResultSummary: Summarize(info, stats), Stats: stats}
+	var buf bytes.Buffer
+
+	// Act
+	err := WriteSummaryYAML(&buf, res)
+
+	// Assert
+	require.NoError(t, err)
+	golden := filepath.Join("testdata", "summary.golden.yaml")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0644))
+	}
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), buf.String())
+}
+
+func Test_WriteSummaryYAML_ShouldWriteEmptyRun(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+
+	// Act
+	err := WriteSummaryYAML(&buf, &Result{})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "totals:\n  total: 0\n  passed: 0\n  flaky: 0\n  failed: 0\n  skipped: 0\ntests: []\n", buf.String())
+}
+
+func Test_WriteSummaryYAML_ShouldListShardsOfMergedResult(t *testing.T) {
+	// Arrange
+	a := shardResult("run-a", time.Second)
+	a.RandSeed = 7
+	b := shardResult("run-b", time.Second)
+	b.RandSeed = 7
+	merged, err := MergeResults(a, b)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+
+	// Act
+	err = WriteSummaryYAML(&buf, merged)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "shards: [\"run-a\", \"run-b\"]\nrand_seed: 7\ntotals:\n  total: 0\n  passed: 0\n  flaky: 0\n  failed: 0\n  skipped: 0\ntests: []\n", buf.String())
+}
+
+func Test_BlockingSerialTests_ShouldFlagLongSerialTests(t *testing.T) {
+	// Arrange
+	stats := []constants.Statistics{
+		{Name: "TestFastSerial", Duration: 10 * time.Millisecond},
+		{Name: "TestSlowSerial", Duration: 3 * time.Second},
+		{Name: "TestSlowParallel", Duration: 5 * time.Second, Parallel: true},
+		{Name: "TestSlowerSerial", Duration: 4 * time.Second},
+	}
+
+	// Act
+	got := BlockingSerialTests(stats, time.Second)
+
+	// Assert
+	require.Equal(t, 2, len(got))
+	assert.Equal(t, "TestSlowerSerial", got[0].Name)
+	assert.Equal(t, "TestSlowSerial", got[1].Name)
+}
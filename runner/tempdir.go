@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"fmt"
+	tdlog "log"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+/*
+tempdir.go: Per-test scratch directories that are removed when the test finishes
+*/
+
+var keepFailedTempDirs = false
+
+// testTempDirs holds the temp directory of each running test, keyed by test name
+var testTempDirs sync.Map
+
+// reTempDirName matches the characters that are not kept in temp directory names
+var reTempDirName = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// TempDir returns a temp directory for the running test, creating it on the
+// first call. Each test (and subtest) gets its own directory. It is removed
+// when the test finishes, unless the test failed and SetKeepFailedTempDirs is
+// on. Unlike t.TempDir(), a failed removal doesn't fail the test; it is
+// recorded as a warning (see Runner.Warnings).
+func TempDir(t *testing.T) string {
+	if dir, ok := testTempDirs.Load(t.Name()); ok {
+		return dir.(string)
+	}
+
+	dir, err := os.MkdirTemp("", "testdeck-"+reTempDirName.ReplaceAllString(t.Name(), "_")+"-")
+	if err != nil {
+		t.Fatalf("testdeck: could not create temp dir: %v", err)
+	}
+	testTempDirs.Store(t.Name(), dir)
+
+	t.Cleanup(func() {
+		testTempDirs.Delete(t.Name())
+		if t.Failed() && keepFailedTempDirs {
+			warn(fmt.Sprintf("kept temp dir of failed test %s: %s", t.Name(), dir))
+			return
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			warn(fmt.Sprintf("could not remove temp dir of %s: %v", t.Name(), err))
+		}
+	})
+	return dir
+}
+
+// SetKeepFailedTempDirs keeps the TempDir of failed tests for debugging
+func (r *runner) SetKeepFailedTempDirs(yes bool) {
+	keepFailedTempDirs = yes
+}
+
+// warn records a warning on the runner (or logs it if there is no runner)
+func warn(message string) {
+	if instance == nil {
+		tdlog.Println("testdeck warning:", message)
+		return
+	}
+	instance.(*runner).addWarning(message)
+}
@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TempDir_ShouldExistDuringTestAndBeRemovedAfter(t *testing.T) {
+	// Arrange
+	var dir string
+
+	// Act
+	t.Run("pass", func(t *testing.T) {
+		dir = TempDir(t)
+		info, err := os.Stat(dir)
+		require.NoError(t, err)
+		assert.True(t, info.IsDir())
+		assert.Equal(t, dir, TempDir(t))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "scratch.txt"), []byte("x"), 0644))
+	})
+
+	// Assert
+	_, err := os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_TempDir_ShouldBeUniquePerTest(t *testing.T) {
+	// Arrange
+	var dirs []string
+
+	// Act
+	for _, name := range []string{"a", "b"} {
+		t.Run(name, func(t *testing.T) {
+			dirs = append(dirs, TempDir(t))
+		})
+	}
+
+	// Assert
+	require.Equal(t, 2, len(dirs))
+	assert.NotEqual(t, dirs[0], dirs[1])
+}
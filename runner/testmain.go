@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+testmain.go: An adapter for running an existing TestMain-style suite (anything that calls m.Run()) through the runner.
+The testing package doesn't expose the results of m.Run so they are parsed from the verbose output instead.
+*/
+
+// reTestResult matches the "--- PASS: TestName (0.00s)" lines printed by the testing package in verbose mode
+var reTestResult = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+s)\)`)
+
+// RunTestMain runs run (e.g. a suite's m.Run) with verbose output enabled and
+// adds a Statistics entry for every test result found in its output. It
+// returns run's exit code. Output() holds the captured output afterwards.
+//
+// The statistics only have the name, outcome and duration of each test since
+// that is all the output contains. Tests using testdeck.Test inside run add
+// their full statistics instead, and their results in the output are skipped.
+func (r *runner) RunTestMain(run func() int) int {
+	var code int
+	first := len(r.stats)
+	restoreVerbose := setTestVerbose()
+	r.output = r.captureOutput(func() {
+		code = run()
+	})
+	restoreVerbose()
+
+	r.addParsedResults(first, r.output)
+	return code
+}
+
+// addParsedResults adds the results parsed from output, except those of the
+// tests that added their own statistics since first
+func (r *runner) addParsedResults(first int, output string) {
+	own := make(map[string]bool)
+	for _, s := range r.stats[first:] {
+		own[s.Name] = true
+	}
+	for _, s := range parseTestOutput(output) {
+		if own[s.Name] {
+			continue
+		}
+		stats := s
+		r.AddStatistics(&stats)
+	}
+}
+
+// parseTestOutput returns a Statistics entry for each result line in output,
+// named without the tag of the match workaround
+func parseTestOutput(output string) []constants.Statistics {
+	var stats []constants.Statistics
+	for _, line := range strings.Split(output, "\n") {
+		parts := reTestResult.FindStringSubmatch(line)
+		if parts == nil {
+			continue
+		}
+		d, _ := time.ParseDuration(parts[3])
+		s := constants.Statistics{
+			Name:     untagName(parts[2]),
+			Duration: d,
+			Output:   output,
+		}
+		switch parts[1] {
+		case constants.ResultPass:
+			s.Statuses = []constants.Status{{Status: constants.StatusPass, Lifecycle: constants.LifecycleTestFinished}}
+		case constants.ResultFail:
+			s.Failed = true
+			s.Statuses = []constants.Status{{Status: constants.StatusFail, Lifecycle: constants.LifecycleTestFinished}}
+		default:
+			s.Statuses = []constants.Status{{Status: constants.StatusSkip, Lifecycle: constants.LifecycleTestFinished}}
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
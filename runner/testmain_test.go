@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RunTestMain_ShouldCollectResultsAndExitCode(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	outerVerbose := flag.Lookup("test.v").Value.String()
+	verbose := ""
+	testMain := func() int {
+		verbose = flag.Lookup("test.v").Value.String()
+		fmt.Println("=== RUN   TestA")
+		fmt.Println("--- PASS: TestA (0.50s)")
+		fmt.Println("=== RUN   TestB")
+		fmt.Println("    b_test.go:10: want 1, got 2")
+		fmt.Println("--- FAIL: TestB (1.25s)")
+		fmt.Println("=== RUN   TestC")
+		fmt.Println("=== RUN   TestC/sub")
+		fmt.Println("    --- SKIP: TestC/sub (0.00s)")
+		fmt.Println("--- PASS: TestC (0.00s)")
+		fmt.Println("FAIL")
+		return 1
+	}
+
+	// Act
+	code := r.RunTestMain(testMain)
+
+	// Assert
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "true", verbose)
+	assert.Equal(t, outerVerbose, flag.Lookup("test.v").Value.String())
+	assert.Contains(t, r.Output(), "want 1, got 2")
+	assert.False(t, r.Passed())
+	stats := r.Statistics()
+	require.Equal(t, 4, len(stats))
+	counts := make(map[string]int)
+	for _, s := range stats {
+		counts[Outcome(s)]++
+	}
+	assert.Equal(t, map[string]int{constants.StatusPass: 2, constants.StatusFail: 1, constants.StatusSkip: 1}, counts)
+	assert.Equal(t, "TestB", stats[1].Name)
+	assert.Equal(t, 1250*time.Millisecond, stats[1].Duration)
+	assert.Equal(t, "TestC/sub", stats[2].Name)
+}
+
+func Test_RunTestMain_ShouldNotParseResultsOfTestdeckTests(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	testMain := func() int {
+		fmt.Println("=== RUN   TestPlain")
+		fmt.Println("--- PASS: TestPlain (0.10s)")
+		fmt.Println("=== RUN   TestDeck")
+		// what testdeck.Test adds for the test
+		r.AddStatistics(&constants.Statistics{Name: "TestDeck", Failed: true, Duration: 2 * time.Second})
+		fmt.Println("--- FAIL: TestDeck (2.00s)")
+		fmt.Println("FAIL")
+		return 1
+	}
+
+	// Act
+	code := r.RunTestMain(testMain)
+
+	// Assert
+	assert.Equal(t, 1, code)
+	assert.False(t, r.Passed())
+	stats := r.Statistics()
+	require.Equal(t, 2, len(stats))
+	assert.Equal(t, "TestDeck", stats[0].Name)
+	assert.Equal(t, 1, stats[0].Attempt)
+	assert.Equal(t, "TestPlain", stats[1].Name)
+	assert.Equal(t, 1, r.(*runner).failures)
+}
+
+func Test_ParseTestOutput_ShouldUntagMatchWorkaroundNames(t *testing.T) {
+	// Arrange
+	output := "=== RUN   TestA\x00TestA\n--- PASS: TestA\x00TestA (0.00s)\n=== RUN   TestB\n--- FAIL: TestB (0.00s)\n"
+
+	// Act
+	stats := parseTestOutput(output)
+
+	// Assert
+	require.Len(t, stats, 2)
+	assert.Equal(t, "TestA", stats[0].Name)
+	assert.Equal(t, "TestB", stats[1].Name)
+}
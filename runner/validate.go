@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+/*
+validate.go: Pre-flight checks of the tests given to the runner
+*/
+
+// Validate returns an error listing the names of tests that are given to the
+// runner more than once (e.g. when composing tests from several packages),
+// since their results can't be told apart. Benchmarks are not supported by
+// the runner, and examples are not checked.
+func (r *runner) Validate() error {
+	return validateTests(r.allTests())
+}
+
+// SetStrict makes Run call Validate first and not run any test if it fails.
+// The error is then recorded as a warning (see Warnings) and as the Run's
+// RunInfo().Failure, so Passed returns false.
+func (r *runner) SetStrict(yes bool) {
+	r.strict = yes
+}
+
+func validateTests(tests []testing.InternalTest) error {
+	counts := make(map[string]int)
+	var duplicates []string
+	for _, test := range tests {
+		counts[test.Name]++
+		if counts[test.Name] == 2 {
+			duplicates = append(duplicates, test.Name)
+		}
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("duplicate test names: %s", strings.Join(duplicates, ", "))
+	}
+	return nil
+}
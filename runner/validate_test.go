@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestingM(names ...string) *testing.M {
+	var tests []testing.InternalTest
+	for _, name := range names {
+		tests = append(tests, testing.InternalTest{Name: name, F: func(t *testing.T) {}})
+	}
+	return testing.MainStart(&TestDeps{}, tests, nil, nil, nil)
+}
+
+func Test_Validate_ShouldReportDuplicateNames(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA", "TestB", "TestA", "TestC", "TestB", "TestA"))
+
+	// Act
+	err := r.Validate()
+
+	// Assert
+	require.Error(t, err)
+	assert.Equal(t, "duplicate test names: TestA, TestB", err.Error())
+}
+
+func Test_Validate_ShouldAcceptUniqueNames(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA", "TestB"))
+
+	// Act
+	err := r.Validate()
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func Test_Run_ShouldRefuseDuplicatesWhenStrict(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA", "TestA"))
+	r.SetStrict(true)
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	ran := false
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		ran = true
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.False(t, ran)
+	assert.False(t, r.Passed())
+	require.Equal(t, 1, len(r.Warnings()))
+	assert.Contains(t, r.Warnings()[0], "TestA")
+	assert.Equal(t, r.Warnings()[0], r.RunInfo().Failure)
+}
@@ -0,0 +1,71 @@
+package runner
+
+import "fmt"
+
+/*
+variants.go: Running the selected tests once per variant of an external setting, e.g. once per database driver
+*/
+
+// Variant is a setting the tests are run with. Setup (which may be nil)
+// prepares it before the tests run and returns a func (which may be nil) that
+// tears it down after them.
+type Variant struct {
+	Name  string
+	Setup func() (teardown func())
+}
+
+// SetVariants makes Run run the selected tests, with their retries (see
+// SetRetries), once per variant in order, between the variant's setup and
+// teardown. The statistics of each variant's tests are named as if the
+// variant were their parent test, e.g. "postgres/TestA", and so are the names
+// passed to SetOnTestStart and SetOnTestEnd. Variants are kept in memory only
+// and are not part of WireConfig. No variants (the default) runs the tests
+// once as usual.
+func (r *runner) SetVariants(variants []Variant) error {
+	seen := make(map[string]bool)
+	for _, v := range variants {
+		if v.Name == "" {
+			return fmt.Errorf("variant name must not be empty")
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("duplicate variant %q", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	r.variants = append([]Variant(nil), variants...)
+	return nil
+}
+
+// runVariants runs the tests once per variant set by SetVariants
+func (r *runner) runVariants() {
+	defer func() { r.variant = "" }()
+	r.output = ""
+	for _, v := range r.variants {
+		r.LogEvent(fmt.Sprintf("Variant %s", v.Name))
+		var teardown func()
+		if v.Setup != nil {
+			teardown = v.Setup()
+		}
+
+		r.variant = v.Name
+		first := len(r.stats)
+		r.output += r.runOnce(r.matcher, r.matchPattern)
+		// retries find the failed tests by their own names, so rename after
+		r.retryFailures(first)
+		for i := first; i < len(r.stats); i++ {
+			r.stats[i].Name = r.variantName(r.stats[i].Name)
+		}
+
+		if teardown != nil {
+			teardown()
+		}
+	}
+}
+
+// variantName returns the name of test within the variant being run
+func (r *runner) variantName(name string) string {
+	if r.variant == "" {
+		return name
+	}
+	return r.variant + "/" + name
+}
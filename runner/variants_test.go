@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SetVariants_ShouldRunTestsOncePerVariant(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestA", "TestB")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	var events []string
+	variant := func(name string) Variant {
+		return Variant{Name: name, Setup: func() func() {
+			events = append(events, "setup "+name)
+			return func() { events = append(events, "teardown "+name) }
+		}}
+	}
+	require.NoError(t, r.SetVariants([]Variant{variant("sqlite"), variant("postgres")}))
+	var ended []string
+	r.SetOnTestEnd(func(name string, outcome string, d time.Duration) { ended = append(ended, name) })
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for _, test := range tests {
+			_, _, name := MatchTag(test.Name)
+			events = append(events, "run "+name)
+			r.AddStatistics(&constants.Statistics{Name: name})
+		}
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.Equal(t, []string{
+		"setup sqlite", "run TestA", "run TestB", "teardown sqlite",
+		"setup postgres", "run TestA", "run TestB", "teardown postgres",
+	}, events)
+	var names []string
+	for _, s := range r.Statistics() {
+		names = append(names, s.Name)
+		assert.Equal(t, 1, s.Attempt, s.Name)
+	}
+	want := []string{"sqlite/TestA", "sqlite/TestB", "postgres/TestA", "postgres/TestB"}
+	assert.Equal(t, want, names)
+	assert.Equal(t, want, ended)
+}
+
+func Test_SetVariants_ShouldRetryWithinVariant(t *testing.T) {
+	// Arrange
+	r := newInstance(newTestingM("TestFlaky")).(*runner)
+	defer r.PrintToStdout(printStdout)
+	r.PrintToStdout(false)
+	r.SetRetries(1)
+	require.NoError(t, r.SetVariants([]Variant{{Name: "sqlite"}, {Name: "postgres"}}))
+	runs := 0
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		for _, test := range tests {
+			_, _, name := MatchTag(test.Name)
+			runs++
+			r.AddStatistics(&constants.Statistics{Name: name, Failed: runs%2 == 1}) // fails first, passes on retry
+		}
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	var got []string
+	for _, s := range r.Statistics() {
+		got = append(got, fmt.Sprintf("%s retry %d failed %t", s.Name, s.Retry, s.Failed))
+	}
+	assert.Equal(t, []string{
+		"sqlite/TestFlaky retry 0 failed true",
+		"sqlite/TestFlaky retry 1 failed false",
+		"postgres/TestFlaky retry 0 failed true",
+		"postgres/TestFlaky retry 1 failed false",
+	}, got)
+	assert.True(t, r.Passed())
+}
+
+func Test_SetVariants_ShouldRejectDuplicateNames(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+
+	// Act
+	err := r.SetVariants([]Variant{{Name: "sqlite"}, {Name: "sqlite"}})
+
+	// Assert
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+)
+
+/*
+webhook.go: Posting a compact JSON summary of a run to a URL, e.g. for chat notifications
+*/
+
+// ResultSummary is the JSON body posted by PostResult
+type ResultSummary struct {
+	RunID    string        `json:"run_id"`
+	OK       bool          `json:"ok"` // no test failed in its last retry and the Run didn't fail otherwise
+	Failure  string        `json:"failure,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+	Total    int           `json:"total"`    // the test runs, by their last retry (see SetRetries)
+	Attempts int           `json:"attempts"` // the test runs including the retries, one per Statistics
+	Passed   int           `json:"passed"`   // not counting the flaky passes
+	Flaky    int           `json:"flaky"`    // passed on a retry, see Statistics.Flaky
+	Failed   int           `json:"failed"`
+	Skipped  int           `json:"skipped"`
+	Failures []string      `json:"failures,omitempty"` // the top-level tests that failed
+
+	NoTestsRan      bool           `json:"no_tests_ran,omitempty"`      // see RunInfo.NoTestsRan
+	PeakGoroutines  *GoroutinePeak `json:"peak_goroutines,omitempty"`   // see RunInfo.PeakGoroutines
+	RetryBudgetLeft *int           `json:"retry_budget_left,omitempty"` // see RunInfo.RetryBudgetLeft; nil in a merged result
+	RandSeed        int64          `json:"rand_seed,omitempty"`         // see RunInfo.RandSeed
+}
+
+// Summarize returns the summary of a Run from its RunInfo and statistics
+func Summarize(info RunInfo, stats []constants.Statistics) ResultSummary {
+	s := ResultSummary{
+		RunID:    info.RunID,
+		Failure:  info.Failure,
+		Duration: info.FinishedAt.Sub(info.StartedAt),
+
+		NoTestsRan:      info.NoTestsRan,
+		PeakGoroutines:  info.PeakGoroutines,
+		RetryBudgetLeft: info.RetryBudgetLeft,
+		RandSeed:        info.RandSeed,
+	}
+	s.countTests(stats)
+	s.OK = s.Failure == "" && len(s.Failures) == 0
+	return s
+}
+
+// countTests sets the totals and Failures of s from stats. A retried test is
+// counted by its last retry only, so a test that passed on a retry is one
+// flaky test rather than a failure and a pass.
+func (s *ResultSummary) countTests(stats []constants.Statistics) {
+	s.Total, s.Attempts, s.Passed, s.Flaky, s.Failed, s.Skipped = 0, len(stats), 0, 0, 0, 0
+	s.Failures = failedTests(stats)
+	lastRetry := make(map[string]int)
+	for _, stat := range stats {
+		if stat.Retry > lastRetry[stat.Name] {
+			lastRetry[stat.Name] = stat.Retry
+		}
+	}
+	for _, stat := range stats {
+		if stat.Retry != lastRetry[stat.Name] {
+			continue
+		}
+		s.Total++
+		switch Outcome(stat) {
+		case constants.StatusPass:
+			if stat.Flaky {
+				s.Flaky++
+			} else {
+				s.Passed++
+			}
+		case constants.StatusFail:
+			s.Failed++
+		case constants.StatusSkip:
+			s.Skipped++
+		}
+	}
+}
+
+// PostResult POSTs the ResultSummary of res as JSON to url. It returns an
+// error if the request fails or the response status is not 2xx. The request
+// is bounded by ctx.
+func PostResult(ctx context.Context, url string, res *Result) error {
+	body, err := json.Marshal(res.ResultSummary)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) // so the connection can be reused
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("posting result to %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// SetWebhook makes Run post its result to url with PostResult when it ends,
+// waiting at most timeout (0 means constants.DefaultHttpTimeout). A failed
+// post is recorded as a warning (see Warnings) and doesn't fail the Run. An
+// empty url turns posting off.
+func (r *runner) SetWebhook(url string, timeout time.Duration) {
+	r.webhookURL = url
+	r.webhookWait = timeout
+}
+
+// postResult posts the Run's result as set by SetWebhook
+func (r *runner) postResult() {
+	if r.webhookURL == "" || r.listMatcher != nil {
+		return
+	}
+	timeout := r.webhookWait
+	if timeout <= 0 {
+		timeout = constants.DefaultHttpTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := PostResult(ctx, r.webhookURL, r.Result()); err != nil {
+		r.addWarning(fmt.Sprintf("webhook: %v", err))
+	}
+}
@@ -0,0 +1,132 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebhook starts a server that records the bodies posted to it and
+// answers with status
+func fakeWebhook(t *testing.T, status int) (url string, bodies chan []byte) {
+	bodies = make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, bodies
+}
+
+func Test_PostResult_ShouldPostTotals(t *testing.T) {
+	// Arrange
+	url, bodies := fakeWebhook(t, http.StatusNoContent)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	info := RunInfo{RunID: "run-1", StartedAt: start, FinishedAt: start.Add(3 * time.Second)}
+	stats := []constants.Statistics{
+		{Name: "TestA"},
+		{Name: "TestB", Failed: true},
+		{Name: "TestC", Statuses: []constants.Status{{Status: constants.StatusSkip}}},
+	}
+
+	// Act
+	err := PostResult(context.Background(), url, &Result{ResultSummary: Summarize(info, stats), Stats: stats})
+
+	// Assert
+	require.NoError(t, err)
+	var posted ResultSummary
+	require.NoError(t, json.Unmarshal(<-bodies, &posted))
+	assert.Equal(t, ResultSummary{
+		RunID:    "run-1",
+		OK:       false,
+		Duration: 3 * time.Second,
+		Total:    3,
+		Attempts: 3,
+		Passed:   1,
+		Failed:   1,
+		Skipped:  1,
+		Failures: []string{"TestB"},
+	}, posted)
+}
+
+func Test_PostResult_ShouldFailOnNon2xx(t *testing.T) {
+	// Arrange
+	url, _ := fakeWebhook(t, http.StatusBadGateway)
+
+	// Act
+	err := PostResult(context.Background(), url, &Result{})
+
+	// Assert
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "502")
+}
+
+func Test_Run_ShouldPostResultToWebhook(t *testing.T) {
+	// Arrange
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	url, bodies := fakeWebhook(t, http.StatusOK)
+	r := newInstance(newTestingM("TestA")).(*runner)
+	r.SetWebhook(url, time.Second)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		r.AddStatistics(&constants.Statistics{Name: "TestA"})
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	var posted ResultSummary
+	require.NoError(t, json.Unmarshal(<-bodies, &posted))
+	assert.True(t, posted.OK)
+	assert.Equal(t, 1, posted.Passed)
+	assert.Equal(t, r.RunInfo().RunID, posted.RunID)
+	assert.Empty(t, r.Warnings())
+}
+
+func Test_Run_ShouldPostOnlyTheResultOfTheLastRun(t *testing.T) {
+	// Arrange
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	url, bodies := fakeWebhook(t, http.StatusOK)
+	r := newInstance(newTestingM("TestA")).(*runner)
+	r.SetWebhook(url, time.Second)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		r.AddStatistics(&constants.Statistics{Name: "TestA"})
+	}
+	r.Run()
+	<-bodies
+
+	// Act
+	r.Run()
+
+	// Assert
+	var posted ResultSummary
+	require.NoError(t, json.Unmarshal(<-bodies, &posted))
+	assert.Equal(t, 1, posted.Total)
+	assert.Equal(t, 1, posted.Passed)
+}
+
+func Test_Run_ShouldWarnWhenWebhookFails(t *testing.T) {
+	// Arrange
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	url, _ := fakeWebhook(t, http.StatusInternalServerError)
+	r := newInstance(newTestingM("TestA")).(*runner)
+	r.SetWebhook(url, time.Second)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {}
+
+	// Act
+	r.Run()
+
+	// Assert
+	require.Equal(t, 1, len(r.Warnings()))
+	assert.Contains(t, r.Warnings()[0], "webhook:")
+	assert.True(t, r.Passed())
+}
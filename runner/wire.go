@@ -0,0 +1,294 @@
+package runner
+
+import (
+	"flag"
+	"regexp"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+/*
+wire.go: A serializable (gob/JSON) snapshot of the runner's settings so a run can be configured remotely or persisted.
+Callbacks, writers and the event logger can't be serialized and are not included. The run's statistics ([]constants.Statistics) are already plain data.
+Every zero value keeps the default of a new runner, so a WireConfig only needs the settings it changes: gob doesn't send
+zero values, not even through a pointer, so a setting that is on by default is carried as its inverse.
+*/
+
+// WireConfig holds the serializable settings of a Runner
+type WireConfig struct {
+	MatchPattern          string
+	ExactNames            []string // see MatchNames; takes precedence over MatchPattern
+	SkipPattern           string
+	AllowEnvPatterns      bool   // see SetAllowEnvPatterns
+	List                  string // see SetList; when set a Run only lists the matching tests
+	RunTimeout            time.Duration
+	PerTestTimeout        time.Duration
+	PerTestBudget         time.Duration
+	Parallel              int // 0 keeps the command line -parallel, see ResolveParallel
+	Count                 int // 0 keeps the command line -count, negative runs nothing (SetCount(0))
+	CPUProfile            string
+	CPUProfileDuration    time.Duration
+	ArtifactDir           string
+	KeepFailedTempDirs    bool
+	CorpusCoercion        bool
+	MaxFailures           int
+	FailFast              bool
+	FailOnSkip            bool
+	FailOnNoTests         bool
+	FailOnStderr          bool
+	SplitStderr           bool // SetCombineOutput(false); stderr is combined by default, like go test
+	RunID                 string
+	MaxTotalOutputBytes   int
+	MaxFailureLines       int
+	Strict                bool
+	MinCoverage           float64
+	MemSummary            bool
+	TrackPeakGoroutines   bool
+	GoroutineInterval     time.Duration
+	RandSeed              int64
+	SplitLogOutput        bool
+	GroupOutput           bool
+	TestLogFlushInterval  time.Duration
+	TrackFileAccess       bool
+	TrackOpenFiles        bool
+	DetectRaces           bool
+	Retries               int
+	RetryBackoff          time.Duration
+	RetryBackoffFactor    float64
+	RetryBudget           int
+	HeapBackoff           HeapBackoff
+	WebhookURL            string // see SetWebhook
+	WebhookTimeout        time.Duration
+	SortTests             string // see SetSortTests; SortDuration uses the runner's own statistics
+	HookScope             string // see SetHookScope; the hooks themselves are not part of WireConfig
+	Verbose               bool
+	SuppressStdout        bool // PrintToStdout(false); printing is on by default
+	PrintOutputToEventLog bool
+
+	compiled *compiledPatterns // see PrecompileMatchers
+}
+
+// compiledPatterns are the patterns of a WireConfig, compiled
+type compiledPatterns struct {
+	match, skip, list     string // the patterns they were compiled from
+	matchRe               *regexp.Regexp
+	matcher, skipM, listM *Matcher // nil for an empty skip or list pattern
+}
+
+// Wire returns the runner's current settings
+func (r *runner) Wire() WireConfig {
+	return WireConfig{
+		MatchPattern:          r.matchPattern,
+		ExactNames:            r.exactNames,
+		SkipPattern:           r.skipPattern,
+		AllowEnvPatterns:      r.envPatterns,
+		List:                  r.listPattern,
+		RunTimeout:            r.runTimeout,
+		PerTestTimeout:        r.testTimeout,
+		PerTestBudget:         r.testBudget,
+		Parallel:              r.parallel,
+		Count:                 wireCount(r.count),
+		CPUProfile:            r.cpuProfile,
+		CPUProfileDuration:    r.cpuProfDur,
+		ArtifactDir:           r.artifactDir,
+		KeepFailedTempDirs:    keepFailedTempDirs,
+		CorpusCoercion:        r.coerceCorpus,
+		MaxFailures:           r.maxFailures,
+		FailFast:              r.failFast,
+		FailOnSkip:            r.failOnSkip,
+		FailOnNoTests:         r.failNoTests,
+		FailOnStderr:          r.failStderr,
+		SplitStderr:           r.splitStderr,
+		RunID:                 r.runID,
+		MaxTotalOutputBytes:   r.maxOutput,
+		MaxFailureLines:       r.maxFailLines,
+		Strict:                r.strict,
+		MinCoverage:           r.minCoverage,
+		MemSummary:            r.memSummary,
+		TrackPeakGoroutines:   r.peakRoutines,
+		GoroutineInterval:     r.goSampleTick,
+		RandSeed:              r.randSeed,
+		SplitLogOutput:        r.splitLog,
+		GroupOutput:           r.groupOutput,
+		TestLogFlushInterval:  r.testLogFlush,
+		TrackFileAccess:       r.trackFiles,
+		TrackOpenFiles:        r.trackOpen,
+		DetectRaces:           r.detectRaces,
+		Retries:               r.retries,
+		RetryBackoff:          r.retryBackoff,
+		RetryBackoffFactor:    r.retryFactor,
+		RetryBudget:           r.retryBudget,
+		HeapBackoff:           r.heapBackoff,
+		WebhookURL:            r.webhookURL,
+		WebhookTimeout:        r.webhookWait,
+		SortTests:             r.sortTests,
+		HookScope:             r.hookScope,
+		Verbose:               r.verbose,
+		SuppressStdout:        !printStdout,
+		PrintOutputToEventLog: printOutputToEventLog,
+	}
+}
+
+// FromWire applies settings from a WireConfig. Patterns are validated the
+// same way as Match and Skip; on error no settings are changed.
+func (r *runner) FromWire(c WireConfig) error {
+	if err := checkTopLevelNames(c.ExactNames); err != nil {
+		return err
+	}
+	if err := checkSortOrder(c.SortTests); err != nil {
+		return err
+	}
+	if err := checkHookScope(c.HookScope); err != nil {
+		return err
+	}
+	p := c.compiled
+	if !p.compiledFrom(c) {
+		var err error
+		if p, err = compilePatterns(c); err != nil {
+			return err
+		}
+	}
+
+	r.setMatch(p.match, p.matchRe, p.matcher)
+	if len(c.ExactNames) > 0 {
+		r.exactNames = append([]string(nil), c.ExactNames...)
+	}
+	r.skipPattern, r.skipMatcher = p.skip, p.skipM
+	r.SetAllowEnvPatterns(c.AllowEnvPatterns)
+	r.listPattern, r.listMatcher = p.list, p.listM
+	r.SetRunTimeout(c.RunTimeout)
+	r.SetPerTestTimeout(c.PerTestTimeout)
+	r.SetPerTestBudget(c.PerTestBudget)
+	r.SetParallel(c.Parallel)
+	r.SetCount(countFromWire(c.Count))
+	r.SetCPUProfile(c.CPUProfile)
+	r.SetCPUProfileDuration(c.CPUProfileDuration)
+	r.SetArtifactDir(c.ArtifactDir)
+	r.SetKeepFailedTempDirs(c.KeepFailedTempDirs)
+	r.SetCorpusCoercion(c.CorpusCoercion)
+	r.SetMaxFailures(c.MaxFailures)
+	r.SetFailFast(c.FailFast)
+	r.SetFailOnSkip(c.FailOnSkip)
+	r.SetFailOnNoTests(c.FailOnNoTests)
+	r.SetFailOnStderr(c.FailOnStderr)
+	r.SetCombineOutput(!c.SplitStderr)
+	r.SetRunID(c.RunID)
+	r.SetMaxTotalOutputBytes(c.MaxTotalOutputBytes)
+	r.SetMaxFailureLines(c.MaxFailureLines)
+	r.SetStrict(c.Strict)
+	r.SetMinCoverage(c.MinCoverage)
+	r.SetMemSummary(c.MemSummary)
+	r.SetTrackPeakGoroutines(c.TrackPeakGoroutines)
+	r.SetGoroutineSampleInterval(c.GoroutineInterval)
+	r.SetRandSeed(c.RandSeed)
+	r.SetSplitLogOutput(c.SplitLogOutput)
+	r.SetGroupOutput(c.GroupOutput)
+	r.SetTestLogFlushInterval(c.TestLogFlushInterval)
+	r.SetTrackFileAccess(c.TrackFileAccess)
+	r.SetTrackOpenFiles(c.TrackOpenFiles)
+	r.SetDetectRaces(c.DetectRaces)
+	r.SetRetries(c.Retries)
+	r.SetRetryBackoff(c.RetryBackoff, c.RetryBackoffFactor)
+	r.SetRetryBudget(c.RetryBudget)
+	r.SetHeapBackoff(c.HeapBackoff)
+	r.SetWebhook(c.WebhookURL, c.WebhookTimeout)
+	r.SetSortTests(c.SortTests, nil)
+	r.SetHookScope(c.HookScope)
+	r.SetVerbose(c.Verbose)
+	r.PrintToStdout(!c.SuppressStdout)
+	r.PrintOutputToEventLog(c.PrintOutputToEventLog)
+	return nil
+}
+
+// PrecompileMatchers compiles the patterns of c once, so that FromWire
+// doesn't compile them again, e.g. for many short Runs with the same
+// settings. It returns the error FromWire would for an invalid pattern.
+// Patterns changed afterwards are compiled by FromWire as usual.
+func (c *WireConfig) PrecompileMatchers() error {
+	if err := checkTopLevelNames(c.ExactNames); err != nil {
+		return err
+	}
+	p, err := compilePatterns(*c)
+	if err != nil {
+		return err
+	}
+	c.compiled = p
+	return nil
+}
+
+// matchPatternOf returns the pattern that selects the tests to run with c
+func matchPatternOf(c WireConfig) string {
+	if len(c.ExactNames) > 0 {
+		return namesPattern(c.ExactNames)
+	}
+	if c.MatchPattern == "" {
+		return ".*"
+	}
+	return c.MatchPattern
+}
+
+func compilePatterns(c WireConfig) (*compiledPatterns, error) {
+	p := &compiledPatterns{match: matchPatternOf(c), skip: c.SkipPattern, list: c.List}
+	var err error
+	if p.matchRe, err = compileRegexp(p.match); err != nil {
+		return nil, &PatternError{Pattern: p.match, Err: err}
+	}
+	if p.matcher, err = NewMatcher(p.match); err != nil {
+		return nil, &PatternError{Pattern: p.match, Err: err}
+	}
+	if p.skip != "" {
+		if p.skipM, err = NewMatcher(p.skip); err != nil {
+			return nil, &PatternError{Pattern: p.skip, Err: err}
+		}
+	}
+	if p.list != "" {
+		if p.listM, err = NewMatcher(p.list); err != nil {
+			return nil, &PatternError{Pattern: p.list, Err: err}
+		}
+	}
+	return p, nil
+}
+
+// compiledFrom returns true if p was compiled from the patterns of c
+func (p *compiledPatterns) compiledFrom(c WireConfig) bool {
+	return p != nil && p.match == matchPatternOf(c) && p.skip == c.SkipPattern && p.list == c.List
+}
+
+// wireCount returns the WireConfig.Count for a count set with SetCount, where
+// zero runs nothing and a negative count keeps the command line -count
+func wireCount(n int) int {
+	switch {
+	case n < 0:
+		return 0
+	case n == 0:
+		return -1
+	}
+	return n
+}
+
+// countFromWire returns the SetCount count for a WireConfig.Count
+func countFromWire(n int) int {
+	switch {
+	case n == 0:
+		return -1
+	case n < 0:
+		return 0
+	}
+	return n
+}
+
+// ResolveParallel returns the number of tests a Run with c runs in parallel:
+// Parallel if set, otherwise the command line -test.parallel, which defaults
+// to runtime.GOMAXPROCS(0) like go test -parallel
+func (c WireConfig) ResolveParallel() int {
+	if c.Parallel > 0 {
+		return c.Parallel
+	}
+	if f := flag.Lookup("test.parallel"); f != nil {
+		if n, err := strconv.Atoi(f.Value.String()); err == nil && n > 0 {
+			return n
+		}
+	}
+	return runtime.GOMAXPROCS(0)
+}
@@ -0,0 +1,345 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"flag"
+	"regexp"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mercari/testdeck/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WireConfig_ShouldGobRoundTrip(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	defer r.PrintToStdout(printStdout)
+	defer r.PrintOutputToEventLog(printOutputToEventLog)
+	defer r.SetKeepFailedTempDirs(keepFailedTempDirs)
+	require.NoError(t, r.Match("TestParent/TestChild"))
+	require.NoError(t, r.Skip("TestSlow"))
+	r.SetAllowEnvPatterns(true)
+	require.NoError(t, r.SetList("TestParent"))
+	r.SetRunTimeout(time.Minute)
+	r.SetPerTestTimeout(10 * time.Second)
+	r.SetPerTestBudget(5 * time.Second)
+	r.SetParallel(4)
+	r.SetCount(2)
+	r.SetCPUProfile("cpu.out")
+	r.SetCPUProfileDuration(time.Minute)
+	r.SetArtifactDir("artifacts")
+	r.SetKeepFailedTempDirs(true)
+	r.SetCorpusCoercion(true)
+	r.SetMaxFailures(3)
+	r.SetFailFast(true)
+	r.SetFailOnSkip(true)
+	r.SetFailOnNoTests(true)
+	r.SetFailOnStderr(true)
+	r.SetCombineOutput(false)
+	r.SetRunID("retry-of-1234")
+	r.SetMaxTotalOutputBytes(1 << 20)
+	r.SetMaxFailureLines(50)
+	r.SetStrict(true)
+	r.SetMinCoverage(80)
+	r.SetMemSummary(true)
+	r.SetTrackPeakGoroutines(true)
+	r.SetGoroutineSampleInterval(5 * time.Millisecond)
+	r.SetVerbose(true)
+	r.SetRandSeed(42)
+	r.SetSplitLogOutput(true)
+	r.SetGroupOutput(true)
+	r.SetTestLogFlushInterval(time.Second)
+	r.SetTrackFileAccess(true)
+	r.SetTrackOpenFiles(true)
+	r.SetDetectRaces(true)
+	r.SetRetries(2)
+	r.SetRetryBackoff(time.Second, 2)
+	r.SetRetryBudget(5)
+	r.SetHeapBackoff(HeapBackoff{HighWatermarkBytes: 1 << 30})
+	r.SetWebhook("https://chat.example.com/hooks/tests", 5*time.Second)
+	require.NoError(t, r.SetSortTests(SortDuration, nil))
+	require.NoError(t, r.SetHookScope(HookScopeIteration))
+	r.PrintToStdout(false)
+	r.PrintOutputToEventLog(true)
+	var buf bytes.Buffer
+
+	// Act
+	require.NoError(t, gob.NewEncoder(&buf).Encode(r.Wire()))
+	var decoded WireConfig
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+	r2 := newInstance(&bm)
+	err := r2.FromWire(decoded)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, WireConfig{
+		MatchPattern:          "TestParent/TestChild",
+		SkipPattern:           "TestSlow",
+		AllowEnvPatterns:      true,
+		List:                  "TestParent",
+		RunTimeout:            time.Minute,
+		PerTestTimeout:        10 * time.Second,
+		PerTestBudget:         5 * time.Second,
+		Parallel:              4,
+		Count:                 2,
+		CPUProfile:            "cpu.out",
+		CPUProfileDuration:    time.Minute,
+		ArtifactDir:           "artifacts",
+		KeepFailedTempDirs:    true,
+		CorpusCoercion:        true,
+		MaxFailures:           3,
+		FailFast:              true,
+		FailOnSkip:            true,
+		FailOnNoTests:         true,
+		FailOnStderr:          true,
+		SplitStderr:           true,
+		RunID:                 "retry-of-1234",
+		MaxTotalOutputBytes:   1 << 20,
+		MaxFailureLines:       50,
+		Strict:                true,
+		MinCoverage:           80,
+		MemSummary:            true,
+		TrackPeakGoroutines:   true,
+		GoroutineInterval:     5 * time.Millisecond,
+		Verbose:               true,
+		RandSeed:              42,
+		SplitLogOutput:        true,
+		GroupOutput:           true,
+		TestLogFlushInterval:  time.Second,
+		TrackFileAccess:       true,
+		TrackOpenFiles:        true,
+		DetectRaces:           true,
+		Retries:               2,
+		RetryBackoff:          time.Second,
+		RetryBackoffFactor:    2,
+		RetryBudget:           5,
+		HeapBackoff:           HeapBackoff{HighWatermarkBytes: 1 << 30},
+		WebhookURL:            "https://chat.example.com/hooks/tests",
+		WebhookTimeout:        5 * time.Second,
+		SortTests:             SortDuration,
+		HookScope:             HookScopeIteration,
+		SuppressStdout:        true,
+		PrintOutputToEventLog: true,
+	}, decoded)
+	assert.Equal(t, r.Wire(), r2.Wire())
+}
+
+func Test_FromWire_ShouldRejectInvalidPattern(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	require.NoError(t, r.Match("TestA"))
+
+	// Act
+	err := r.FromWire(WireConfig{MatchPattern: "TestB", SkipPattern: "("})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, "TestA", r.Wire().MatchPattern)
+}
+
+func Test_Statistics_ShouldGobAndJSONRoundTrip(t *testing.T) {
+	// Arrange
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	stats := []constants.Statistics{
+		{
+			Name:     "TestFail",
+			Failed:   true,
+			Fatal:    true,
+			Statuses: []constants.Status{{Status: constants.StatusFail, Lifecycle: constants.LifecycleAct, Fatal: true}},
+			Timings: map[string]constants.Timing{
+				constants.LifecycleAct: {Lifecycle: constants.LifecycleAct, Start: start, End: start.Add(time.Second), Duration: time.Second, Started: true, Ended: true},
+			},
+			Start:      start,
+			End:        start.Add(2 * time.Second),
+			Duration:   2 * time.Second,
+			Output:     "--- FAIL: TestFail\n",
+			SkipReason: "",
+		},
+	}
+
+	// Act
+	var gobBuf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&gobBuf).Encode(stats))
+	var fromGob []constants.Statistics
+	require.NoError(t, gob.NewDecoder(&gobBuf).Decode(&fromGob))
+	jsonBytes, err := json.Marshal(stats)
+	require.NoError(t, err)
+	var fromJSON []constants.Statistics
+	require.NoError(t, json.Unmarshal(jsonBytes, &fromJSON))
+
+	// Assert
+	assert.Equal(t, stats, fromGob)
+	assert.Equal(t, stats, fromJSON)
+}
+
+func Test_FromWire_ShouldApplyExactNames(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm)
+	defer r.PrintToStdout(printStdout)
+	defer r.PrintOutputToEventLog(printOutputToEventLog)
+
+	// Act
+	err := r.FromWire(WireConfig{MatchPattern: "TestIgnored", ExactNames: []string{"Test.A", "TestB"}, SuppressStdout: !printStdout})
+
+	// Assert
+	require.NoError(t, err)
+	wired := r.Wire()
+	assert.Equal(t, []string{"Test.A", "TestB"}, wired.ExactNames)
+	assert.Equal(t, namesPattern([]string{"Test.A", "TestB"}), wired.MatchPattern)
+	r2 := newInstance(&bm)
+	require.NoError(t, r2.FromWire(wired))
+	assert.Equal(t, wired, r2.Wire())
+}
+
+func Test_ResolveParallel_ShouldDefaultToGOMAXPROCS(t *testing.T) {
+	if flag.Lookup("test.parallel").Value.String() != strconv.Itoa(runtime.GOMAXPROCS(0)) {
+		t.Skip("needs to run without -parallel")
+	}
+
+	// Act
+	unset := WireConfig{}.ResolveParallel()
+	set := WireConfig{Parallel: 3}.ResolveParallel()
+
+	// Assert
+	assert.Equal(t, runtime.GOMAXPROCS(0), unset)
+	assert.Equal(t, 3, set)
+}
+
+func Test_ResolveParallel_ShouldUseCommandLineValue(t *testing.T) {
+	// Arrange
+	restore := setTestFlag("test.parallel", "7")
+	defer restore()
+
+	// Act
+	got := WireConfig{}.ResolveParallel()
+
+	// Assert
+	assert.Equal(t, 7, got)
+}
+
+func Test_FromWire_ShouldKeepDefaultsForZeroConfig(t *testing.T) {
+	// Arrange
+	defer func(stdout bool) { printStdout = stdout }(printStdout)
+	printStdout = true
+	fresh := newInstance(newFakeTestingM()).(*runner)
+	r := newInstance(newFakeTestingM()).(*runner)
+
+	// Act
+	err := r.FromWire(WireConfig{})
+
+	// Assert
+	require.NoError(t, err)
+	want := fresh.Wire()
+	want.MatchPattern = ".*" // what the unset pattern of a new runner matches
+	assert.Equal(t, want, r.Wire())
+	assert.True(t, printStdout)
+	assert.False(t, r.splitStderr)
+	assert.Equal(t, -1, r.count)
+}
+
+func Test_FromWire_ShouldKeepCommandLineCountForZeroCount(t *testing.T) {
+	tests := map[string]struct {
+		count    int
+		wantRuns int
+	}{
+		"Unset":      {count: 0, wantRuns: 1},
+		"Explicit":   {count: 2, wantRuns: 1},
+		"RunNothing": {count: -1, wantRuns: 0},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			r := newInstance(newFakeTestingM()).(*runner)
+			defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+			runs := 0
+			runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+				runs++
+			}
+			require.NoError(t, r.FromWire(WireConfig{Count: tc.count, SuppressStdout: !printStdout}))
+
+			// Act
+			r.Run()
+
+			// Assert
+			assert.Equal(t, tc.wantRuns, runs)
+			assert.Equal(t, tc.count, r.Wire().Count)
+		})
+	}
+}
+
+func Test_PrecompileMatchers_ShouldReuseRegexpsAcrossRuns(t *testing.T) {
+	// Arrange
+	defer func(prev func(deps testDeps, tests []testing.InternalTest)) { runnerMainStart = prev }(runnerMainStart)
+	defer func(prev func(string) (*regexp.Regexp, error)) { compileRegexp = prev }(compileRegexp)
+	compiles := 0
+	compileRegexp = func(pattern string) (*regexp.Regexp, error) {
+		compiles++
+		return regexp.Compile(pattern)
+	}
+	c := WireConfig{MatchPattern: "TestA/TestChild", SkipPattern: "TestSlow", Count: 1, SuppressStdout: !printStdout}
+	require.NoError(t, c.PrecompileMatchers())
+	precompiled := compiles
+	r := newInstance(newTestingM("TestA", "TestB")).(*runner)
+	var selected [][]string
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		var names []string
+		for _, test := range tests {
+			if _, matched, name := MatchTag(test.Name); matched {
+				names = append(names, name)
+			}
+		}
+		selected = append(selected, names)
+	}
+
+	// Act
+	require.NoError(t, r.FromWire(c))
+	matchRe := r.matchRe
+	r.Run()
+	require.NoError(t, r.FromWire(c))
+	r.Run()
+
+	// Assert
+	assert.NotZero(t, precompiled)
+	assert.Equal(t, precompiled, compiles)
+	assert.Same(t, matchRe, r.matchRe)
+	assert.Equal(t, [][]string{{"TestA"}, {"TestA"}}, selected)
+}
+
+func Test_PrecompileMatchers_ShouldSurfaceInvalidPattern(t *testing.T) {
+	// Arrange
+	c := WireConfig{MatchPattern: "TestA", List: "TestB/("}
+
+	// Act
+	err := c.PrecompileMatchers()
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, c.compiled)
+}
+
+func Test_FromWire_ShouldRecompileChangedPatterns(t *testing.T) {
+	// Arrange
+	bm := badM{}
+	r := newInstance(&bm).(*runner)
+	c := WireConfig{MatchPattern: "TestA", SuppressStdout: !printStdout}
+	require.NoError(t, c.PrecompileMatchers())
+	c.MatchPattern = "TestB"
+
+	// Act
+	err := r.FromWire(c)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "TestB", r.matchRe.String())
+	ok, _ := r.matcher.MatchFullName("TestB")
+	assert.True(t, ok)
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"sync"
+	"testing"
+)
+
+/*
+wrap.go: Tests registered with the runner in addition to the ones of its testing.M, with a per-test wrapper of their
+*testing.T that helper libraries can retrieve to attach their own state, since the testing package creates every T.
+*/
+
+// registeredTest is a test added with RegisterWithWrapper
+type registeredTest struct {
+	name string
+	fn   func(t *testing.T)
+	wrap func(t *testing.T) any
+}
+
+// wrappers holds the wrapper of each running registered test
+var wrappers sync.Map // *testing.T to any
+
+// RegisterWithWrapper adds a top-level test to the tests of the following
+// Runs, after the ones of the runner's testing.M. When the test starts, wrap
+// (if not nil) is called with its T and the value it returns can be
+// retrieved with Wrapped until the test finishes, e.g. a T with assertion
+// helpers or per-test metadata. Like HookFor, registered tests are kept in
+// memory only; a name that is already taken is reported by Validate. To
+// register from init, before the runner exists, use DefaultDeck.
+func (r *runner) RegisterWithWrapper(name string, fn func(t *testing.T), wrap func(t *testing.T) any) {
+	r.registered = append(r.registered, registeredTest{name: name, fn: fn, wrap: wrap})
+}
+
+// Wrapped returns the wrapper created for t by the wrap func given to
+// RegisterWithWrapper, or nil if t is not a running registered test (a
+// subtest has its own T, which has no wrapper)
+func Wrapped(t *testing.T) any {
+	w, _ := wrappers.Load(t)
+	return w
+}
+
+// allTests returns the tests of r.m followed by the registered tests, the
+// runner's before the default deck's
+func (r *runner) allTests() []testing.InternalTest {
+	tests := getInternalTests(r.m)
+	for _, reg := range r.registered {
+		tests = append(tests, reg.internalTest())
+	}
+	for _, reg := range DefaultDeck().registered() {
+		tests = append(tests, reg.internalTest())
+	}
+	return tests
+}
+
+// internalTest returns the test, creating its wrapper when it starts
+func (reg registeredTest) internalTest() testing.InternalTest {
+	return testing.InternalTest{Name: reg.name, F: func(t *testing.T) {
+		if reg.wrap != nil {
+			wrappers.Store(t, reg.wrap(t))
+			t.Cleanup(func() { wrappers.Delete(t) })
+		}
+		reg.fn(t)
+	}}
+}
@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wrappedT is a wrapper a helper library might attach to each test
+type wrappedT struct {
+	*testing.T
+	test string
+}
+
+func Test_RegisterWithWrapper_ShouldCreateWrapperPerTest(t *testing.T) {
+	// Arrange
+	r := newInstance(newFakeTestingM()).(*runner)
+	var created []string
+	seen := make(map[string]any)
+	wrap := func(t *testing.T) any {
+		created = append(created, t.Name())
+		return &wrappedT{T: t, test: t.Name()}
+	}
+	body := func(t *testing.T) {
+		seen[t.Name()] = Wrapped(t)
+		t.Run("Sub", func(t *testing.T) { seen[t.Name()] = Wrapped(t) })
+	}
+	r.RegisterWithWrapper("TestFirst", body, wrap)
+	r.RegisterWithWrapper("TestSecond", body, wrap)
+	r.RegisterWithWrapper("TestUnwrapped", body, nil)
+	var outside []any
+	ok := runIsolated(t)
+	runnerMainStart = func(deps testDeps, tests []testing.InternalTest) {
+		*ok = testing.RunTests(func(pat, str string) (bool, error) { return true, nil }, tests)
+		for _, test := range tests {
+			outside = append(outside, Wrapped(&testing.T{}), test.Name)
+		}
+	}
+
+	// Act
+	r.Run()
+
+	// Assert
+	assert.True(t, *ok)
+	assert.Equal(t, []string{"TestFirst", "TestSecond"}, created)
+	first, _ := seen["TestFirst"].(*wrappedT)
+	second, _ := seen["TestSecond"].(*wrappedT)
+	if assert.NotNil(t, first) && assert.NotNil(t, second) {
+		assert.Equal(t, "TestFirst", first.test)
+		assert.Equal(t, "TestSecond", second.test)
+		assert.NotSame(t, first, second)
+	}
+	assert.Nil(t, seen["TestFirst/Sub"])
+	assert.Nil(t, seen["TestUnwrapped"])
+	assert.Equal(t, []any{nil, "TestFirst", nil, "TestSecond", nil, "TestUnwrapped"}, outside)
+	assert.Equal(t, 0, countWrappers())
+}
+
+// countWrappers returns the number of wrappers of running tests
+func countWrappers() int {
+	n := 0
+	wrappers.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
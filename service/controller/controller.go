@@ -74,7 +74,7 @@ func (c *controllerImpl) SetPrintToStdout(printToStdout bool) {
 // -----
 
 // Runs a set of tests matching the regex pattern
-func (c *controllerImpl) runSet(pattern string) (IDs []int, savedToDb bool, stats []constants.Statistics) {
+func (c *controllerImpl) runSet(pattern string) (IDs []int, savedToDb bool, stats []constants.Statistics, passed bool) {
 	var err error
 	var jobID int
 	env, _ := config.ReadFromEnv()
@@ -97,6 +97,7 @@ func (c *controllerImpl) runSet(pattern string) (IDs []int, savedToDb bool, stat
 
 	// Save statistics to DB
 	stats = c.runner.Statistics()
+	passed = c.runner.Passed()
 	c.runner.ClearStatistics()
 	if len(stats) > 0 && saveToDatabase {
 		var err error
@@ -115,12 +116,10 @@ func (c *controllerImpl) runSet(pattern string) (IDs []int, savedToDb bool, stat
 
 // Run all tests
 func (c *controllerImpl) RunAll() string {
-	_, _, stats := c.runSet(".*")
+	_, _, _, passed := c.runSet(".*")
 
-	for _, stat := range stats {
-		if stat.Failed {
-			return constants.ResultFail
-		}
+	if !passed {
+		return constants.ResultFail
 	}
 	return constants.ResultPass
 }
@@ -128,7 +127,7 @@ func (c *controllerImpl) RunAll() string {
 // Run an individual test case by name
 // Note: This method isn't used anywhere right now because the feature to run individual tests by name is not complete yet
 func (c *controllerImpl) Run(name string) (constants.Statistics, []int, bool) {
-	IDs, savedToDb, stats := c.runSet(fmt.Sprintf("^%s$", name))
+	IDs, savedToDb, stats, _ := c.runSet(fmt.Sprintf("^%s$", name))
 
 	if len(stats) == 0 {
 		// TODO: Add logic for when no matching test cases are found